@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"dns-client-go/cache"
+	"dns-client-go/dns"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// adminAPI is the local HTTP API operators use to inspect and control the running server. Every request
+// must carry the configured token as "Authorization: Bearer <token>".
+type adminAPI struct {
+	ctx context.Context // of the server, reloads start zones and lists under it
+	f   *frontend
+}
+
+// serveAdmin starts the admin API, it returns nil when none is configured
+func serveAdmin(ctx context.Context, address string, f *frontend) (*http.Server, error) {
+	if address == "" {
+		return nil, nil
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	api := &adminAPI{ctx: ctx, f: f}
+	server := &http.Server{Handler: api.handler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("admin API failed", "error", err)
+		}
+	}()
+
+	return server, nil
+}
+
+// adminError is an error answered with its HTTP status
+type adminError struct {
+	status  int
+	message string
+}
+
+func (e *adminError) Error() string {
+	return e.message
+}
+
+func badRequest(format string, args ...interface{}) error {
+	return &adminError{http.StatusBadRequest, fmt.Sprintf(format, args...)}
+}
+
+func (a *adminAPI) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", route(methods{http.MethodGet: a.status}))
+	mux.HandleFunc("/config", a.config)
+	mux.HandleFunc("/cache", route(methods{http.MethodGet: a.cacheEntries, http.MethodDelete: a.cacheFlush}))
+	mux.HandleFunc("/filtering/pause", route(methods{http.MethodPost: a.pauseFiltering}))
+	mux.HandleFunc("/filtering/resume", route(methods{http.MethodPost: a.resumeFiltering}))
+	mux.HandleFunc("/reload", route(methods{http.MethodPost: a.reload}))
+	mux.HandleFunc("/reload/zone", route(methods{http.MethodPost: a.reloadZone}))
+	mux.HandleFunc("/reload/lists", route(methods{http.MethodPost: a.reloadLists}))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := a.f.current.Load().config.Admin.Token
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "missing or invalid token"})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// methods maps the HTTP methods of an endpoint to the functions answering them
+type methods map[string]func(*http.Request) (interface{}, error)
+
+// route dispatches a request by its method and answers with what the function returns as JSON
+func route(endpoint methods) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		answer, ok := endpoint[r.Method]
+		if !ok {
+			writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+			return
+		}
+
+		result, err := answer(r)
+		var adminErr *adminError
+		switch {
+		case errors.As(err, &adminErr):
+			writeJSON(w, adminErr.status, map[string]string{"error": adminErr.message})
+		case err != nil:
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		default:
+			writeJSON(w, http.StatusOK, result)
+		}
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(value)
+}
+
+type statusResponse struct {
+	Started         time.Time  `json:"started"`
+	UptimeSeconds   int64      `json:"uptime_seconds"`
+	CacheEntries    int        `json:"cache_entries"`
+	Zones           []string   `json:"zones"`
+	FilteringPaused bool       `json:"filtering_paused"`
+	PausedUntil     *time.Time `json:"paused_until,omitempty"`
+}
+
+func (a *adminAPI) status(*http.Request) (interface{}, error) {
+	s := a.f.current.Load()
+	status := statusResponse{
+		Started:       a.f.started,
+		UptimeSeconds: int64(time.Since(a.f.started) / time.Second),
+		CacheEntries:  s.cache.Len(),
+		Zones:         []string{},
+	}
+	for name := range s.primaries {
+		status.Zones = append(status.Zones, name.String())
+	}
+	for name := range s.secondaries {
+		status.Zones = append(status.Zones, name.String())
+	}
+	sort.Strings(status.Zones)
+	status.FilteringPaused, status.PausedUntil = a.pauseState()
+
+	return status, nil
+}
+
+// config answers with the configuration in effect as YAML, without the admin token
+func (a *adminAPI) config(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "method not allowed"})
+		return
+	}
+
+	cfg := *a.f.current.Load().config
+	cfg.Admin.Token = "<redacted>"
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/yaml")
+	w.Write(data)
+}
+
+type cacheEntry struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	View    string `json:"view,omitempty"`
+	Rcode   string `json:"rcode"`
+	Answers int    `json:"answers"`
+	TTL     uint32 `json:"ttl"`
+}
+
+// cacheSelection reads the name and subtree parameters that select cache entries
+func cacheSelection(r *http.Request) (dns.Name, bool, error) {
+	subtree := false
+	if value := r.URL.Query().Get("subtree"); value != "" {
+		var err error
+		if subtree, err = strconv.ParseBool(value); err != nil {
+			return "", false, badRequest("invalid subtree %q", value)
+		}
+	}
+
+	name, err := dns.ToASCII(r.URL.Query().Get("name"))
+	if err != nil {
+		return "", false, badRequest("invalid name: %v", err)
+	}
+
+	return dns.Name(name), subtree, nil
+}
+
+func (a *adminAPI) cacheEntries(r *http.Request) (interface{}, error) {
+	name, subtree, err := cacheSelection(r)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []cacheEntry{}
+	for _, info := range a.f.current.Load().cache.Entries(name, subtree) {
+		entries = append(entries, newCacheEntry(info))
+	}
+
+	return entries, nil
+}
+
+func newCacheEntry(info cache.EntryInfo) cacheEntry {
+	return cacheEntry{
+		Name:    info.Name.String(),
+		Type:    info.Qtype.String(),
+		View:    info.View,
+		Rcode:   info.Rcode.String(),
+		Answers: info.Answers,
+		TTL:     info.TTL,
+	}
+}
+
+func (a *adminAPI) cacheFlush(r *http.Request) (interface{}, error) {
+	name, subtree, err := cacheSelection(r)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := a.f.current.Load().cache.Flush(name, subtree)
+	slog.Info("flushed cache", "name", name, "subtree", subtree, "removed", removed)
+	return map[string]int{"removed": removed}, nil
+}
+
+// pauseFiltering stops blocking and safe search for the duration parameter, or until resumed
+func (a *adminAPI) pauseFiltering(r *http.Request) (interface{}, error) {
+	var deadline time.Time
+	if value := r.URL.Query().Get("duration"); value != "" {
+		duration, err := time.ParseDuration(value)
+		if err != nil || duration <= 0 {
+			return nil, badRequest("invalid duration %q", value)
+		}
+		deadline = time.Now().Add(duration)
+	}
+
+	a.f.current.Load().pause.Set(deadline)
+	slog.Info("filtering paused", "until", deadline)
+	return a.pauseResponse(), nil
+}
+
+func (a *adminAPI) resumeFiltering(*http.Request) (interface{}, error) {
+	a.f.current.Load().pause.Resume()
+	slog.Info("filtering resumed")
+	return a.pauseResponse(), nil
+}
+
+func (a *adminAPI) pauseResponse() interface{} {
+	paused, until := a.pauseState()
+	return struct {
+		Paused bool       `json:"filtering_paused"`
+		Until  *time.Time `json:"paused_until,omitempty"`
+	}{paused, until}
+}
+
+func (a *adminAPI) pauseState() (bool, *time.Time) {
+	paused, until := a.f.current.Load().pause.State()
+	if !paused || until.IsZero() {
+		return paused, nil
+	}
+
+	return paused, &until
+}
+
+// reload rereads the configuration like SIGHUP
+func (a *adminAPI) reload(*http.Request) (interface{}, error) {
+	if err := reload(a.ctx, a.f); err != nil {
+		slog.Error("keeping the current configuration, reload failed", "error", err)
+		return nil, err
+	}
+
+	return map[string]bool{"reloaded": true}, nil
+}
+
+// reloadZone rereads the file of a primary zone or refreshes a secondary zone from its primaries
+func (a *adminAPI) reloadZone(r *http.Request) (interface{}, error) {
+	name, err := dns.ToASCII(r.URL.Query().Get("name"))
+	if err != nil {
+		return nil, badRequest("invalid name: %v", err)
+	}
+	s := a.f.current.Load()
+
+	if p, ok := s.primaries[dns.Name(name).Canonical()]; ok {
+		if err := p.Load(); err != nil {
+			return nil, err
+		}
+		return map[string]string{"zone": p.Zone().String(), "reloaded": "primary"}, nil
+	}
+	if sec, ok := s.secondaries[dns.Name(name).Canonical()]; ok {
+		sec.Notify()
+		return map[string]string{"zone": sec.Zone().String(), "reloaded": "secondary refresh scheduled"}, nil
+	}
+
+	return nil, &adminError{http.StatusNotFound, fmt.Sprintf("no zone %q", name)}
+}
+
+// reloadLists rereads the blocklists and allowlists of every client group
+func (a *adminAPI) reloadLists(*http.Request) (interface{}, error) {
+	blocked := 0
+	for _, group := range a.f.current.Load().groups.All() {
+		if err := group.Blocklists.Load(); err != nil {
+			return nil, fmt.Errorf("group %v: %w", group.Name, err)
+		}
+		if err := group.Allowlists.Load(); err != nil {
+			return nil, fmt.Errorf("group %v: %w", group.Name, err)
+		}
+		blocked += group.Blocklists.Current().Blocked.Len()
+	}
+
+	return map[string]int{"blocked": blocked}, nil
+}
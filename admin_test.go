@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"dns-client-go/cache"
+	"dns-client-go/config"
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const adminToken = "secret"
+
+// newTestAdmin returns the admin API of a server with the default configuration
+func newTestAdmin(t *testing.T) (*adminAPI, *server) {
+	cfg := config.Default()
+	cfg.Admin.Token = adminToken
+	s, err := newServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("failed to build the server: %v", err)
+	}
+
+	return &adminAPI{ctx: context.Background(), f: newFrontend(s, nil, nil)}, s
+}
+
+// adminCall sends a request with the token to the admin API and decodes its JSON answer into result
+func adminCall(api *adminAPI, method string, target string, result interface{}) int {
+	request := httptest.NewRequest(method, target, nil)
+	request.Header.Set("Authorization", "Bearer "+adminToken)
+	recorder := httptest.NewRecorder()
+	api.handler().ServeHTTP(recorder, request)
+
+	if result != nil {
+		json.NewDecoder(recorder.Body).Decode(result)
+	}
+	return recorder.Code
+}
+
+func TestAdminAPI_Unauthorized(t *testing.T) {
+	api, _ := newTestAdmin(t)
+
+	for _, authorization := range []string{"", "Bearer wrong", "Bearer", "Basic " + adminToken, "bearer " + adminToken} {
+		request := httptest.NewRequest(http.MethodGet, "/status", nil)
+		if authorization != "" {
+			request.Header.Set("Authorization", authorization)
+		}
+		recorder := httptest.NewRecorder()
+		api.handler().ServeHTTP(recorder, request)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code, authorization)
+	}
+}
+
+func TestAdminAPI_Errors(t *testing.T) {
+	api, _ := newTestAdmin(t)
+
+	testCases := []struct {
+		method string
+		target string
+		status int
+	}{
+		{http.MethodPost, "/status", http.StatusMethodNotAllowed},
+		{http.MethodPut, "/config", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/cache", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/reload", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/filtering/pause", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/cache?subtree=maybe", http.StatusBadRequest},
+		{http.MethodDelete, "/cache?name=example.com&subtree=2", http.StatusBadRequest},
+		{http.MethodDelete, "/cache?name=-b%C3%BCcher.de", http.StatusBadRequest},
+		{http.MethodPost, "/filtering/pause?duration=soon", http.StatusBadRequest},
+		{http.MethodPost, "/filtering/pause?duration=-1h", http.StatusBadRequest},
+		{http.MethodPost, "/reload/zone?name=example.com", http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		var answer map[string]string
+		assert.Equal(t, tc.status, adminCall(api, tc.method, tc.target, &answer), "%v %v", tc.method, tc.target)
+		assert.NotEmpty(t, answer["error"], "%v %v", tc.method, tc.target)
+	}
+}
+
+func TestAdminAPI_CacheFlush(t *testing.T) {
+	api, s := newTestAdmin(t)
+	for _, name := range []dns.Name{"www.example.com", "mail.example.com", "www.example.org"} {
+		response := dns.NewPacket()
+		response.Answers = []dns.DnsRecord{dns.NewARecord(name, "192.0.2.1", 300)}
+		s.cache.Put(cache.NewKey(name, querytype.A, ""), response)
+	}
+
+	var flushed map[string]int
+	assert.Equal(t, http.StatusOK, adminCall(api, http.MethodDelete, "/cache?name=Example.COM&subtree=true", &flushed))
+	assert.Equal(t, map[string]int{"removed": 2}, flushed)
+
+	var entries []cacheEntry
+	assert.Equal(t, http.StatusOK, adminCall(api, http.MethodGet, "/cache", &entries))
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "www.example.org", entries[0].Name)
+	assert.Equal(t, "A", entries[0].Type)
+}
+
+func TestAdminAPI_PauseResume(t *testing.T) {
+	api, s := newTestAdmin(t)
+
+	var state struct {
+		Paused bool    `json:"filtering_paused"`
+		Until  *string `json:"paused_until"`
+	}
+	assert.Equal(t, http.StatusOK, adminCall(api, http.MethodPost, "/filtering/pause?duration=1h", &state))
+	assert.True(t, state.Paused)
+	assert.NotNil(t, state.Until)
+	assert.True(t, s.pause.Paused())
+
+	var status statusResponse
+	assert.Equal(t, http.StatusOK, adminCall(api, http.MethodGet, "/status", &status))
+	assert.True(t, status.FilteringPaused)
+	assert.NotNil(t, status.PausedUntil)
+
+	state.Until = nil
+	assert.Equal(t, http.StatusOK, adminCall(api, http.MethodPost, "/filtering/resume", &state))
+	assert.False(t, state.Paused)
+	assert.Nil(t, state.Until)
+	assert.False(t, s.pause.Paused())
+
+	// Without a duration filtering stays paused until resumed
+	assert.Equal(t, http.StatusOK, adminCall(api, http.MethodPost, "/filtering/pause", &state))
+	assert.True(t, state.Paused)
+	assert.Nil(t, state.Until)
+}
+
+func TestAdminRequest(t *testing.T) {
+	testCases := []struct {
+		args   []string
+		method string
+		path   string
+		query  string
+	}{
+		{[]string{"status"}, http.MethodGet, "/status", ""},
+		{[]string{"config"}, http.MethodGet, "/config", ""},
+		{[]string{"cache", "show"}, http.MethodGet, "/cache", "name=&subtree=false"},
+		{[]string{"cache", "show", "-subtree", "example.com"}, http.MethodGet, "/cache", "name=example.com&subtree=true"},
+		{[]string{"cache", "flush", "bücher.de"}, http.MethodDelete, "/cache", "name=xn--bcher-kva.de&subtree=false"},
+		{[]string{"filtering", "pause"}, http.MethodPost, "/filtering/pause", ""},
+		{[]string{"filtering", "pause", "10m"}, http.MethodPost, "/filtering/pause", "duration=10m"},
+		{[]string{"filtering", "resume"}, http.MethodPost, "/filtering/resume", ""},
+		{[]string{"reload"}, http.MethodPost, "/reload", ""},
+		{[]string{"reload", "zone", "example.com"}, http.MethodPost, "/reload/zone", "name=example.com"},
+		{[]string{"reload", "lists"}, http.MethodPost, "/reload/lists", ""},
+	}
+	for _, tc := range testCases {
+		method, path, query, err := adminRequest(tc.args)
+		assert.NoError(t, err, tc.args)
+		assert.Equal(t, tc.method, method, tc.args)
+		assert.Equal(t, tc.path, path, tc.args)
+		assert.Equal(t, tc.query, query.Encode(), tc.args)
+	}
+
+	for _, args := range [][]string{
+		nil,
+		{"status", "now"},
+		{"cache"},
+		{"cache", "show", "a.com", "b.com"},
+		{"cache", "flush", "-all"},
+		{"cache", "flush", "-bücher.de"},
+		{"filtering", "pause", "10m", "now"},
+		{"reload", "zone"},
+		{"reload", "zone", "-bücher.de"},
+		{"restart"},
+	} {
+		_, _, _, err := adminRequest(args)
+		assert.Error(t, err, args)
+	}
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"dns-client-go/config"
+	"dns-client-go/dns"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const adminUsage = `usage: dns-client-go admin [-config file] [-server url] [-token token] command
+
+commands:
+  status                          uptime, zones, cache size and filtering state
+  config                          configuration in effect
+  cache show [-subtree] [name]    cached responses with their remaining TTLs
+  cache flush [-subtree] [name]   remove cached responses, all of them without a name
+  filtering pause [duration]      stop blocking, until resumed without a duration
+  filtering resume
+  reload                          reread the configuration, like SIGHUP
+  reload zone name                reread a primary zone or refresh a secondary zone
+  reload lists                    reread the blocklists and allowlists
+`
+
+// runAdmin implements the admin subcommand, a client of the admin API. The address and the token are
+// read from the configuration file unless given as flags. It returns the exit code.
+func runAdmin(args []string) int {
+	flags := flag.NewFlagSet("admin", flag.ContinueOnError)
+	flags.Usage = func() { fmt.Fprint(os.Stderr, adminUsage) }
+	configFile := flags.String("config", "", "configuration file with the admin settings")
+	server := flags.String("server", "", "URL of the admin API, http://<admin.listen> by default")
+	token := flags.String("token", "", "token of the admin API, admin.token by default")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if *configFile != "" {
+		cfg, err := config.Load(*configFile)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 2
+		}
+		if *server == "" && cfg.Admin.Listen != "" {
+			*server = "http://" + cfg.Admin.Listen
+		}
+		if *token == "" {
+			*token = cfg.Admin.Token
+		}
+	}
+	if *server == "" {
+		fmt.Fprintln(os.Stderr, "no admin API address, pass -server or -config")
+		return 2
+	}
+
+	method, path, query, err := adminRequest(flags.Args())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n\n%v", err, adminUsage)
+		return 2
+	}
+
+	request, err := http.NewRequest(method, strings.TrimSuffix(*server, "/")+path+"?"+query.Encode(), nil)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	request.Header.Set("Authorization", "Bearer "+*token)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	response, err := client.Do(request)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer response.Body.Close()
+
+	io.Copy(os.Stdout, response.Body)
+	if response.StatusCode != http.StatusOK {
+		return 1
+	}
+
+	return 0
+}
+
+// adminRequest translates a command to the method, path and parameters of the admin API request
+func adminRequest(args []string) (string, string, url.Values, error) {
+	query := url.Values{}
+	if len(args) == 0 {
+		return "", "", nil, errors.New("missing command")
+	}
+
+	switch command := strings.Join(args[:min(2, len(args))], " "); {
+	case args[0] == "status" && len(args) == 1:
+		return http.MethodGet, "/status", query, nil
+	case args[0] == "config" && len(args) == 1:
+		return http.MethodGet, "/config", query, nil
+	case command == "cache show" || command == "cache flush":
+		cacheFlags := flag.NewFlagSet(command, flag.ContinueOnError)
+		cacheFlags.SetOutput(io.Discard)
+		subtree := cacheFlags.Bool("subtree", false, "")
+		if err := cacheFlags.Parse(args[2:]); err != nil || cacheFlags.NArg() > 1 {
+			return "", "", nil, fmt.Errorf("invalid arguments of %v", command)
+		}
+		name, err := dns.ToASCII(cacheFlags.Arg(0))
+		if err != nil {
+			return "", "", nil, err
+		}
+		query.Set("name", name)
+		query.Set("subtree", fmt.Sprint(*subtree))
+		if args[1] == "show" {
+			return http.MethodGet, "/cache", query, nil
+		}
+		return http.MethodDelete, "/cache", query, nil
+	case command == "filtering pause" && len(args) <= 3:
+		if len(args) == 3 {
+			query.Set("duration", args[2])
+		}
+		return http.MethodPost, "/filtering/pause", query, nil
+	case command == "filtering resume" && len(args) == 2:
+		return http.MethodPost, "/filtering/resume", query, nil
+	case args[0] == "reload" && len(args) == 1:
+		return http.MethodPost, "/reload", query, nil
+	case command == "reload zone" && len(args) == 3:
+		name, err := dns.ToASCII(args[2])
+		if err != nil {
+			return "", "", nil, err
+		}
+		query.Set("name", name)
+		return http.MethodPost, "/reload/zone", query, nil
+	case command == "reload lists" && len(args) == 2:
+		return http.MethodPost, "/reload/lists", query, nil
+	}
+
+	return "", "", nil, fmt.Errorf("unknown command %q", strings.Join(args, " "))
+}
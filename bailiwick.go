@@ -0,0 +1,101 @@
+package main
+
+import (
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+	resultcode "dns-client-go/result-code"
+)
+
+// sanitize strips the records a server authoritative for zone has no business giving in a response
+// about qname, which are how out-of-zone data gets into a resolver's cache (cache poisoning):
+//
+//   - answers not owned by qname or the names its CNAMEs point to, of another type than asked, or outside
+//     of zone
+//   - authority records outside of zone, and NS and SOA records for zones qname is not in
+//   - additional records other than the addresses of the name servers, or outside of zone
+//
+// A referral must move closer to qname: only the NS records of a zone below zone are kept, which sanitize
+// returns. A response that refers elsewhere, up to the root or sideways, is bogus and ok is false.
+func sanitize(response *dns.DnsPacket, qname dns.Name, qtype querytype.QueryType, zone dns.Name) (referral dns.Name, ok bool) {
+	response.Answers = relatedAnswers(response.Answers, qname, qtype, zone)
+
+	var authorities []dns.DnsRecord
+	delegated := false // the server gave NS records, whether they are kept or not
+	for _, record := range response.Authorities {
+		owner := record.Domain()
+		delegated = delegated || record.NS != nil
+		if !owner.IsSubdomainOf(zone) {
+			continue
+		}
+		if (record.NS != nil || record.SOA != nil) && !qname.IsSubdomainOf(owner) {
+			continue
+		}
+		if record.NS != nil && !owner.Equal(zone) && (referral == "" || owner.CountLabels() > referral.CountLabels()) {
+			referral = owner
+		}
+		authorities = append(authorities, record)
+	}
+
+	isReferral := response.Header.Rescode == resultcode.NOERROR && len(response.Answers) == 0 && !response.Header.AuthoritativeAnswer
+	if isReferral && referral != "" {
+		// Only the NS records of the zone referred to, the closest to qname
+		authorities = authorities[:0:0]
+		for _, record := range response.Authorities {
+			if record.NS != nil && record.Domain().Equal(referral) {
+				authorities = append(authorities, record)
+			}
+		}
+	} else {
+		referral = ""
+	}
+	response.Authorities = authorities
+
+	hosts := map[dns.Name]bool{}
+	for _, record := range append(append([]dns.DnsRecord(nil), response.Answers...), response.Authorities...) {
+		if record.NS != nil {
+			hosts[dns.Name(record.NS.Host()).Canonical()] = true
+		}
+	}
+	var resources []dns.DnsRecord
+	for _, record := range response.Resources {
+		owner := record.Domain()
+		if (record.A != nil || record.AAAA != nil) && hosts[owner.Canonical()] && owner.IsSubdomainOf(zone) {
+			resources = append(resources, record)
+		}
+	}
+	response.Resources = resources
+
+	return referral, !(isReferral && delegated && referral == "")
+}
+
+// relatedAnswers returns the answers owned by qname, or by the target of a CNAME kept, of the type asked
+// for or CNAME, and inside zone
+func relatedAnswers(answers []dns.DnsRecord, qname dns.Name, qtype querytype.QueryType, zone dns.Name) []dns.DnsRecord {
+	owners := map[dns.Name]bool{qname.Canonical(): true}
+	kept := make([]bool, len(answers))
+
+	for changed := true; changed; {
+		changed = false
+		for i, record := range answers {
+			owner := record.Domain().Canonical()
+			if kept[i] || !owners[owner] || !owner.IsSubdomainOf(zone) {
+				continue
+			}
+			if record.CNAME != nil {
+				owners[dns.Name(record.CNAME.Host()).Canonical()] = true
+			} else if record.Type() != qtype && qtype != querytype.ANY {
+				continue
+			}
+			kept[i], changed = true, true
+		}
+	}
+
+	var related []dns.DnsRecord
+	for i, record := range answers {
+		if kept[i] {
+			related = append(related, record)
+		}
+	}
+
+	return related
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"testing"
+
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSanitize(t *testing.T) {
+	testCases := []struct {
+		name          string
+		qname         dns.Name
+		zone          dns.Name
+		authoritative bool
+		answers       []dns.DnsRecord
+		authorities   []dns.DnsRecord
+		resources     []dns.DnsRecord
+		referral      dns.Name
+		ok            bool
+		expected      [3][]string // answers, authorities and resources kept
+	}{
+		{
+			name:  "out of zone glue and authority stripped",
+			qname: "www.example.com",
+			zone:  "com",
+			authorities: []dns.DnsRecord{
+				dns.NewNSRecord("example.com", "ns1.example.com", 300),
+				dns.NewNSRecord("example.com", "ns.evil.net", 300),
+				dns.NewNSRecord("evil.net", "ns.evil.net", 300),
+			},
+			resources: []dns.DnsRecord{
+				dns.NewARecord("ns1.example.com", "192.0.2.1", 300),
+				dns.NewARecord("ns.evil.net", "198.51.100.1", 300),
+				dns.NewARecord("www.example.com", "198.51.100.2", 300),
+			},
+			referral: "example.com",
+			ok:       true,
+			expected: [3][]string{nil, {
+				"example.com. 300 IN NS ns1.example.com.",
+				"example.com. 300 IN NS ns.evil.net.",
+			}, {
+				"ns1.example.com. 300 IN A 192.0.2.1",
+			}},
+		},
+		{
+			name:        "upward referral",
+			qname:       "www.example.com",
+			zone:        "example.com",
+			authorities: []dns.DnsRecord{dns.NewNSRecord("com", "a.gtld-servers.net", 300)},
+			resources:   []dns.DnsRecord{dns.NewARecord("a.gtld-servers.net", "192.0.2.30", 300)},
+			ok:          false,
+		},
+		{
+			name:        "sideways referral",
+			qname:       "www.example.com",
+			zone:        "example.com",
+			authorities: []dns.DnsRecord{dns.NewNSRecord("sub.example.com", "ns.sub.example.com", 300)},
+			resources:   []dns.DnsRecord{dns.NewARecord("ns.sub.example.com", "192.0.2.53", 300)},
+			ok:          false,
+		},
+		{
+			name:  "closest referral picked",
+			qname: "www.a.b.example.com",
+			zone:  "com",
+			authorities: []dns.DnsRecord{
+				dns.NewNSRecord("example.com", "ns.example.com", 300),
+				dns.NewNSRecord("b.example.com", "ns.b.example.com", 300),
+				dns.NewNSRecord("example.com", "ns2.example.com", 300),
+			},
+			resources: []dns.DnsRecord{
+				dns.NewARecord("ns.example.com", "192.0.2.1", 300),
+				dns.NewARecord("ns.b.example.com", "192.0.2.2", 300),
+			},
+			referral: "b.example.com",
+			ok:       true,
+			expected: [3][]string{nil, {
+				"b.example.com. 300 IN NS ns.b.example.com.",
+			}, {
+				"ns.b.example.com. 300 IN A 192.0.2.2",
+			}},
+		},
+		{
+			name:          "authoritative answer",
+			qname:         "www.example.com",
+			zone:          "example.com",
+			authoritative: true,
+			answers: []dns.DnsRecord{
+				dns.NewARecord("www.example.com", "192.0.2.10", 300),
+				dns.NewARecord("www.example.org", "198.51.100.10", 300),
+			},
+			authorities: []dns.DnsRecord{
+				dns.NewNSRecord("example.com", "ns.example.com", 300),
+				dns.NewSOARecord("example.org", "ns.example.org", "hm.example.org", 1, 1, 1, 1, 1, 300),
+			},
+			resources: []dns.DnsRecord{dns.NewARecord("ns.example.com", "192.0.2.1", 300)},
+			ok:        true,
+			expected: [3][]string{{
+				"www.example.com. 300 IN A 192.0.2.10",
+			}, {
+				"example.com. 300 IN NS ns.example.com.",
+			}, {
+				"ns.example.com. 300 IN A 192.0.2.1",
+			}},
+		},
+		{
+			name:  "mixed case and escaped owners",
+			qname: "WWW.Sub.example.com",
+			zone:  "Example.COM",
+			authorities: []dns.DnsRecord{
+				dns.NewNSRecord(`sub\.example.com`, "ns.evil.net", 300),
+				dns.NewNSRecord("sub.EXAMPLE.com", "NS.sub.example.com", 300),
+				dns.NewNSRecord(`www\.sub.example.com`, "ns.sub.example.com", 300),
+			},
+			resources: []dns.DnsRecord{
+				dns.NewARecord("ns.SUB.example.com", "192.0.2.53", 300),
+				dns.NewARecord(`ns\.sub.example.com`, "198.51.100.53", 300),
+			},
+			referral: "sub.EXAMPLE.com",
+			ok:       true,
+			expected: [3][]string{nil, {
+				"sub.EXAMPLE.com. 300 IN NS NS.sub.example.com.",
+			}, {
+				"ns.SUB.example.com. 300 IN A 192.0.2.53",
+			}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			response := dns.NewPacket()
+			response.Header.AuthoritativeAnswer = tc.authoritative
+			response.Answers = tc.answers
+			response.Authorities = tc.authorities
+			response.Resources = tc.resources
+
+			referral, ok := sanitize(response, tc.qname, querytype.A, tc.zone)
+			assert.Equal(t, tc.referral, referral)
+			assert.Equal(t, tc.ok, ok)
+			if !tc.ok {
+				return
+			}
+			assert.Equal(t, tc.expected[0], recordStrings(response.Answers))
+			assert.Equal(t, tc.expected[1], recordStrings(response.Authorities))
+			assert.Equal(t, tc.expected[2], recordStrings(response.Resources))
+		})
+	}
+}
+
+func TestRelatedAnswers(t *testing.T) {
+	testCases := []struct {
+		name     string
+		qname    dns.Name
+		qtype    querytype.QueryType
+		answers  []dns.DnsRecord
+		expected []string
+	}{
+		{
+			name:  "unrelated owner dropped",
+			qname: "www.example.com",
+			qtype: querytype.A,
+			answers: []dns.DnsRecord{
+				dns.NewARecord("www.example.com", "192.0.2.10", 300),
+				dns.NewARecord("mail.example.com", "192.0.2.25", 300),
+			},
+			expected: []string{"www.example.com. 300 IN A 192.0.2.10"},
+		},
+		{
+			name:  "wrong type dropped",
+			qname: "www.example.com",
+			qtype: querytype.A,
+			answers: []dns.DnsRecord{
+				dns.NewAAAARecord("www.example.com", "2001:db8::10", 300),
+				dns.NewARecord("www.example.com", "192.0.2.10", 300),
+			},
+			expected: []string{"www.example.com. 300 IN A 192.0.2.10"},
+		},
+		{
+			name:  "any type kept for qtype ANY",
+			qname: "www.example.com",
+			qtype: querytype.ANY,
+			answers: []dns.DnsRecord{
+				dns.NewAAAARecord("www.example.com", "2001:db8::10", 300),
+				dns.NewARecord("www.example.com", "192.0.2.10", 300),
+			},
+			expected: []string{"www.example.com. 300 IN AAAA 2001:db8::10", "www.example.com. 300 IN A 192.0.2.10"},
+		},
+		{
+			name:  "in-zone CNAME chain kept",
+			qname: "www.example.com",
+			qtype: querytype.A,
+			answers: []dns.DnsRecord{
+				dns.NewARecord("b.example.com", "192.0.2.10", 300),
+				dns.NewCNAMERecord("a.example.com", "b.example.com", 300),
+				dns.NewCNAMERecord("www.example.com", "a.example.com", 300),
+			},
+			expected: []string{
+				"b.example.com. 300 IN A 192.0.2.10",
+				"a.example.com. 300 IN CNAME b.example.com.",
+				"www.example.com. 300 IN CNAME a.example.com.",
+			},
+		},
+		{
+			name:  "CNAME target outside of zone dropped",
+			qname: "www.example.com",
+			qtype: querytype.A,
+			answers: []dns.DnsRecord{
+				dns.NewCNAMERecord("www.example.com", "cdn.example.net", 300),
+				dns.NewARecord("cdn.example.net", "198.51.100.10", 300),
+			},
+			expected: []string{"www.example.com. 300 IN CNAME cdn.example.net."},
+		},
+		{
+			name:  "mixed case and escaped owners",
+			qname: "WWW.Example.com",
+			qtype: querytype.A,
+			answers: []dns.DnsRecord{
+				dns.NewARecord("www.EXAMPLE.com", "192.0.2.10", 300),
+				dns.NewARecord(`www\.example.com`, "198.51.100.10", 300),
+			},
+			expected: []string{"www.EXAMPLE.com. 300 IN A 192.0.2.10"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, recordStrings(relatedAnswers(tc.answers, tc.qname, tc.qtype, "example.com")))
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package cache
+
+import (
+	"container/list"
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+	resultcode "dns-client-go/result-code"
+	"sync"
+	"time"
+)
+
+var now = time.Now
+
+// Key identifies a cached response. View separates answers that depend on where the question was
+// sent, such as the upstream resolvers of a client group.
+type Key struct {
+	Name  dns.Name
+	Qtype querytype.QueryType
+	View  string
+}
+
+func NewKey(name dns.Name, qtype querytype.QueryType, view string) Key {
+	return Key{Name: name.Canonical(), Qtype: qtype, View: view}
+}
+
+type entry struct {
+	key      Key
+	response *dns.DnsPacket
+	stored   time.Time
+	expires  time.Time
+}
+
+// Cache keeps resolved responses until their TTL runs out. When it is full the least recently used
+// response is evicted. Negative answers are kept for the SOA minimum (RFC 2308).
+type Cache struct {
+	mu          sync.Mutex
+	size        int
+	minTTL      uint32
+	maxTTL      uint32
+	negativeTTL uint32
+	entries     map[Key]*list.Element
+	order       *list.List // most recently used first
+	stats       Stats
+}
+
+// Stats counts the lookups and evictions of a cache
+type Stats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64 // responses removed to make room, expired ones are not counted
+	Entries   int
+}
+
+// New creates a cache holding up to size responses, a size of 0 disables caching. TTLs are raised to
+// minTTL and capped at maxTTL, negative answers at negativeTTL.
+func New(size int, minTTL, maxTTL, negativeTTL uint32) *Cache {
+	return &Cache{
+		size:        size,
+		minTTL:      minTTL,
+		maxTTL:      maxTTL,
+		negativeTTL: negativeTTL,
+		entries:     map[Key]*list.Element{},
+		order:       list.New(),
+	}
+}
+
+// Get returns a copy of the cached response with the TTLs reduced by the time it spent in the cache
+func (c *Cache) Get(key Key) (*dns.DnsPacket, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+
+	cached := element.Value.(*entry)
+	current := now()
+	if !current.Before(cached.expires) {
+		c.remove(element)
+		c.stats.Misses++
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	c.stats.Hits++
+
+	age := uint32(current.Sub(cached.stored) / time.Second)
+	response := *cached.response
+	response.Answers = aged(cached.response.Answers, age)
+	response.Authorities = aged(cached.response.Authorities, age)
+	response.Resources = aged(cached.response.Resources, age)
+
+	return &response, true
+}
+
+// Put stores a response for as long as its records are valid. Responses that carry no TTL, such as
+// SERVFAIL or negative answers without a SOA record, are not stored.
+func (c *Cache) Put(key Key, response *dns.DnsPacket) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.size <= 0 {
+		return
+	}
+
+	ttl, ok := c.ttl(response)
+	if !ok || ttl == 0 {
+		return
+	}
+
+	if element, ok := c.entries[key]; ok {
+		c.remove(element)
+	}
+
+	current := now()
+	c.entries[key] = c.order.PushFront(&entry{
+		key:      key,
+		response: response,
+		stored:   current,
+		expires:  current.Add(time.Duration(ttl) * time.Second),
+	})
+
+	c.evict()
+}
+
+// Resize changes the limits of the cache, keeping the responses that still fit
+func (c *Cache) Resize(size int, minTTL, maxTTL, negativeTTL uint32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.size, c.minTTL, c.maxTTL, c.negativeTTL = size, minTTL, maxTTL, negativeTTL
+	c.evict()
+}
+
+// Stats returns the hit, miss and eviction counts since the cache was created
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Entries = c.order.Len()
+	return stats
+}
+
+// EntryInfo describes a cached response
+type EntryInfo struct {
+	Key
+	Rcode   resultcode.ResultCode
+	Answers int
+	TTL     uint32 // seconds left until the response expires
+}
+
+// Entries lists the valid responses cached for name, or for name and the names below it with subtree,
+// most recently used first. An empty name lists the whole cache.
+func (c *Cache) Entries(name dns.Name, subtree bool) []EntryInfo {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	canonical := name.Canonical()
+	current := now()
+	var entries []EntryInfo
+	for element := c.order.Front(); element != nil; element = element.Next() {
+		cached := element.Value.(*entry)
+		if !cached.matches(canonical, subtree) || !current.Before(cached.expires) {
+			continue
+		}
+		entries = append(entries, EntryInfo{
+			Key:     cached.key,
+			Rcode:   cached.response.Header.Rescode,
+			Answers: len(cached.response.Answers),
+			TTL:     uint32((cached.expires.Sub(current) + time.Second - 1) / time.Second),
+		})
+	}
+
+	return entries
+}
+
+// Flush removes the responses cached for name, or for name and the names below it with subtree, and
+// returns how many were removed. An empty name flushes the whole cache.
+func (c *Cache) Flush(name dns.Name, subtree bool) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	canonical := name.Canonical()
+	removed := 0
+	for element := c.order.Front(); element != nil; {
+		next := element.Next()
+		if element.Value.(*entry).matches(canonical, subtree) {
+			c.remove(element)
+			removed++
+		}
+		element = next
+	}
+
+	return removed
+}
+
+// matches reports whether the entry is cached for name, which is in the form of Key names
+func (e *entry) matches(name dns.Name, subtree bool) bool {
+	if name.IsRoot() || e.key.Name == name {
+		return true
+	}
+
+	return subtree && e.key.Name.IsSubdomainOf(name)
+}
+
+// Len returns the number of cached responses, including expired ones not yet removed
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// evict removes the least recently used responses until the cache fits its size
+func (c *Cache) evict() {
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+		c.stats.Evictions++
+	}
+}
+
+func (c *Cache) remove(element *list.Element) {
+	delete(c.entries, element.Value.(*entry).key)
+	c.order.Remove(element)
+}
+
+// ttl returns how long a response may be cached: the lowest TTL of the answer for positive
+// responses, the SOA minimum bounded by the SOA TTL for negative ones
+func (c *Cache) ttl(response *dns.DnsPacket) (uint32, bool) {
+	rescode := response.Header.Rescode
+	if rescode != resultcode.NOERROR && rescode != resultcode.NXDOMAIN {
+		return 0, false
+	}
+
+	if rescode == resultcode.NOERROR && len(response.Answers) > 0 {
+		ttl := response.Answers[0].TTL()
+		for _, record := range response.Answers[1:] {
+			if record.TTL() < ttl {
+				ttl = record.TTL()
+			}
+		}
+		return clamp(ttl, c.minTTL, c.maxTTL), true
+	}
+
+	for _, record := range response.Authorities {
+		if record.SOA == nil {
+			continue
+		}
+		ttl := record.TTL()
+		if record.SOA.Minimum() < ttl {
+			ttl = record.SOA.Minimum()
+		}
+		return clamp(ttl, c.minTTL, c.negativeTTL), true
+	}
+
+	return 0, false
+}
+
+func clamp(ttl, min, max uint32) uint32 {
+	if ttl < min {
+		ttl = min
+	}
+	if ttl > max {
+		ttl = max
+	}
+
+	return ttl
+}
+
+// aged returns copies of the records with age subtracted from their TTLs
+func aged(records []dns.DnsRecord, age uint32) []dns.DnsRecord {
+	if records == nil {
+		return nil
+	}
+
+	result := make([]dns.DnsRecord, 0, len(records))
+	for _, record := range records {
+		ttl := record.TTL()
+		if ttl > age {
+			ttl -= age
+		} else {
+			ttl = 0
+		}
+		result = append(result, record.WithTTL(ttl))
+	}
+
+	return result
+}
@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+	resultcode "dns-client-go/result-code"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func answer(name dns.Name, ttl uint32) *dns.DnsPacket {
+	response := dns.NewPacket()
+	response.Answers = append(response.Answers, dns.NewARecord(name, "192.0.2.1", ttl))
+	return response
+}
+
+func setClock(t *testing.T, current *time.Time) {
+	now = func() time.Time { return *current }
+	t.Cleanup(func() { now = time.Now })
+}
+
+func TestCache_GetAgesRecords(t *testing.T) {
+	current := time.Unix(1700000000, 0)
+	setClock(t, &current)
+
+	c := New(10, 0, 86400, 3600)
+	key := NewKey("Example.COM.", querytype.A, "")
+	c.Put(key, answer("example.com", 300))
+
+	current = current.Add(100 * time.Second)
+	response, ok := c.Get(NewKey("example.com", querytype.A, ""))
+	assert.True(t, ok)
+	assert.Equal(t, uint32(200), response.Answers[0].TTL())
+
+	_, ok = c.Get(NewKey("example.com", querytype.A, "10.0.0.53:53"))
+	assert.False(t, ok, "other views do not share entries")
+
+	current = current.Add(200 * time.Second)
+	_, ok = c.Get(key)
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestCache_TTLBounds(t *testing.T) {
+	current := time.Unix(1700000000, 0)
+	setClock(t, &current)
+
+	c := New(10, 60, 600, 30)
+	c.Put(NewKey("short.example", querytype.A, ""), answer("short.example", 5))
+	c.Put(NewKey("long.example", querytype.A, ""), answer("long.example", 86400))
+
+	current = current.Add(59 * time.Second)
+	_, ok := c.Get(NewKey("short.example", querytype.A, ""))
+	assert.True(t, ok, "raised to the minimum TTL")
+
+	current = current.Add(600 * time.Second)
+	_, ok = c.Get(NewKey("long.example", querytype.A, ""))
+	assert.False(t, ok, "capped at the maximum TTL")
+
+	negative := dns.NewPacket()
+	negative.Header.Rescode = resultcode.NXDOMAIN
+	negative.Authorities = append(negative.Authorities,
+		dns.NewSOARecord("example", "ns.example", "admin.example", 1, 3600, 600, 86400, 300, 3600))
+	c.Put(NewKey("missing.example", querytype.A, ""), negative)
+
+	current = current.Add(29 * time.Second)
+	response, ok := c.Get(NewKey("missing.example", querytype.A, ""))
+	assert.True(t, ok)
+	assert.Equal(t, resultcode.NXDOMAIN, response.Header.Rescode)
+	current = current.Add(time.Second)
+	_, ok = c.Get(NewKey("missing.example", querytype.A, ""))
+	assert.False(t, ok, "capped at the negative TTL")
+
+	servfail := dns.NewPacket()
+	servfail.Header.Rescode = resultcode.SERVFAIL
+	c.Put(NewKey("broken.example", querytype.A, ""), servfail)
+	_, ok = c.Get(NewKey("broken.example", querytype.A, ""))
+	assert.False(t, ok)
+}
+
+func TestCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	c := New(2, 0, 86400, 3600)
+	c.Put(NewKey("a.example", querytype.A, ""), answer("a.example", 300))
+	c.Put(NewKey("b.example", querytype.A, ""), answer("b.example", 300))
+
+	_, ok := c.Get(NewKey("a.example", querytype.A, ""))
+	assert.True(t, ok)
+
+	c.Put(NewKey("c.example", querytype.A, ""), answer("c.example", 300))
+	assert.Equal(t, 2, c.Len())
+
+	_, ok = c.Get(NewKey("b.example", querytype.A, ""))
+	assert.False(t, ok)
+	_, ok = c.Get(NewKey("a.example", querytype.A, ""))
+	assert.True(t, ok)
+
+	c.Resize(1, 0, 86400, 3600)
+	assert.Equal(t, 1, c.Len())
+	_, ok = c.Get(NewKey("a.example", querytype.A, ""))
+	assert.True(t, ok, "the most recently used response is kept")
+	assert.Equal(t, Stats{Hits: 3, Misses: 1, Evictions: 2, Entries: 1}, c.Stats())
+
+	disabled := New(0, 0, 86400, 3600)
+	disabled.Put(NewKey("a.example", querytype.A, ""), answer("a.example", 300))
+	assert.Equal(t, 0, disabled.Len())
+}
+
+func TestCache_EntriesAndFlush(t *testing.T) {
+	current := time.Unix(1700000000, 0)
+	setClock(t, &current)
+
+	c := New(10, 0, 86400, 3600)
+	c.Put(NewKey("example.com", querytype.A, ""), answer("example.com", 300))
+	c.Put(NewKey("www.example.com", querytype.A, ""), answer("www.example.com", 60))
+	c.Put(NewKey("badexample.com", querytype.A, ""), answer("badexample.com", 300))
+
+	current = current.Add(1500 * time.Millisecond)
+	assert.Equal(t, []EntryInfo{
+		{Key: NewKey("www.example.com", querytype.A, ""), Rcode: resultcode.NOERROR, Answers: 1, TTL: 59},
+		{Key: NewKey("example.com", querytype.A, ""), Rcode: resultcode.NOERROR, Answers: 1, TTL: 299},
+	}, c.Entries("Example.com.", true))
+	assert.Len(t, c.Entries("example.com", false), 1)
+	assert.Len(t, c.Entries("", false), 3)
+
+	assert.Equal(t, 1, c.Flush("example.com", false))
+	assert.Equal(t, 1, c.Flush("example.com", true))
+	assert.Equal(t, 1, c.Len(), "names merely ending in the same characters are kept")
+	assert.Equal(t, 1, c.Flush("", false))
+	assert.Equal(t, 0, c.Len())
+}
@@ -0,0 +1,305 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ModeRecursive = "recursive"
+	ModeForward   = "forward"
+)
+
+// QNAME minimisation modes (RFC 9156). Relaxed falls back to the full name when servers answer minimised
+// queries with NXDOMAIN or an error, strict takes NXDOMAIN for an answer.
+const (
+	MinimiseOff     = "off"
+	MinimiseRelaxed = "relaxed"
+	MinimiseStrict  = "strict"
+)
+
+// Log levels, from the most to the least verbose
+const (
+	LevelDebug = "debug"
+	LevelInfo  = "info"
+	LevelWarn  = "warn"
+	LevelError = "error"
+)
+
+// Log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// What happens to the queries the server sheds
+const (
+	ShedRefuse = "refuse"
+	ShedDrop   = "drop" // UDP queries only, TCP queries are refused
+)
+
+// RootServers are the IPv4 addresses of a to m.root-servers.net, the default root hints
+var RootServers = []string{
+	"198.41.0.4", "170.247.170.2", "192.33.4.12", "199.7.91.13", "192.203.230.10", "192.5.5.241", "192.112.36.4",
+	"198.97.190.53", "192.36.148.17", "192.58.128.30", "193.0.14.129", "199.7.83.42", "202.12.27.33",
+}
+
+// Config is the server configuration. It is read from a YAML file and every setting can be overridden
+// with a command line flag.
+type Config struct {
+	Listen    Listen    `yaml:"listen"`
+	Resolver  Resolver  `yaml:"resolver"`
+	Cache     Cache     `yaml:"cache"`
+	Logging   Logging   `yaml:"logging"`
+	Metrics   Metrics   `yaml:"metrics"`
+	Dnstap    Dnstap    `yaml:"dnstap"`
+	Admin     Admin     `yaml:"admin"`
+	ACL       ACL       `yaml:"acl"`
+	RateLimit RateLimit `yaml:"rate_limit"`
+	Limits    Limits    `yaml:"limits"`
+	TSIGKeys  string    `yaml:"tsig_keys"` // file with one "name algorithm base64-secret" per line
+	Zones     Zones     `yaml:"zones"`
+	Filtering Filtering `yaml:"filtering"`
+	Static    Static    `yaml:"static"`
+}
+
+// Listen holds the addresses (host:port) the server accepts queries on
+type Listen struct {
+	UDP            []string      `yaml:"udp"`
+	TCP            []string      `yaml:"tcp"`
+	UDPSize        int           `yaml:"udp_size"` // responses larger than this are truncated
+	TCPIdleTimeout time.Duration `yaml:"tcp_idle_timeout"`
+	// ShutdownTimeout bounds how long queries in flight are waited for on shutdown
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+}
+
+// Resolver controls how names outside of the local zones are answered: resolved from the root
+// servers or forwarded to upstream resolvers
+type Resolver struct {
+	Mode      string        `yaml:"mode"`
+	Upstreams []string      `yaml:"upstreams"` // host[:port], tried in order
+	RootHints []string      `yaml:"root_hints"`
+	Timeout   time.Duration `yaml:"timeout"` // of a single query to an upstream or authoritative server
+
+	QnameMinimisation string `yaml:"qname_minimisation"` // off, relaxed or strict
+	CaseRandomization bool   `yaml:"case_randomization"` // randomize the case of query names and check the responses echo it
+}
+
+// Cache sizes the response cache, TTLs are in seconds
+type Cache struct {
+	Size        int    `yaml:"size"` // responses, 0 disables the cache
+	MinTTL      uint32 `yaml:"min_ttl"`
+	MaxTTL      uint32 `yaml:"max_ttl"`
+	NegativeTTL uint32 `yaml:"negative_ttl"` // cap for NXDOMAIN and NODATA answers
+}
+
+type Logging struct {
+	Level    string   `yaml:"level"`
+	Format   string   `yaml:"format"` // text or json
+	QueryLog QueryLog `yaml:"query_log"`
+}
+
+// QueryLog writes one JSON line per client query
+type QueryLog struct {
+	File string `yaml:"file"` // "-" for standard output, empty disables the query log
+	// AnonymizeClientIP zeroes the last octet of IPv4 clients and all but the first 48 bits of IPv6 clients
+	AnonymizeClientIP bool `yaml:"anonymize_client_ip"`
+}
+
+// Metrics configures the HTTP endpoint Prometheus scrapes at /metrics
+type Metrics struct {
+	Listen string `yaml:"listen"` // host:port, empty disables the endpoint
+}
+
+// Dnstap captures client queries and the queries of recursive resolution with their responses
+type Dnstap struct {
+	Output   string `yaml:"output"`   // a file, or unix: and the path of a collector's socket, empty disables dnstap
+	Identity string `yaml:"identity"` // sent with every message, the host name when empty
+}
+
+// Admin configures the HTTP API operators use to control the running server. It only listens on
+// loopback addresses and every request must carry the token.
+type Admin struct {
+	Listen string `yaml:"listen"` // host:port, empty disables the API
+	Token  string `yaml:"token"`
+}
+
+// ACL restricts which clients (by CIDR) are served. Empty lists allow everyone, except for refuse and
+// allow_transfer.
+type ACL struct {
+	Refuse         []string `yaml:"refuse"` // refused whatever they ask, before the other lists are checked
+	AllowQuery     []string `yaml:"allow_query"`
+	AllowRecursion []string `yaml:"allow_recursion"` // other clients only get answers from local data
+	AllowTransfer  []string `yaml:"allow_transfer"`  // may transfer every primary zone besides its secondaries
+}
+
+// RateLimit limits the UDP responses sent to a client network (response rate limiting), so that
+// spoofed queries cannot turn the server into an amplifier. Rates are responses per second.
+type RateLimit struct {
+	ResponsesPerSecond int           `yaml:"responses_per_second"` // 0 disables rate limiting
+	NXDomainsPerSecond int           `yaml:"nxdomains_per_second"` // responses_per_second when 0
+	ErrorsPerSecond    int           `yaml:"errors_per_second"`    // responses_per_second when 0
+	Window             time.Duration `yaml:"window"`
+	Slip               int           `yaml:"slip"` // every slip-th limited response is sent truncated, 0 drops them all
+	IPv4PrefixLength   int           `yaml:"ipv4_prefix_length"`
+	IPv6PrefixLength   int           `yaml:"ipv6_prefix_length"`
+	LogOnly            bool          `yaml:"log_only"`
+}
+
+// Limits protects the server from clients sending more queries than it can answer. Queries over the
+// limits are shed with the shed action.
+type Limits struct {
+	ClientQueriesPerSecond int    `yaml:"client_queries_per_second"` // per client address, 0 disables the limit
+	ClientBurst            int    `yaml:"client_burst"`              // queries a client may send at once, client_queries_per_second when 0
+	MaxInflight            int    `yaml:"max_inflight"`              // above, queries that need resolution are shed, 0 disables the cap
+	ShedAction             string `yaml:"shed_action"`               // refuse or drop
+}
+
+type Zones struct {
+	Primary   []PrimaryZone   `yaml:"primary"`
+	Secondary []SecondaryZone `yaml:"secondary"`
+}
+
+type PrimaryZone struct {
+	Name        string   `yaml:"name"`
+	File        string   `yaml:"file"`
+	AlsoNotify  []string `yaml:"also_notify"`  // secondaries, host[:port]
+	AllowUpdate []string `yaml:"allow_update"` // networks allowed to send dynamic updates
+	UpdateKeys  []string `yaml:"update_keys"`  // TSIG keys allowed to sign dynamic updates
+	Key         string   `yaml:"key"`          // TSIG key for transfers and NOTIFY
+}
+
+type SecondaryZone struct {
+	Name      string   `yaml:"name"`
+	Primaries []string `yaml:"primaries"` // host[:port]
+	Key       string   `yaml:"key"`
+}
+
+// Policy is the filtering policy of a client group
+type Policy struct {
+	Blocklists    []string `yaml:"blocklists"`
+	Allowlists    []string `yaml:"allowlists"`
+	BlockResponse string   `yaml:"block_response"` // nxdomain, refused, null or an IP address
+	SafeSearch    bool     `yaml:"safe_search"`
+}
+
+// Filtering holds the policy of clients outside of every group and the client groups
+type Filtering struct {
+	Policy `yaml:",inline"`
+	Groups []Group `yaml:"groups"`
+}
+
+type Group struct {
+	Name      string   `yaml:"name"`
+	Networks  []string `yaml:"networks"`
+	Upstreams []string `yaml:"upstreams"` // forward the queries of the group instead of using the resolver settings
+	Policy    `yaml:",inline"`
+}
+
+type Static struct {
+	Records  []string `yaml:"records"` // "name [ttl] type data"
+	Hosts    string   `yaml:"hosts"`   // file in /etc/hosts format
+	HostsTTL uint32   `yaml:"hosts_ttl"`
+}
+
+// Default returns the configuration used for settings missing from the file and the flags
+func Default() *Config {
+	return &Config{
+		Listen: Listen{
+			UDP:             []string{"0.0.0.0:2053"},
+			TCP:             []string{"0.0.0.0:2053"},
+			UDPSize:         512,
+			TCPIdleTimeout:  10 * time.Second,
+			ShutdownTimeout: 5 * time.Second,
+		},
+		Resolver: Resolver{
+			Mode:      ModeRecursive,
+			RootHints: append([]string(nil), RootServers...),
+			Timeout:   5 * time.Second,
+
+			QnameMinimisation: MinimiseRelaxed,
+			CaseRandomization: true,
+		},
+		Cache: Cache{
+			Size:        10000,
+			MaxTTL:      86400,
+			NegativeTTL: 3600,
+		},
+		Logging:   Logging{Level: LevelInfo, Format: FormatText},
+		RateLimit: RateLimit{Window: 15 * time.Second, Slip: 2, IPv4PrefixLength: 24, IPv6PrefixLength: 56},
+		Limits:    Limits{ShedAction: ShedRefuse},
+		Filtering: Filtering{Policy: Policy{BlockResponse: "null"}},
+		Static:    Static{HostsTTL: 300},
+	}
+}
+
+// Load reads a YAML configuration file on top of the defaults. Unknown settings are rejected so that
+// typos do not go unnoticed.
+func Load(path string) (*Config, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	config := Default()
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return config, nil
+}
+
+// Primary returns the primary zone called name, adding it when it is not configured yet
+func (c *Config) Primary(name string) *PrimaryZone {
+	for i := range c.Zones.Primary {
+		if sameZone(c.Zones.Primary[i].Name, name) {
+			return &c.Zones.Primary[i]
+		}
+	}
+
+	c.Zones.Primary = append(c.Zones.Primary, PrimaryZone{Name: name})
+	return &c.Zones.Primary[len(c.Zones.Primary)-1]
+}
+
+// Secondary returns the secondary zone called name, adding it when it is not configured yet
+func (c *Config) Secondary(name string) *SecondaryZone {
+	for i := range c.Zones.Secondary {
+		if sameZone(c.Zones.Secondary[i].Name, name) {
+			return &c.Zones.Secondary[i]
+		}
+	}
+
+	c.Zones.Secondary = append(c.Zones.Secondary, SecondaryZone{Name: name})
+	return &c.Zones.Secondary[len(c.Zones.Secondary)-1]
+}
+
+// ASCIIZoneNames converts the names of the primary and secondary zones to A-labels, the form queries and
+// transfers use. Names that cannot be converted are left for Validate to report.
+func (c *Config) ASCIIZoneNames() {
+	for i := range c.Zones.Primary {
+		c.Zones.Primary[i].Name = asciiName(c.Zones.Primary[i].Name)
+	}
+	for i := range c.Zones.Secondary {
+		c.Zones.Secondary[i].Name = asciiName(c.Zones.Secondary[i].Name)
+	}
+}
+
+// Group returns the client group called name, adding it when it is not configured yet
+func (c *Config) Group(name string) *Group {
+	for i := range c.Filtering.Groups {
+		if c.Filtering.Groups[i].Name == name {
+			return &c.Filtering.Groups[i]
+		}
+	}
+
+	c.Filtering.Groups = append(c.Filtering.Groups, Group{Name: name})
+	return &c.Filtering.Groups[len(c.Filtering.Groups)-1]
+}
@@ -0,0 +1,163 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "dns.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `
+listen:
+  udp: ["127.0.0.1:53"]
+  udp_size: 1232
+resolver:
+  mode: forward
+  upstreams: [9.9.9.9, "[2620:fe::fe]:53"]
+  timeout: 2s
+cache:
+  size: 500
+logging:
+  level: debug
+  query_log:
+    file: queries.log
+    anonymize_client_ip: true
+acl:
+  allow_recursion: [10.0.0.0/8]
+zones:
+  primary:
+    - name: example.com
+      file: example.com.zone
+      also_notify: [192.0.2.2]
+filtering:
+  blocklists: [hosts.txt]
+  block_response: nxdomain
+  groups:
+    - name: kids
+      networks: [192.168.2.0/24]
+      safe_search: true
+static:
+  records: ["db.staging A 10.0.0.5"]
+`)
+
+	config, err := Load(path)
+	assert.NoError(t, err)
+	assert.NoError(t, config.Validate())
+
+	assert.Equal(t, []string{"127.0.0.1:53"}, config.Listen.UDP)
+	assert.Equal(t, []string{"0.0.0.0:2053"}, config.Listen.TCP, "settings missing from the file keep their default")
+	assert.Equal(t, 1232, config.Listen.UDPSize)
+	assert.Equal(t, 2*time.Second, config.Resolver.Timeout)
+	assert.Equal(t, 500, config.Cache.Size)
+	assert.Equal(t, uint32(86400), config.Cache.MaxTTL)
+	assert.Equal(t, "nxdomain", config.Filtering.BlockResponse)
+	assert.Equal(t, []string{"hosts.txt"}, config.Filtering.Blocklists)
+	assert.Equal(t, QueryLog{File: "queries.log", AnonymizeClientIP: true}, config.Logging.QueryLog)
+	assert.Equal(t, FormatText, config.Logging.Format)
+	assert.True(t, config.Filtering.Groups[0].SafeSearch)
+	assert.Equal(t, "example.com.zone", config.Primary("example.com.").File)
+	assert.Len(t, config.Zones.Primary, 1)
+}
+
+func TestLoad_RejectsUnknownSettings(t *testing.T) {
+	_, err := Load(writeConfig(t, "resolver:\n  mdoe: forward\n"))
+	assert.ErrorContains(t, err, "field mdoe not found")
+
+	_, err = Load(writeConfig(t, "resolver:\n  timeout: soon\n"))
+	assert.Error(t, err)
+
+	config, err := Load(writeConfig(t, ""))
+	assert.NoError(t, err)
+	assert.Equal(t, Default(), config)
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, Default().Validate())
+
+	config := Default()
+	config.Listen.UDP = []string{"0.0.0.0"}
+	config.Resolver.Mode = "forward"
+	config.Resolver.RootHints = []string{"a.root-servers.net"}
+	config.Resolver.QnameMinimisation = "on"
+	config.Cache.MinTTL = 90000
+	config.Logging.Level = "verbose"
+	config.Logging.Format = "xml"
+	config.Dnstap.Output = "unix:"
+	config.Admin.Listen = "0.0.0.0:8053"
+	config.ACL.AllowQuery = []string{"10.0.0.0"}
+	config.ACL.AllowTransfer = []string{"192.0.2.300/32"}
+	config.RateLimit.Slip = 20
+	config.Limits.ShedAction = "servfail"
+	config.Primary("example.com")
+	config.Secondary("Example.com.").Primaries = []string{"192.0.2.1"}
+	config.Filtering.BlockResponse = "drop"
+	config.Group("kids")
+	config.Static.Records = []string{"db.staging A"}
+
+	err := config.Validate()
+	assert.Error(t, err)
+	problems := err.(*ValidationError).Problems
+	assert.Equal(t, []string{
+		`listen.udp: "0.0.0.0" is not a host:port address`,
+		"resolver.upstreams: forward mode needs at least one upstream resolver",
+		`resolver.root_hints: "a.root-servers.net" is not an IP address`,
+		`resolver.qname_minimisation: "on" is not one of off, relaxed or strict`,
+		"cache.min_ttl: 90000 is above cache.max_ttl 86400",
+		`logging.level: "verbose" is not one of debug, info, warn or error`,
+		`logging.format: "xml" is not one of text or json`,
+		"dnstap.output: no socket path after unix:",
+		`admin.listen: "0.0.0.0:8053" is not a loopback address`,
+		"admin.token: the admin API needs a token",
+		`acl.allow_query: "10.0.0.0" is not a network in CIDR notation`,
+		`acl.allow_transfer: "192.0.2.300/32" is not a network in CIDR notation`,
+		"rate_limit.slip: 20 is not between 0 and 10",
+		`limits.shed_action: "servfail" is not one of refuse or drop`,
+		"zones.primary example.com: no zone file",
+		"zones.secondary Example.com.: zone is configured twice",
+		`filtering block_response: invalid block response "drop", expected nxdomain, refused, null or an IP address`,
+		"filtering.groups kids: no client networks",
+	}, problems[:len(problems)-1])
+	assert.Contains(t, problems[len(problems)-1], `static.records: "db.staging A"`)
+	assert.Contains(t, err.Error(), "invalid configuration:\n  listen.udp")
+}
+
+func TestConfig_ASCIIZoneNames(t *testing.T) {
+	config := Default()
+	config.Primary("bücher.de").File = "bücher.zone"
+	config.Secondary("例え.テスト").Primaries = []string{"192.0.2.1"}
+	assert.Same(t, config.Primary("bücher.de"), config.Primary("XN--BCHER-KVA.de."))
+
+	config.ASCIIZoneNames()
+	assert.Equal(t, "xn--bcher-kva.de", config.Zones.Primary[0].Name)
+	assert.Equal(t, "xn--r8jz45g.xn--zckzah", config.Zones.Secondary[0].Name)
+	assert.NoError(t, config.Validate())
+
+	config.Primary("-bücher.de").File = "hyphen.zone"
+	config.ASCIIZoneNames()
+	assert.Equal(t, "-bücher.de", config.Zones.Primary[1].Name)
+	err := config.Validate()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "zones.primary -bücher.de: invalid label")
+}
+
+func TestConfig_OpenResolver(t *testing.T) {
+	config := Default()
+	config.Listen.UDP = []string{"0.0.0.0:2053"}
+	assert.True(t, config.OpenResolver())
+
+	config.ACL.AllowRecursion = []string{"192.168.0.0/16"}
+	assert.False(t, config.OpenResolver())
+
+	config = Default()
+	config.Listen.UDP = []string{"127.0.0.1:2053", "[::1]:2053"}
+	assert.False(t, config.OpenResolver())
+}
@@ -0,0 +1,311 @@
+package config
+
+import (
+	"dns-client-go/dns"
+	"dns-client-go/filter"
+	"dns-client-go/zone"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
+
+// Validate checks the configuration and reports all problems at once, naming the offending setting
+func (c *Config) Validate() error {
+	v := &validator{}
+
+	if len(c.Listen.UDP) == 0 && len(c.Listen.TCP) == 0 {
+		v.problem("listen: no UDP or TCP address to listen on")
+	}
+	for _, address := range c.Listen.UDP {
+		v.listenAddress("listen.udp", address)
+	}
+	for _, address := range c.Listen.TCP {
+		v.listenAddress("listen.tcp", address)
+	}
+	if c.Listen.UDPSize < 512 || c.Listen.UDPSize > 65535 {
+		v.problem("listen.udp_size: %d is outside of 512 to 65535", c.Listen.UDPSize)
+	}
+	if c.Listen.TCPIdleTimeout <= 0 {
+		v.problem("listen.tcp_idle_timeout: must be positive")
+	}
+	if c.Listen.ShutdownTimeout < 0 {
+		v.problem("listen.shutdown_timeout: must not be negative")
+	}
+
+	switch c.Resolver.Mode {
+	case ModeRecursive:
+		if len(c.Resolver.RootHints) == 0 {
+			v.problem("resolver.root_hints: recursive mode needs at least one root server")
+		}
+		if len(c.Resolver.Upstreams) > 0 {
+			v.problem("resolver.upstreams: only used in %v mode, the mode is %v", ModeForward, ModeRecursive)
+		}
+	case ModeForward:
+		if len(c.Resolver.Upstreams) == 0 {
+			v.problem("resolver.upstreams: forward mode needs at least one upstream resolver")
+		}
+	default:
+		v.problem("resolver.mode: %q is neither %v nor %v", c.Resolver.Mode, ModeRecursive, ModeForward)
+	}
+	for _, upstream := range c.Resolver.Upstreams {
+		v.serverAddress("resolver.upstreams", upstream)
+	}
+	for _, hint := range c.Resolver.RootHints {
+		if net.ParseIP(hint) == nil {
+			v.problem("resolver.root_hints: %q is not an IP address", hint)
+		}
+	}
+	if c.Resolver.Timeout <= 0 {
+		v.problem("resolver.timeout: must be positive")
+	}
+	switch c.Resolver.QnameMinimisation {
+	case MinimiseOff, MinimiseRelaxed, MinimiseStrict:
+	default:
+		v.problem("resolver.qname_minimisation: %q is not one of off, relaxed or strict", c.Resolver.QnameMinimisation)
+	}
+
+	if c.Cache.Size < 0 {
+		v.problem("cache.size: %d is negative", c.Cache.Size)
+	}
+	if c.Cache.MinTTL > c.Cache.MaxTTL {
+		v.problem("cache.min_ttl: %d is above cache.max_ttl %d", c.Cache.MinTTL, c.Cache.MaxTTL)
+	}
+
+	switch c.Logging.Level {
+	case LevelDebug, LevelInfo, LevelWarn, LevelError:
+	default:
+		v.problem("logging.level: %q is not one of debug, info, warn or error", c.Logging.Level)
+	}
+	if c.Logging.Format != FormatText && c.Logging.Format != FormatJSON {
+		v.problem("logging.format: %q is not one of text or json", c.Logging.Format)
+	}
+
+	if c.Metrics.Listen != "" {
+		v.listenAddress("metrics.listen", c.Metrics.Listen)
+	}
+
+	if c.Dnstap.Output == "unix:" {
+		v.problem("dnstap.output: no socket path after unix:")
+	}
+
+	if c.Admin.Listen != "" {
+		v.listenAddress("admin.listen", c.Admin.Listen)
+		if host, _, err := net.SplitHostPort(c.Admin.Listen); err == nil && !isLoopback(host) {
+			v.problem("admin.listen: %q is not a loopback address", c.Admin.Listen)
+		}
+		if c.Admin.Token == "" {
+			v.problem("admin.token: the admin API needs a token")
+		}
+	}
+
+	for _, network := range c.ACL.Refuse {
+		v.network("acl.refuse", network)
+	}
+	for _, network := range c.ACL.AllowQuery {
+		v.network("acl.allow_query", network)
+	}
+	for _, network := range c.ACL.AllowRecursion {
+		v.network("acl.allow_recursion", network)
+	}
+	for _, network := range c.ACL.AllowTransfer {
+		v.network("acl.allow_transfer", network)
+	}
+
+	if c.RateLimit.ResponsesPerSecond < 0 || c.RateLimit.NXDomainsPerSecond < 0 || c.RateLimit.ErrorsPerSecond < 0 {
+		v.problem("rate_limit: rates must not be negative")
+	}
+	if c.RateLimit.ResponsesPerSecond > 0 && c.RateLimit.Window < time.Second {
+		v.problem("rate_limit.window: %v is shorter than a second", c.RateLimit.Window)
+	}
+	if c.RateLimit.Slip < 0 || c.RateLimit.Slip > 10 {
+		v.problem("rate_limit.slip: %d is not between 0 and 10", c.RateLimit.Slip)
+	}
+	if c.RateLimit.IPv4PrefixLength < 0 || c.RateLimit.IPv4PrefixLength > 32 {
+		v.problem("rate_limit.ipv4_prefix_length: %d is not between 0 and 32", c.RateLimit.IPv4PrefixLength)
+	}
+	if c.RateLimit.IPv6PrefixLength < 0 || c.RateLimit.IPv6PrefixLength > 128 {
+		v.problem("rate_limit.ipv6_prefix_length: %d is not between 0 and 128", c.RateLimit.IPv6PrefixLength)
+	}
+
+	if c.Limits.ClientQueriesPerSecond < 0 || c.Limits.ClientBurst < 0 || c.Limits.MaxInflight < 0 {
+		v.problem("limits: limits must not be negative")
+	}
+	if c.Limits.ShedAction != ShedRefuse && c.Limits.ShedAction != ShedDrop {
+		v.problem("limits.shed_action: %q is not one of refuse or drop", c.Limits.ShedAction)
+	}
+
+	zones := map[dns.Name]bool{}
+	for _, primary := range c.Zones.Primary {
+		v.zoneName("zones.primary", primary.Name, zones)
+		if primary.File == "" {
+			v.problem("zones.primary %v: no zone file", primary.Name)
+		}
+		for _, secondary := range primary.AlsoNotify {
+			v.serverAddress(fmt.Sprintf("zones.primary %v also_notify", primary.Name), secondary)
+		}
+		for _, network := range primary.AllowUpdate {
+			v.network(fmt.Sprintf("zones.primary %v allow_update", primary.Name), network)
+		}
+	}
+	for _, secondary := range c.Zones.Secondary {
+		v.zoneName("zones.secondary", secondary.Name, zones)
+		if len(secondary.Primaries) == 0 {
+			v.problem("zones.secondary %v: no primaries", secondary.Name)
+		}
+		for _, primary := range secondary.Primaries {
+			v.serverAddress(fmt.Sprintf("zones.secondary %v primaries", secondary.Name), primary)
+		}
+	}
+
+	v.policy("filtering", c.Filtering.Policy)
+	groups := map[string]bool{}
+	for _, group := range c.Filtering.Groups {
+		setting := fmt.Sprintf("filtering.groups %v", group.Name)
+		if group.Name == "" {
+			v.problem("filtering.groups: group without a name")
+		} else if groups[group.Name] {
+			v.problem("%v: defined twice", setting)
+		}
+		groups[group.Name] = true
+
+		if len(group.Networks) == 0 {
+			v.problem("%v: no client networks", setting)
+		}
+		for _, network := range group.Networks {
+			v.network(setting+" networks", network)
+		}
+		for _, upstream := range group.Upstreams {
+			v.serverAddress(setting+" upstreams", upstream)
+		}
+		v.policy(setting, group.Policy)
+	}
+
+	for _, record := range c.Static.Records {
+		if _, err := zone.ParseRecord(record); err != nil {
+			v.problem("static.records: %q: %v", record, err)
+		}
+	}
+
+	if len(v.problems) > 0 {
+		return &ValidationError{Problems: v.problems}
+	}
+
+	return nil
+}
+
+type validator struct {
+	problems []string
+}
+
+func (v *validator) problem(format string, args ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, args...))
+}
+
+func (v *validator) listenAddress(setting string, address string) {
+	_, port, err := net.SplitHostPort(address)
+	if err != nil {
+		v.problem("%v: %q is not a host:port address", setting, address)
+		return
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		v.problem("%v: %q has an invalid port", setting, address)
+	}
+}
+
+// isLoopback reports whether host is localhost or a loopback IP
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// OpenResolver reports whether every client may ask for recursion over UDP on an address that is not a
+// loopback one. Such an open resolver can be abused to amplify attacks.
+func (c *Config) OpenResolver() bool {
+	if len(c.ACL.AllowQuery) > 0 || len(c.ACL.AllowRecursion) > 0 {
+		return false
+	}
+
+	for _, address := range c.Listen.UDP {
+		if host, _, err := net.SplitHostPort(address); err == nil && !isLoopback(host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// serverAddress checks the address of a server, the port may be left out
+func (v *validator) serverAddress(setting string, address string) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, "53")
+	}
+
+	host, _, _ := net.SplitHostPort(address)
+	if host == "" {
+		v.problem("%v: %q has no host", setting, address)
+		return
+	}
+	v.listenAddress(setting, address)
+}
+
+func (v *validator) network(setting string, network string) {
+	if _, _, err := net.ParseCIDR(network); err != nil {
+		v.problem("%v: %q is not a network in CIDR notation", setting, network)
+	}
+}
+
+func (v *validator) zoneName(setting string, name string, seen map[dns.Name]bool) {
+	if name == "" {
+		v.problem("%v: zone without a name", setting)
+		return
+	}
+
+	ascii, err := dns.ToASCII(name)
+	if err != nil {
+		v.problem("%v %v: %v", setting, name, err)
+		return
+	}
+
+	canonical := dns.Name(ascii).Canonical()
+	if seen[canonical] {
+		v.problem("%v %v: zone is configured twice", setting, name)
+	}
+	seen[canonical] = true
+}
+
+func (v *validator) policy(setting string, policy Policy) {
+	if policy.BlockResponse == "" {
+		return
+	}
+	if _, err := filter.ParseAction(policy.BlockResponse); err != nil {
+		v.problem("%v block_response: %v", setting, err)
+	}
+}
+
+func sameZone(a string, b string) bool {
+	return dns.Name(asciiName(a)).Equal(dns.Name(asciiName(b)))
+}
+
+// asciiName returns name with its Unicode labels as A-labels, or as it is when it is not a valid name
+func asciiName(name string) string {
+	if ascii, err := dns.ToASCII(name); err == nil {
+		return ascii
+	}
+
+	return name
+}
@@ -62,8 +62,8 @@ func TestDnsHeader_Read(t *testing.T) {
 		t.Error("expected header.z to be false, but got true")
 	}
 
-	if header.RecursionAvailable != false {
-		t.Error("expected header.recursion_available to be false, but got true")
+	if header.RecursionAvailable != true {
+		t.Error("expected header.recursion_available to be true, but got false")
 	}
 
 	if header.Questions != 1 {
@@ -82,3 +82,25 @@ func TestDnsHeader_Read(t *testing.T) {
 		t.Errorf("expected header.resource_entries to be 0, but got %d", header.ResourceEntries)
 	}
 }
+
+func TestDnsHeader_WriteRead(t *testing.T) {
+	header := NewHeader()
+	header.ID = 0xbeef
+	header.Response = true
+	header.Opcode = 4
+	header.AuthoritativeAnswer = true
+	header.TruncatedMessage = true
+	header.RecursionDesired = true
+	header.Rescode = resultcode.REFUSED
+
+	buffer := bytepacketbuffer.NewPacketBuffer()
+	header.Write(&buffer)
+	buffer.SetPosition(0)
+
+	parsed := &DnsHeader{}
+	parsed.Read(&buffer)
+
+	if *parsed != *header {
+		t.Errorf("expected header to survive a write/read round trip, got %+v, want %+v", parsed, header)
+	}
+}
@@ -4,7 +4,6 @@ import (
 	bytepacketbuffer "dns-client-go/packetbuffer"
 	queryType "dns-client-go/query-type"
 	"net"
-	"strings"
 )
 
 type DnsPacket struct {
@@ -51,7 +50,7 @@ func (dp *DnsPacket) FromBuffer(buffer *bytepacketbuffer.PacketBuffer) *DnsPacke
 	for i := 0; i < int(packet.Header.ResourceEntries); i++ {
 		record := DnsRecord{}
 		record.Read(buffer)
-		packet.Authorities = append(packet.Authorities, record)
+		packet.Resources = append(packet.Resources, record)
 	}
 
 	return packet
@@ -95,10 +94,63 @@ func (dp *DnsPacket) GetRandomA() net.IP {
 	return nil
 }
 
-func (dp *DnsPacket) GetNS(qname string) []NSRecord {
+// GetAddresses returns the addresses of the A records of the answer section
+func (dp *DnsPacket) GetAddresses() []net.IP {
+	var addresses []net.IP
+	for _, record := range dp.Answers {
+		if record.A != nil {
+			addresses = append(addresses, net.ParseIP(record.A.addr))
+		}
+	}
+
+	return addresses
+}
+
+// UPDATE messages (RFC 2136) reuse the four sections as zone, prerequisite, update and additional data.
+// The following accessors name them accordingly.
+
+// UpdateZone returns the zone section of an UPDATE message, which names the zone being updated
+func (dp *DnsPacket) UpdateZone() *DnsQuestion {
+	if len(dp.Question) != 1 {
+		return nil
+	}
+
+	return &dp.Question[0]
+}
+
+// Prerequisites returns the prerequisite section of an UPDATE message
+func (dp *DnsPacket) Prerequisites() []DnsRecord {
+	return dp.Answers
+}
+
+// Updates returns the update section of an UPDATE message
+func (dp *DnsPacket) Updates() []DnsRecord {
+	return dp.Authorities
+}
+
+// GetSOA returns the first SOA record of the answer section, falling back to the authority section
+// where it is placed for negative answers and IXFR requests.
+func (dp *DnsPacket) GetSOA() *SOARecord {
+	for _, record := range dp.Answers {
+		if record.SOA != nil {
+			return record.SOA
+		}
+	}
+
+	for _, record := range dp.Authorities {
+		if record.SOA != nil {
+			return record.SOA
+		}
+	}
+
+	return nil
+}
+
+// GetNS returns the NS records of the authority section for zones qname is in
+func (dp *DnsPacket) GetNS(qname Name) []NSRecord {
 	var nsRecords []NSRecord
 	for _, record := range dp.Authorities {
-		if record.NS != nil && strings.HasSuffix(qname, record.NS.domain) {
+		if record.NS != nil && qname.IsSubdomainOf(record.NS.domain) {
 			nsRecords = append(nsRecords, *record.NS)
 		}
 	}
@@ -106,24 +158,27 @@ func (dp *DnsPacket) GetNS(qname string) []NSRecord {
 	return nsRecords
 }
 
-func (dp *DnsPacket) GetResolvedNS(qname string) net.IP {
-	nsRecords := dp.GetNS(qname)
-	for _, ns := range nsRecords {
+// GetResolvedNS returns the addresses the additional section gives for the name servers of qname, in the
+// order of the NS records
+func (dp *DnsPacket) GetResolvedNS(qname Name) []net.IP {
+	var addresses []net.IP
+	for _, ns := range dp.GetNS(qname) {
 		for _, res := range dp.Resources {
-			if res.A != nil && res.A.domain == ns.domain {
-				return net.ParseIP(res.A.addr)
+			if res.A != nil && res.A.domain.Equal(Name(ns.host)) {
+				addresses = append(addresses, net.ParseIP(res.A.addr))
 			}
 		}
 	}
 
-	return nil
+	return addresses
 }
 
-func (dp *DnsPacket) GetUnresolvedNS(qname string) string {
-	nsRecords := dp.GetNS(qname)
-	if len(nsRecords) > 0 {
-		return nsRecords[0].host
+// GetUnresolvedNS returns the host names of the name servers of qname
+func (dp *DnsPacket) GetUnresolvedNS(qname Name) []string {
+	var hosts []string
+	for _, ns := range dp.GetNS(qname) {
+		hosts = append(hosts, ns.host)
 	}
 
-	return ""
+	return hosts
 }
@@ -0,0 +1,43 @@
+package dns
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDnsPacket_GetResolvedNS(t *testing.T) {
+	referral := NewPacket()
+	referral.Authorities = []DnsRecord{
+		NewNSRecord("example.com", "a.iana-servers.net", 3600),
+		NewNSRecord("example.com", "ns.example.com", 3600),
+		NewNSRecord("example.org", "ns.example.org", 3600),
+	}
+	referral.Resources = []DnsRecord{
+		NewARecord("NS.example.com", "192.0.2.2", 3600),
+		NewARecord("ns.example.org", "192.0.2.3", 3600),
+		NewARecord("a.iana-servers.net", "192.0.2.1", 3600),
+	}
+
+	assert.Equal(t, []net.IP{net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2")}, referral.GetResolvedNS("www.example.com"))
+	assert.Equal(t, []string{"a.iana-servers.net", "ns.example.com"}, referral.GetUnresolvedNS("www.example.com"))
+	assert.Equal(t, Name("example.com"), referral.GetNS("www.example.com")[0].Domain())
+}
+
+func TestDnsPacket_GetNS_IgnoresCase(t *testing.T) {
+	referral := NewPacket()
+	referral.Authorities = []DnsRecord{NewNSRecord("Example.COM", "ns.example.com", 3600)}
+	referral.Resources = []DnsRecord{NewARecord("NS.Example.com", "192.0.2.2", 3600)}
+
+	assert.Len(t, referral.GetNS("wWw.eXample.com"), 1)
+	assert.Equal(t, []net.IP{net.ParseIP("192.0.2.2")}, referral.GetResolvedNS("wWw.eXample.com"))
+}
+
+func TestDnsPacket_GetNS_WholeLabels(t *testing.T) {
+	referral := NewPacket()
+	referral.Authorities = []DnsRecord{NewNSRecord("example.com", "ns.example.com", 3600)}
+
+	assert.Empty(t, referral.GetNS("badexample.com"))
+	assert.Len(t, referral.GetNS("example.com."), 1)
+}
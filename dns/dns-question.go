@@ -6,11 +6,11 @@ import (
 )
 
 type DnsQuestion struct {
-	Name  string
+	Name  Name
 	Qtype querytype.QueryType
 }
 
-func NewQuestion(name string, qtype querytype.QueryType) *DnsQuestion {
+func NewQuestion(name Name, qtype querytype.QueryType) *DnsQuestion {
 	return &DnsQuestion{
 		Name:  name,
 		Qtype: qtype,
@@ -18,14 +18,15 @@ func NewQuestion(name string, qtype querytype.QueryType) *DnsQuestion {
 }
 
 func (dn *DnsQuestion) Read(buffer *bytepacketbuffer.PacketBuffer) {
-	dn.Name, _ = buffer.ReadQname()
+	name, _ := buffer.ReadQname()
+	dn.Name = Name(name)
 	qtBuffer, _ := buffer.Read_u16()
 	dn.Qtype = querytype.QueryType(qtBuffer) // qtype
 	_, _ = buffer.Read_u16()                 // class
 }
 
 func (dn *DnsQuestion) Write(buffer *bytepacketbuffer.PacketBuffer) *DnsQuestion {
-	buffer.WriteQname(dn.Name)
+	buffer.WriteQname(dn.Name.String())
 
 	buffer.Write_uint16(uint16(dn.Qtype))
 	buffer.Write_uint16(1)
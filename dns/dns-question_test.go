@@ -13,7 +13,7 @@ func TestDnsQuestion_Read(t *testing.T) {
 	testCases := []struct {
 		name          string
 		input         []byte
-		expectedName  string
+		expectedName  Name
 		expectedQType querytype.QueryType
 	}{
 		{
@@ -54,7 +54,7 @@ func TestDnsQuestion_Read(t *testing.T) {
 func TestDnsQuestion_Write(t *testing.T) {
 	buffer := bytepacketbuffer.NewPacketBuffer()
 
-	qname := "google.com"
+	qname := Name("google.com")
 	qtype := querytype.A
 	question := DnsQuestion{
 		Name:  qname,
@@ -0,0 +1,79 @@
+package dns
+
+import (
+	recordclass "dns-client-go/record-class"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// String renders the record in master file presentation format, e.g. "www.example.com. 300 IN A 192.0.2.1".
+// Types without a dedicated struct use the generic \# form of RFC 3597.
+func (dr *DnsRecord) String() string {
+	var rdata string
+	switch {
+	case dr.A != nil:
+		rdata = dr.A.addr
+	case dr.NS != nil:
+		rdata = absolute(dr.NS.host)
+	case dr.CNAME != nil:
+		rdata = absolute(dr.CNAME.host)
+	case dr.SOA != nil:
+		soa := dr.SOA
+		rdata = fmt.Sprintf("%s %s %d %d %d %d %d", absolute(soa.mname), absolute(soa.rname), soa.serial, soa.refresh, soa.retry, soa.expire, soa.minimum)
+	case dr.MX != nil:
+		rdata = fmt.Sprintf("%d %s", dr.MX.priority, absolute(dr.MX.host))
+	case dr.AAAA != nil:
+		rdata = dr.AAAA.addr
+	case dr.PTR != nil:
+		rdata = absolute(dr.PTR.host)
+	case dr.TXT != nil:
+		quoted := make([]string, len(dr.TXT.texts))
+		for i, text := range dr.TXT.texts {
+			quoted[i] = quote(text)
+		}
+		rdata = strings.Join(quoted, " ")
+	case dr.Unknown != nil:
+		rdata = fmt.Sprintf("\\# %d %s", len(dr.Unknown.data), hex.EncodeToString(dr.Unknown.data))
+	}
+
+	return strings.TrimSpace(fmt.Sprintf("%s %d %s %v %s", absolute(dr.Domain().String()), dr.TTL(), className(dr), dr.Type(), rdata))
+}
+
+func className(dr *DnsRecord) string {
+	switch dr.Class() {
+	case recordclass.IN:
+		return "IN"
+	case recordclass.NONE:
+		return "NONE"
+	case recordclass.ANY:
+		return "ANY"
+	}
+
+	return fmt.Sprintf("CLASS%d", dr.Class())
+}
+
+// quote renders a character string in double quotes, escaping quotes, backslashes and non-printable bytes
+func quote(text string) string {
+	var builder strings.Builder
+	builder.WriteByte('"')
+	for i := 0; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case c == '"' || c == '\\':
+			builder.WriteByte('\\')
+			builder.WriteByte(c)
+		case c < ' ' || c > '~':
+			fmt.Fprintf(&builder, "\\%03d", c)
+		default:
+			builder.WriteByte(c)
+		}
+	}
+	builder.WriteByte('"')
+
+	return builder.String()
+}
+
+func absolute(name string) string {
+	return name + "."
+}
@@ -1,10 +1,11 @@
 package dns
 
 import (
+	"bytes"
 	bytepacketbuffer "dns-client-go/packetbuffer"
 	querytype "dns-client-go/query-type"
+	recordclass "dns-client-go/record-class"
 	"errors"
-	"fmt"
 	"net"
 	"strconv"
 	"strings"
@@ -14,62 +15,233 @@ type WriteableRecord interface {
 	Write(buffer *bytepacketbuffer.PacketBuffer)
 }
 
+// UnknownRecord keeps the raw data of types without a dedicated struct. It also carries the
+// records of UPDATE messages (RFC 2136) that use the ANY and NONE classes, often without data.
 type UnknownRecord struct {
-	domain     string
+	domain     Name
 	qtype      uint16
+	class      recordclass.RecordClass
 	dataLength uint16
+	data       []byte
 	ttl        uint32
 }
 
 type ARecord struct {
-	domain string
+	domain Name
 	addr   string
 	ttl    uint32
 }
 
 type NSRecord struct {
-	domain string
+	domain Name
 	host   string
 	ttl    uint32
 }
 
 type CNAMERecord struct {
-	domain string
+	domain Name
 	host   string
 	ttl    uint32
 }
 
+type PTRRecord struct {
+	domain Name
+	host   string
+	ttl    uint32
+}
+
+// TXTRecord holds one or more character strings of up to 255 bytes each
+type TXTRecord struct {
+	domain Name
+	texts  []string
+	ttl    uint32
+}
+
 type MXRecord struct {
-	domain   string
+	domain   Name
 	host     string
 	priority uint16
 	ttl      uint32
 }
 
 type AAAARecord struct {
-	domain string
+	domain Name
 	addr   string
 	ttl    uint32
 }
 
+type SOARecord struct {
+	domain  Name
+	mname   string
+	rname   string
+	serial  uint32
+	refresh uint32
+	retry   uint32
+	expire  uint32
+	minimum uint32
+	ttl     uint32
+}
+
 type DnsRecord struct {
 	Unknown *UnknownRecord
 	A       *ARecord
 	NS      *NSRecord
 	CNAME   *CNAMERecord
+	SOA     *SOARecord
 	MX      *MXRecord
 	AAAA    *AAAARecord
+	PTR     *PTRRecord
+	TXT     *TXTRecord
+}
+
+func NewARecord(domain Name, addr string, ttl uint32) DnsRecord {
+	return DnsRecord{A: &ARecord{domain: domain, addr: addr, ttl: ttl}}
+}
+
+func NewAAAARecord(domain Name, addr string, ttl uint32) DnsRecord {
+	return DnsRecord{AAAA: &AAAARecord{domain: domain, addr: addr, ttl: ttl}}
+}
+
+func NewNSRecord(domain Name, host string, ttl uint32) DnsRecord {
+	return DnsRecord{NS: &NSRecord{domain: domain, host: host, ttl: ttl}}
+}
+
+func NewCNAMERecord(domain Name, host string, ttl uint32) DnsRecord {
+	return DnsRecord{CNAME: &CNAMERecord{domain: domain, host: host, ttl: ttl}}
+}
+
+func NewPTRRecord(domain Name, host string, ttl uint32) DnsRecord {
+	return DnsRecord{PTR: &PTRRecord{domain: domain, host: host, ttl: ttl}}
+}
+
+// NewTXTRecord builds a TXT record, texts longer than 255 bytes are split into several character strings
+func NewTXTRecord(domain Name, texts []string, ttl uint32) DnsRecord {
+	var split []string
+	for _, text := range texts {
+		for len(text) > 255 {
+			split = append(split, text[:255])
+			text = text[255:]
+		}
+		split = append(split, text)
+	}
+
+	return DnsRecord{TXT: &TXTRecord{domain: domain, texts: split, ttl: ttl}}
+}
+
+func NewMXRecord(domain Name, host string, priority uint16, ttl uint32) DnsRecord {
+	return DnsRecord{MX: &MXRecord{domain: domain, host: host, priority: priority, ttl: ttl}}
+}
+
+func NewSOARecord(domain Name, mname string, rname string, serial uint32, refresh uint32, retry uint32, expire uint32, minimum uint32, ttl uint32) DnsRecord {
+	return DnsRecord{SOA: &SOARecord{
+		domain:  domain,
+		mname:   mname,
+		rname:   rname,
+		serial:  serial,
+		refresh: refresh,
+		retry:   retry,
+		expire:  expire,
+		minimum: minimum,
+		ttl:     ttl,
+	}}
+}
+
+// NewUnknownRecord builds a record from raw data, e.g. the class ANY and NONE records of an UPDATE message
+func NewUnknownRecord(domain Name, qtype querytype.QueryType, class recordclass.RecordClass, data []byte, ttl uint32) DnsRecord {
+	return DnsRecord{Unknown: &UnknownRecord{
+		domain:     domain,
+		qtype:      uint16(qtype),
+		class:      class,
+		dataLength: uint16(len(data)),
+		data:       data,
+		ttl:        ttl,
+	}}
+}
+
+func (un *UnknownRecord) Class() recordclass.RecordClass {
+	if un.class == 0 {
+		return recordclass.IN
+	}
+
+	return un.class
+}
+
+func (ns *NSRecord) Host() string {
+	return ns.host
+}
+
+// Domain returns the zone the name server is authoritative for
+func (ns *NSRecord) Domain() Name {
+	return ns.domain
+}
+
+func (cn *CNAMERecord) Host() string {
+	return cn.host
+}
+
+func (ptr *PTRRecord) Host() string {
+	return ptr.host
+}
+
+func (txt *TXTRecord) Texts() []string {
+	return append([]string(nil), txt.texts...)
+}
+
+// WithSerial returns a copy of the SOA record with the serial replaced
+func (soa *SOARecord) WithSerial(serial uint32) DnsRecord {
+	updated := *soa
+	updated.serial = serial
+	return DnsRecord{SOA: &updated}
+}
+
+func (soa *SOARecord) Serial() uint32 {
+	return soa.serial
+}
+
+// Refresh is the interval in seconds after which a secondary checks the primary for a newer serial
+func (soa *SOARecord) Refresh() uint32 {
+	return soa.refresh
+}
+
+// Retry is the interval in seconds between attempts after a failed refresh
+func (soa *SOARecord) Retry() uint32 {
+	return soa.retry
+}
+
+// Expire is the time in seconds after which a secondary that could not refresh stops being authoritative
+func (soa *SOARecord) Expire() uint32 {
+	return soa.expire
+}
+
+// Minimum is the TTL used for negative answers from the zone
+func (soa *SOARecord) Minimum() uint32 {
+	return soa.minimum
 }
 
 func (dr *DnsRecord) Read(buffer *bytepacketbuffer.PacketBuffer) DnsRecord {
-	domain, _ := buffer.ReadQname()
+	owner, _ := buffer.ReadQname()
+	domain := Name(owner)
 
 	qtypeNumber, _ := buffer.Read_u16()
 	qtype := querytype.QueryType(qtypeNumber)
-	_, _ = buffer.Read_u16()
+	classNumber, _ := buffer.Read_u16()
+	class := recordclass.RecordClass(classNumber)
 	ttl, _ := buffer.Read_u32()
 	dataLength, _ := buffer.Read_u16()
 
+	// Only records of the IN class carry data in the layout of their type
+	if class != recordclass.IN || dataLength == 0 {
+		dr.Unknown = &UnknownRecord{
+			domain:     domain,
+			qtype:      qtypeNumber,
+			class:      class,
+			dataLength: dataLength,
+			data:       readRdata(buffer, dataLength),
+			ttl:        ttl,
+		}
+		return *dr
+	}
+
 	switch qtype {
 	case querytype.NS:
 		ns, _ := buffer.ReadQname()
@@ -87,6 +259,41 @@ func (dr *DnsRecord) Read(buffer *bytepacketbuffer.PacketBuffer) DnsRecord {
 			ttl:    ttl,
 		}
 		return *dr
+	case querytype.SOA:
+		mname, _ := buffer.ReadQname()
+		rname, _ := buffer.ReadQname()
+		serial, _ := buffer.Read_u32()
+		refresh, _ := buffer.Read_u32()
+		retry, _ := buffer.Read_u32()
+		expire, _ := buffer.Read_u32()
+		minimum, _ := buffer.Read_u32()
+		dr.SOA = &SOARecord{
+			domain:  domain,
+			mname:   mname,
+			rname:   rname,
+			serial:  serial,
+			refresh: refresh,
+			retry:   retry,
+			expire:  expire,
+			minimum: minimum,
+			ttl:     ttl,
+		}
+		return *dr
+	case querytype.PTR:
+		host, _ := buffer.ReadQname()
+		dr.PTR = &PTRRecord{
+			domain: domain,
+			host:   host,
+			ttl:    ttl,
+		}
+		return *dr
+	case querytype.TXT:
+		dr.TXT = &TXTRecord{
+			domain: domain,
+			texts:  readTexts(readRdata(buffer, dataLength)),
+			ttl:    ttl,
+		}
+		return *dr
 	case querytype.MX:
 		priority, _ := buffer.Read_u16()
 		mx, _ := buffer.ReadQname()
@@ -127,23 +334,27 @@ func (dr *DnsRecord) Read(buffer *bytepacketbuffer.PacketBuffer) DnsRecord {
 		}
 		return *dr
 	case querytype.UNKNOWN:
-		buffer.Step(uint(dataLength))
+		data := readRdata(buffer, dataLength)
 		dr.Unknown = &UnknownRecord{
 			domain:     domain,
 			qtype:      qtypeNumber,
+			class:      class,
 			dataLength: dataLength,
+			data:       data,
 			ttl:        ttl,
 		}
 
 		return *dr
 	default:
-		buffer.Step(uint(dataLength))
+		data := readRdata(buffer, dataLength)
 
 		return DnsRecord{
 			Unknown: &UnknownRecord{
 				domain:     domain,
 				qtype:      qtypeNumber,
+				class:      class,
 				dataLength: dataLength,
+				data:       data,
 				ttl:        ttl,
 			},
 		}
@@ -151,8 +362,37 @@ func (dr *DnsRecord) Read(buffer *bytepacketbuffer.PacketBuffer) DnsRecord {
 
 }
 
+// readRdata copies the raw record data so it can be written back unchanged,
+// e.g. when the record is passed on in a zone transfer.
+func readRdata(buffer *bytepacketbuffer.PacketBuffer, dataLength uint16) []byte {
+	raw, err := buffer.GetRange(buffer.Pos(), uint(dataLength))
+	buffer.Step(uint(dataLength))
+	if err != nil {
+		return nil
+	}
+
+	data := make([]byte, len(raw))
+	copy(data, raw)
+	return data
+}
+
+// readTexts splits TXT record data into its length prefixed character strings
+func readTexts(data []byte) []string {
+	texts := []string{}
+	for len(data) > 0 {
+		length := int(data[0])
+		if length >= len(data) {
+			length = len(data) - 1
+		}
+		texts = append(texts, string(data[1:1+length]))
+		data = data[1+length:]
+	}
+
+	return texts
+}
+
 func (a *ARecord) Write(buffer *bytepacketbuffer.PacketBuffer) {
-	buffer.WriteQname(a.domain)
+	buffer.WriteQname(a.domain.String())
 	buffer.Write_uint16(uint16(querytype.A))
 	buffer.Write_uint16(1) // IN Class
 	buffer.Write_uint32(a.ttl)
@@ -173,7 +413,7 @@ func (a *ARecord) Write(buffer *bytepacketbuffer.PacketBuffer) {
 }
 
 func (ns *NSRecord) Write(buffer *bytepacketbuffer.PacketBuffer) {
-	buffer.WriteQname(ns.domain)
+	buffer.WriteQname(ns.domain.String())
 	buffer.Write_uint16(uint16(querytype.NS))
 	buffer.Write_uint16(1) // IN Class
 	buffer.Write_uint32(ns.ttl)
@@ -188,7 +428,7 @@ func (ns *NSRecord) Write(buffer *bytepacketbuffer.PacketBuffer) {
 }
 
 func (mx *MXRecord) Write(buffer *bytepacketbuffer.PacketBuffer) {
-	buffer.WriteQname(mx.domain)
+	buffer.WriteQname(mx.domain.String())
 	buffer.Write_uint16(uint16(querytype.MX))
 	buffer.Write_uint16(1) // IN Class
 	buffer.Write_uint32(mx.ttl)
@@ -204,7 +444,7 @@ func (mx *MXRecord) Write(buffer *bytepacketbuffer.PacketBuffer) {
 }
 
 func (cn *CNAMERecord) Write(buffer *bytepacketbuffer.PacketBuffer) {
-	buffer.WriteQname(cn.domain)
+	buffer.WriteQname(cn.domain.String())
 	buffer.Write_uint16(uint16(querytype.CNAME))
 	buffer.Write_uint16(1) // IN Class
 	buffer.Write_uint32(cn.ttl)
@@ -218,8 +458,71 @@ func (cn *CNAMERecord) Write(buffer *bytepacketbuffer.PacketBuffer) {
 	buffer.SetValue_u16(pos, uint16(size)) // Update placeholder with CNAME length
 }
 
+func (ptr *PTRRecord) Write(buffer *bytepacketbuffer.PacketBuffer) {
+	buffer.WriteQname(ptr.domain.String())
+	buffer.Write_uint16(uint16(querytype.PTR))
+	buffer.Write_uint16(1) // IN Class
+	buffer.Write_uint32(ptr.ttl)
+
+	pos := buffer.Pos()
+	buffer.Write_uint16(0) // Allocate a placeholder for the PTR length
+
+	buffer.WriteQname(ptr.host)
+
+	size := buffer.Pos() - (pos + 2)
+	buffer.SetValue_u16(pos, uint16(size)) // Update placeholder with PTR length
+}
+
+func (txt *TXTRecord) Write(buffer *bytepacketbuffer.PacketBuffer) {
+	buffer.WriteQname(txt.domain.String())
+	buffer.Write_uint16(uint16(querytype.TXT))
+	buffer.Write_uint16(1) // IN Class
+	buffer.Write_uint32(txt.ttl)
+
+	pos := buffer.Pos()
+	buffer.Write_uint16(0) // Allocate a placeholder for the TXT data length
+
+	for _, text := range txt.texts {
+		buffer.Write_uint8(uint8(len(text)))
+		buffer.WriteBytes([]byte(text))
+	}
+
+	size := buffer.Pos() - (pos + 2)
+	buffer.SetValue_u16(pos, uint16(size)) // Update placeholder with TXT data length
+}
+
+func (soa *SOARecord) Write(buffer *bytepacketbuffer.PacketBuffer) {
+	buffer.WriteQname(soa.domain.String())
+	buffer.Write_uint16(uint16(querytype.SOA))
+	buffer.Write_uint16(1) // IN Class
+	buffer.Write_uint32(soa.ttl)
+
+	pos := buffer.Pos()
+	buffer.Write_uint16(0) // Allocate a placeholder for the SOA data length
+
+	buffer.WriteQname(soa.mname)
+	buffer.WriteQname(soa.rname)
+	buffer.Write_uint32(soa.serial)
+	buffer.Write_uint32(soa.refresh)
+	buffer.Write_uint32(soa.retry)
+	buffer.Write_uint32(soa.expire)
+	buffer.Write_uint32(soa.minimum)
+
+	size := buffer.Pos() - (pos + 2)
+	buffer.SetValue_u16(pos, uint16(size)) // Update placeholder with SOA data length
+}
+
+func (un *UnknownRecord) Write(buffer *bytepacketbuffer.PacketBuffer) {
+	buffer.WriteQname(un.domain.String())
+	buffer.Write_uint16(un.qtype)
+	buffer.Write_uint16(uint16(un.Class()))
+	buffer.Write_uint32(un.ttl)
+	buffer.Write_uint16(uint16(len(un.data)))
+	buffer.WriteBytes(un.data)
+}
+
 func (aaaa *AAAARecord) Write(buffer *bytepacketbuffer.PacketBuffer) {
-	buffer.WriteQname(aaaa.domain)
+	buffer.WriteQname(aaaa.domain.String())
 	buffer.Write_uint16(uint16(querytype.AAAA))
 	buffer.Write_uint16(1) // IN Class
 	buffer.Write_uint32(aaaa.ttl)
@@ -245,12 +548,18 @@ func (dr *DnsRecord) Write(buffer *bytepacketbuffer.PacketBuffer) (uint, error)
 		dr.NS.Write(buffer)
 	case dr.CNAME != nil:
 		dr.CNAME.Write(buffer)
+	case dr.SOA != nil:
+		dr.SOA.Write(buffer)
 	case dr.MX != nil:
 		dr.MX.Write(buffer)
 	case dr.AAAA != nil:
 		dr.AAAA.Write(buffer)
+	case dr.PTR != nil:
+		dr.PTR.Write(buffer)
+	case dr.TXT != nil:
+		dr.TXT.Write(buffer)
 	case dr.Unknown != nil:
-		fmt.Printf("Omitting the unknown DNS Record")
+		dr.Unknown.Write(buffer)
 	default:
 		return 0, errors.New("record contains no known DNS record data")
 
@@ -258,3 +567,207 @@ func (dr *DnsRecord) Write(buffer *bytepacketbuffer.PacketBuffer) (uint, error)
 
 	return buffer.Pos() - startPos, nil
 }
+
+// Domain returns the owner name of the record
+func (dr *DnsRecord) Domain() Name {
+	switch {
+	case dr.A != nil:
+		return dr.A.domain
+	case dr.NS != nil:
+		return dr.NS.domain
+	case dr.CNAME != nil:
+		return dr.CNAME.domain
+	case dr.SOA != nil:
+		return dr.SOA.domain
+	case dr.MX != nil:
+		return dr.MX.domain
+	case dr.AAAA != nil:
+		return dr.AAAA.domain
+	case dr.PTR != nil:
+		return dr.PTR.domain
+	case dr.TXT != nil:
+		return dr.TXT.domain
+	case dr.Unknown != nil:
+		return dr.Unknown.domain
+	}
+
+	return ""
+}
+
+func (dr *DnsRecord) Type() querytype.QueryType {
+	switch {
+	case dr.A != nil:
+		return querytype.A
+	case dr.NS != nil:
+		return querytype.NS
+	case dr.CNAME != nil:
+		return querytype.CNAME
+	case dr.SOA != nil:
+		return querytype.SOA
+	case dr.MX != nil:
+		return querytype.MX
+	case dr.AAAA != nil:
+		return querytype.AAAA
+	case dr.PTR != nil:
+		return querytype.PTR
+	case dr.TXT != nil:
+		return querytype.TXT
+	case dr.Unknown != nil:
+		return querytype.QueryType(dr.Unknown.qtype)
+	}
+
+	return querytype.UNKNOWN
+}
+
+func (dr *DnsRecord) TTL() uint32 {
+	switch {
+	case dr.A != nil:
+		return dr.A.ttl
+	case dr.NS != nil:
+		return dr.NS.ttl
+	case dr.CNAME != nil:
+		return dr.CNAME.ttl
+	case dr.SOA != nil:
+		return dr.SOA.ttl
+	case dr.MX != nil:
+		return dr.MX.ttl
+	case dr.AAAA != nil:
+		return dr.AAAA.ttl
+	case dr.PTR != nil:
+		return dr.PTR.ttl
+	case dr.TXT != nil:
+		return dr.TXT.ttl
+	case dr.Unknown != nil:
+		return dr.Unknown.ttl
+	}
+
+	return 0
+}
+
+// Class returns the class of the record, which is IN for everything but UPDATE prerequisites and deletions
+func (dr *DnsRecord) Class() recordclass.RecordClass {
+	if dr.Unknown != nil {
+		return dr.Unknown.Class()
+	}
+
+	return recordclass.IN
+}
+
+// HasData reports whether the record carries record data, deletions of whole RRsets in UPDATE messages do not
+func (dr *DnsRecord) HasData() bool {
+	return dr.Unknown == nil || len(dr.Unknown.data) > 0
+}
+
+// WithTTL returns a copy of the record with the TTL replaced
+func (dr DnsRecord) WithTTL(ttl uint32) DnsRecord {
+	switch {
+	case dr.A != nil:
+		a := *dr.A
+		a.ttl = ttl
+		return DnsRecord{A: &a}
+	case dr.NS != nil:
+		ns := *dr.NS
+		ns.ttl = ttl
+		return DnsRecord{NS: &ns}
+	case dr.CNAME != nil:
+		cname := *dr.CNAME
+		cname.ttl = ttl
+		return DnsRecord{CNAME: &cname}
+	case dr.SOA != nil:
+		soa := *dr.SOA
+		soa.ttl = ttl
+		return DnsRecord{SOA: &soa}
+	case dr.MX != nil:
+		mx := *dr.MX
+		mx.ttl = ttl
+		return DnsRecord{MX: &mx}
+	case dr.AAAA != nil:
+		aaaa := *dr.AAAA
+		aaaa.ttl = ttl
+		return DnsRecord{AAAA: &aaaa}
+	case dr.PTR != nil:
+		ptr := *dr.PTR
+		ptr.ttl = ttl
+		return DnsRecord{PTR: &ptr}
+	case dr.TXT != nil:
+		txt := *dr.TXT
+		txt.ttl = ttl
+		return DnsRecord{TXT: &txt}
+	case dr.Unknown != nil:
+		unknown := *dr.Unknown
+		unknown.ttl = ttl
+		return DnsRecord{Unknown: &unknown}
+	}
+
+	return dr
+}
+
+// WithDomain returns a copy of the record with the owner name replaced
+func (dr DnsRecord) WithDomain(domain Name) DnsRecord {
+	switch {
+	case dr.A != nil:
+		a := *dr.A
+		a.domain = domain
+		return DnsRecord{A: &a}
+	case dr.NS != nil:
+		ns := *dr.NS
+		ns.domain = domain
+		return DnsRecord{NS: &ns}
+	case dr.CNAME != nil:
+		cname := *dr.CNAME
+		cname.domain = domain
+		return DnsRecord{CNAME: &cname}
+	case dr.SOA != nil:
+		soa := *dr.SOA
+		soa.domain = domain
+		return DnsRecord{SOA: &soa}
+	case dr.MX != nil:
+		mx := *dr.MX
+		mx.domain = domain
+		return DnsRecord{MX: &mx}
+	case dr.AAAA != nil:
+		aaaa := *dr.AAAA
+		aaaa.domain = domain
+		return DnsRecord{AAAA: &aaaa}
+	case dr.PTR != nil:
+		ptr := *dr.PTR
+		ptr.domain = domain
+		return DnsRecord{PTR: &ptr}
+	case dr.TXT != nil:
+		txt := *dr.TXT
+		txt.domain = domain
+		return DnsRecord{TXT: &txt}
+	case dr.Unknown != nil:
+		unknown := *dr.Unknown
+		unknown.domain = domain
+		return DnsRecord{Unknown: &unknown}
+	}
+
+	return dr
+}
+
+// Equal reports whether both records describe the same resource record, that is they share
+// the owner name (compared case-insensitively), the type and the record data. The TTL is ignored.
+func (dr *DnsRecord) Equal(other *DnsRecord) bool {
+	if dr.Type() != other.Type() || !dr.Domain().Equal(other.Domain()) {
+		return false
+	}
+
+	return bytes.Equal(dr.Rdata(), other.Rdata())
+}
+
+// Rdata returns the wire format of the record data
+func (dr *DnsRecord) Rdata() []byte {
+	buffer := bytepacketbuffer.NewPacketBufferWithSize(bytepacketbuffer.MaxMessageSize)
+	if _, err := dr.Write(&buffer); err != nil {
+		return nil
+	}
+
+	buffer.SetPosition(0)
+	_, _ = buffer.ReadQname()
+	buffer.Step(8) // type, class and ttl
+	length, _ := buffer.Read_u16()
+	data, _ := buffer.GetRange(buffer.Pos(), uint(length))
+
+	return data
+}
@@ -0,0 +1,143 @@
+package dns
+
+import (
+	bytepacketbuffer "dns-client-go/packetbuffer"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// Internationalized names (IDNA2008, RFC 5890) are sent as A-labels, the punycode (RFC 3492) form of
+// their Unicode labels prefixed with xn--. ToASCII and ToUnicode convert between both forms at the edges,
+// names inside the library are always made of A-labels.
+//
+// The conversion is the one of UTS #46 with the lookup profile of golang.org/x/net/idna: names typed by
+// users are mapped (case folding, width, NFC) and checked against the IDNA2008 and bidi rules. Labels that
+// are plain ASCII, like the underscore labels of service names, are left as they are.
+
+// acePrefix marks an A-label
+const acePrefix = "xn--"
+
+// lookup converts the non-ASCII labels and checks the A-labels of a name, one label at a time
+var lookup = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.Transitional(false), idna.VerifyDNSLength(true))
+
+// dots are the label separators of UTS #46 besides the full stop
+var dots = strings.NewReplacer("。", ".", "．", ".", "｡", ".")
+
+// ToASCII converts a name with Unicode labels to the A-labels sent on the wire, e.g. bücher.de to
+// xn--bcher-kva.de. ASCII labels are left as they are, A-labels given are checked to be valid.
+func ToASCII(name string) (string, error) {
+	if isASCII(name) && !hasACELabel(name) {
+		return name, nil
+	}
+
+	name = dots.Replace(name)
+	labels, err := bytepacketbuffer.SplitName(name)
+	if err != nil {
+		return "", err
+	}
+
+	for i, label := range labels {
+		switch {
+		case hasACEPrefix(label):
+			if _, err := decodeALabel(label); err != nil {
+				return "", err
+			}
+		case !isASCII(label):
+			if !utf8.ValidString(label) {
+				return "", fmt.Errorf("label %q is not valid UTF-8", label)
+			}
+			if labels[i], err = lookup.ToASCII(label); err != nil {
+				return "", fmt.Errorf("invalid label %q: %w", label, err)
+			}
+		}
+	}
+
+	return withTrailingDot(string(NewName(labels...)), name), nil
+}
+
+// ToUnicode converts the A-labels of a name to Unicode for display, e.g. xn--bcher-kva.de to bücher.de.
+// Other labels stay in presentation format. It fails on A-labels that are not valid punycode or do not
+// decode to a valid Unicode label.
+func ToUnicode(name string) (string, error) {
+	if !hasACELabel(name) {
+		return name, nil
+	}
+
+	labels, err := bytepacketbuffer.SplitName(name)
+	if err != nil {
+		return "", err
+	}
+
+	display := make([]string, len(labels))
+	for i, label := range labels {
+		if !hasACEPrefix(label) {
+			display[i] = bytepacketbuffer.EscapeLabel(label)
+			continue
+		}
+		if display[i], err = decodeALabel(label); err != nil {
+			return "", err
+		}
+	}
+
+	return withTrailingDot(strings.Join(display, "."), name), nil
+}
+
+// Unicode returns the name for display, with its A-labels in Unicode, or the name itself when it holds
+// invalid A-labels
+func (n Name) Unicode() string {
+	display, err := ToUnicode(string(n))
+	if err != nil {
+		return string(n)
+	}
+
+	return display
+}
+
+// decodeALabel returns the Unicode label of an A-label, which must be what the Unicode label encodes to.
+// A-labels are compared case-insensitively like other labels.
+func decodeALabel(label string) (string, error) {
+	aLabel := lowerASCII(label)
+	decoded, err := lookup.ToUnicode(aLabel)
+	if err != nil {
+		return "", fmt.Errorf("invalid A-label %q: %w", label, err)
+	}
+	if isASCII(decoded) {
+		return "", fmt.Errorf("invalid A-label %q: encodes an ASCII label", label)
+	}
+	if encoded, err := lookup.ToASCII(decoded); err != nil || encoded != aLabel {
+		return "", fmt.Errorf("invalid A-label %q: not in canonical form", label)
+	}
+
+	return decoded, nil
+}
+
+func isASCII(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if text[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+
+	return true
+}
+
+func hasACEPrefix(label string) bool {
+	return len(label) >= len(acePrefix) && strings.EqualFold(label[:len(acePrefix)], acePrefix)
+}
+
+// hasACELabel reports whether any label of a name may be an A-label
+func hasACELabel(name string) bool {
+	return strings.Contains(lowerASCII(name), acePrefix)
+}
+
+// withTrailingDot ends name with a dot when original is absolute
+func withTrailingDot(name string, original string) string {
+	if bytepacketbuffer.IsAbsolute(original) && name != "" {
+		return name + "."
+	}
+
+	return name
+}
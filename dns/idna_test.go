@@ -0,0 +1,94 @@
+package dns
+
+import (
+	"strings"
+	"testing"
+
+	bytepacketbuffer "dns-client-go/packetbuffer"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToASCII(t *testing.T) {
+	for name, expected := range map[string]string{
+		"bücher.de":         "xn--bcher-kva.de",
+		"BÜCHER.de.":        "xn--bcher-kva.de.",
+		"münchen.de":        "xn--mnchen-3ya.de",
+		"例え.テスト":            "xn--r8jz45g.xn--zckzah",
+		"中国。ｃｎ":             "xn--fiqs8s.cn",
+		"δοκιμή":            "xn--jxalpdlp",
+		"www.example.com":   "www.example.com",
+		"_sip._udp.Example": "_sip._udp.Example",
+		"xn--bcher-kva.de":  "xn--bcher-kva.de",
+		"XN--BCHER-KVA.de":  "XN--BCHER-KVA.de",
+		"":                  "",
+	} {
+		ascii, err := ToASCII(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, expected, ascii, name)
+	}
+}
+
+func TestToASCII_Invalid(t *testing.T) {
+	for _, name := range []string{
+		"bü cher.de",                    // a space
+		"-bücher.de",                    // a leading hyphen
+		"\u0301bücher.de",               // a leading combining mark
+		"a\u05d0.de",                    // left-to-right and right-to-left in one label
+		"a\u200cb.de",                   // a zero width non-joiner out of context
+		"xn--bcher-kva-.de",             // not what bücher encodes to
+		"xn--abc-.de",                   // an ASCII label in disguise
+		"xn--bcher-kvb.de",              // decodes to an upper case letter
+		strings.Repeat("α", 60) + ".de", // too long as an A-label
+	} {
+		_, err := ToASCII(name)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestToUnicode(t *testing.T) {
+	for name, expected := range map[string]string{
+		"xn--bcher-kva.de":       "bücher.de",
+		"XN--BCHER-kva.de.":      "bücher.de.",
+		"xn--r8jz45g.xn--zckzah": "例え.テスト",
+		"www.example.com":        "www.example.com",
+		`a\.b.xn--fiqs8s`:        `a\.b.中国`,
+	} {
+		display, err := ToUnicode(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, expected, display, name)
+	}
+
+	_, err := ToUnicode("xn--a-.de")
+	assert.Error(t, err)
+}
+
+func TestName_Unicode(t *testing.T) {
+	// Names come off the wire as A-labels and are displayed in Unicode
+	buffer := bytepacketbuffer.NewPacketBuffer()
+	assert.NoError(t, buffer.WriteQname("xn--mnchen-3ya.de"))
+	buffer.SetPosition(0)
+	read, err := buffer.ReadQname()
+	assert.NoError(t, err)
+	assert.Equal(t, "münchen.de", Name(read).Unicode())
+
+	assert.Equal(t, "xn--zz.de", Name("xn--zz.de").Unicode())
+}
+
+func TestToASCII_UTS46(t *testing.T) {
+	// Nontransitional processing keeps ß, the mapping folds case and composes to NFC, and symbols are valid
+	for name, expected := range map[string]string{
+		"faß.de":      "xn--fa-hia.de",
+		"Bloß.de":     "xn--blo-7ka.de",
+		"ÖBB":         "xn--bb-eka",
+		"√.com":       "xn--19g.com",
+		"ü.xn--tda":   "xn--tda.xn--tda",
+		"u\u0308.com": "xn--tda.com",
+		"مثال.إختبار": "xn--mgbh0fb.xn--kgbechtv",
+		"☃.net":       "xn--n3h.net",
+	} {
+		ascii, err := ToASCII(name)
+		assert.NoError(t, err, name)
+		assert.Equal(t, expected, ascii, name)
+	}
+}
@@ -0,0 +1,123 @@
+package dns
+
+import (
+	bytepacketbuffer "dns-client-go/packetbuffer"
+	"strings"
+)
+
+// Name is a domain name in presentation format: labels separated by dots, in which dots, backslashes and
+// bytes outside of printable ASCII are escaped as \. \\ and \DDD (RFC 1035 section 5.1). A trailing dot
+// is optional, the root is "" or ".". Names compare case-insensitively, as ASCII letters only.
+type Name string
+
+// NewName joins raw labels into a name, escaping what needs to be
+func NewName(labels ...string) Name {
+	return Name(bytepacketbuffer.JoinLabels(labels))
+}
+
+// Labels returns the raw labels of the name, from the leftmost, with the escapes resolved. The root has
+// none. Malformed escapes are taken literally, WriteQname is where they are rejected.
+func (n Name) Labels() []string {
+	labels, _ := bytepacketbuffer.SplitName(string(n))
+	return labels
+}
+
+// IsAbsolute reports whether the name ends with an unescaped dot
+func (n Name) IsAbsolute() bool {
+	return bytepacketbuffer.IsAbsolute(string(n))
+}
+
+// IsRoot reports whether the name is the root
+func (n Name) IsRoot() bool {
+	return len(n.Labels()) == 0
+}
+
+// CountLabels returns the number of labels of the name, 0 for the root
+func (n Name) CountLabels() int {
+	return len(n.Labels())
+}
+
+// Canonical returns the name in the form names are compared and stored in: lower-case, without a trailing
+// dot and with only the escapes needed
+func (n Name) Canonical() Name {
+	labels := n.Labels()
+	for i, label := range labels {
+		labels[i] = lowerASCII(label)
+	}
+
+	return NewName(labels...)
+}
+
+// Equal reports whether both names are the same, ignoring the case of ASCII letters
+func (n Name) Equal(other Name) bool {
+	return n.Compare(other) == 0
+}
+
+// IsSubdomainOf reports whether the name is zone or lies below it. Labels are compared whole, so
+// badexample.com is not below example.com.
+func (n Name) IsSubdomainOf(zone Name) bool {
+	labels, zoneLabels := n.Labels(), zone.Labels()
+	if len(zoneLabels) > len(labels) {
+		return false
+	}
+
+	offset := len(labels) - len(zoneLabels)
+	for i, label := range zoneLabels {
+		if lowerASCII(labels[offset+i]) != lowerASCII(label) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Parent returns the name without its leftmost label, the root for the root
+func (n Name) Parent() Name {
+	labels := n.Labels()
+	if len(labels) == 0 {
+		return ""
+	}
+
+	return NewName(labels[1:]...)
+}
+
+// Child returns the name with the raw label prepended
+func (n Name) Child(label string) Name {
+	return NewName(append([]string{label}, n.Labels()...)...)
+}
+
+// Compare orders names canonically (RFC 4034 section 6.1): by their labels from the rightmost, each
+// compared as lower-case bytes, a name sorting before the names below it. It returns -1, 0 or 1.
+func (n Name) Compare(other Name) int {
+	labels, otherLabels := n.Labels(), other.Labels()
+	for i, j := len(labels)-1, len(otherLabels)-1; i >= 0 || j >= 0; i, j = i-1, j-1 {
+		switch {
+		case i < 0:
+			return -1
+		case j < 0:
+			return 1
+		}
+
+		if c := strings.Compare(lowerASCII(labels[i]), lowerASCII(otherLabels[j])); c != 0 {
+			return c
+		}
+	}
+
+	return 0
+}
+
+func (n Name) String() string {
+	return string(n)
+}
+
+// lowerASCII lower-cases the ASCII letters of a label and leaves the other bytes alone
+func lowerASCII(label string) string {
+	lower := []byte(label)
+	for i, c := range lower {
+		if 'A' <= c && c <= 'Z' {
+			lower[i] = c + 'a' - 'A'
+		}
+	}
+
+	return string(lower)
+}
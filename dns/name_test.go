@@ -0,0 +1,95 @@
+package dns
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestName_Labels(t *testing.T) {
+	assert.Equal(t, []string{"www", "example", "com"}, Name("www.example.com.").Labels())
+	assert.Equal(t, []string{"www", "example", "com"}, Name("www.example.com").Labels())
+	assert.Nil(t, Name("").Labels())
+	assert.Nil(t, Name(".").Labels())
+	assert.Equal(t, []string{"a.b", "example"}, Name(`a\.b.example`).Labels())
+	assert.Equal(t, []string{"a b", "example."}, Name(`a\032b.example\.`).Labels())
+	assert.Equal(t, 0, Name(".").CountLabels())
+	assert.True(t, Name("").IsRoot())
+}
+
+func TestNewName(t *testing.T) {
+	assert.Equal(t, Name("www.example.com"), NewName("www", "example", "com"))
+	assert.Equal(t, Name(`a\.b.example`), NewName("a.b", "example"))
+	assert.Equal(t, Name(`a\032b\\\255`), NewName("a b\\\xff"))
+	assert.Equal(t, Name(""), NewName())
+
+	for _, name := range []Name{`a\.b.example`, `a\032b\\\255`, `\@.\$x`} {
+		assert.Equal(t, name, NewName(name.Labels()...))
+	}
+}
+
+func TestName_IsAbsolute(t *testing.T) {
+	assert.True(t, Name("example.com.").IsAbsolute())
+	assert.True(t, Name(`example\\.`).IsAbsolute())
+	assert.False(t, Name(`example\.`).IsAbsolute())
+	assert.False(t, Name("example.com").IsAbsolute())
+}
+
+func TestName_Canonical(t *testing.T) {
+	assert.Equal(t, Name("www.example.com"), Name("WWW.Example.COM.").Canonical())
+	assert.Equal(t, Name(`a\.b`), Name(`A\046B`).Canonical())
+	assert.Equal(t, Name(""), Name(".").Canonical())
+}
+
+func TestName_Equal(t *testing.T) {
+	assert.True(t, Name("WWW.example.com.").Equal("www.Example.com"))
+	assert.True(t, Name(`a\.b`).Equal(`A\046b`))
+	assert.False(t, Name(`a\.b`).Equal("a.b"))
+	assert.True(t, Name("").Equal("."))
+	// Only ASCII letters are folded
+	assert.False(t, Name(`\200`).Equal(`\224`))
+}
+
+func TestName_IsSubdomainOf(t *testing.T) {
+	assert.True(t, Name("www.example.com").IsSubdomainOf("example.com"))
+	assert.True(t, Name("Example.COM").IsSubdomainOf("example.com."))
+	assert.True(t, Name("example.com").IsSubdomainOf(""))
+	assert.False(t, Name("badexample.com").IsSubdomainOf("example.com"))
+	assert.False(t, Name("example.com").IsSubdomainOf("www.example.com"))
+	assert.False(t, Name(`www\.example.com`).IsSubdomainOf("example.com"))
+}
+
+func TestName_ParentChild(t *testing.T) {
+	assert.Equal(t, Name("example.com"), Name("www.example.com.").Parent())
+	assert.Equal(t, Name(""), Name("com").Parent())
+	assert.Equal(t, Name(""), Name("").Parent())
+	assert.Equal(t, Name(`example\.com`), Name(`www.example\.com`).Parent())
+	assert.Equal(t, Name("www.example.com"), Name("example.com.").Child("www"))
+	assert.Equal(t, Name(`a\.b.example.com`), Name("example.com").Child("a.b"))
+	assert.Equal(t, Name("com"), Name("").Child("com"))
+}
+
+func TestName_Compare(t *testing.T) {
+	// The example of RFC 4034 section 6.1
+	ordered := []Name{
+		"example",
+		"a.example",
+		"yljkjljk.a.example",
+		"Z.a.example",
+		`zABC.a.EXAMPLE`,
+		"z.example",
+		`\001.z.example`,
+		"*.z.example",
+		`\200.z.example`,
+	}
+
+	shuffled := []Name{ordered[4], ordered[8], ordered[0], ordered[6], ordered[2], ordered[7], ordered[1], ordered[5], ordered[3]}
+	sort.Slice(shuffled, func(i, j int) bool {
+		return shuffled[i].Compare(shuffled[j]) < 0
+	})
+
+	assert.Equal(t, ordered, shuffled)
+	assert.Equal(t, 0, Name("Example.").Compare("example"))
+	assert.Equal(t, -1, Name("").Compare("com"))
+}
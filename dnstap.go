@@ -0,0 +1,49 @@
+package main
+
+import (
+	"dns-client-go/config"
+	"dns-client-go/dnstap"
+	"os"
+	"time"
+)
+
+// openDnstap starts capturing messages to the dnstap output of a configuration, nil when it has none.
+// The writer of the previous server is taken over when its settings did not change.
+func openDnstap(settings config.Dnstap, previous *server) (*dnstap.Writer, error) {
+	if previous != nil && previous.config.Dnstap == settings {
+		return previous.dnstap, nil
+	}
+	if settings.Output == "" {
+		return nil, nil
+	}
+
+	identity := settings.Identity
+	if identity == "" {
+		identity, _ = os.Hostname()
+	}
+
+	return dnstap.Open(settings.Output, identity)
+}
+
+// tap sends a message to the dnstap output, if there is one. The message is encoded right away, so the
+// caller may change it afterwards.
+func (s *server) tap(message *dnstap.Message) {
+	if s.dnstap != nil {
+		s.dnstap.Send(message)
+	}
+}
+
+// tapClient captures a query received from a client, or the response sent to it
+func (s *server) tapClient(kind dnstap.MessageType, data []byte, x *exchange) {
+	if s.dnstap == nil {
+		return
+	}
+
+	message := &dnstap.Message{Type: kind, QueryAddress: x.remote, ResponseAddress: x.local, QueryTime: x.received}
+	if kind == dnstap.ClientQuery {
+		message.QueryMessage = data
+	} else {
+		message.ResponseTime, message.ResponseMessage = time.Now(), data
+	}
+	s.dnstap.Send(message)
+}
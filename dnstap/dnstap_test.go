@@ -0,0 +1,130 @@
+package dnstap
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testMessage = &Message{
+	Type:            ClientQuery,
+	QueryAddress:    &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 5353},
+	ResponseAddress: &net.UDPAddr{IP: net.ParseIP("192.0.2.53"), Port: 53},
+	QueryTime:       time.Unix(1700000000, 5),
+	QueryMessage:    []byte{0xab, 0xcd},
+}
+
+func TestMessage_marshal(t *testing.T) {
+	expected := "0a036e7331" + // identity "ns1"
+		"120d" + hex.EncodeToString([]byte("dns-client-go")) + // version
+		"7226" + // message, 38 bytes
+		"0805" + // type CLIENT_QUERY
+		"1001" + "1801" + // INET, UDP
+		"2204c0000201" + "2a04c0000235" + // query and response addresses
+		"30e929" + "3835" + // query and response ports
+		"4080e2cfaa06" + "4d05000000" + // query time
+		"5202abcd" + // query message
+		"7801" // type MESSAGE
+
+	assert.Equal(t, expected, hex.EncodeToString(testMessage.marshal([]byte("ns1"))))
+}
+
+// readFrame reads a frame, returning the control type and nil data for control frames
+func readFrame(t *testing.T, r io.Reader) (uint32, []byte) {
+	var length uint32
+	assert.NoError(t, binary.Read(r, binary.BigEndian, &length))
+	if length != 0 {
+		data := make([]byte, length)
+		_, err := io.ReadFull(r, data)
+		assert.NoError(t, err)
+		return 0, data
+	}
+
+	assert.NoError(t, binary.Read(r, binary.BigEndian, &length))
+	control := make([]byte, length)
+	_, err := io.ReadFull(r, control)
+	assert.NoError(t, err)
+	if length > 4 {
+		assert.Contains(t, string(control), contentType)
+	}
+
+	return binary.BigEndian.Uint32(control), nil
+}
+
+func TestWriter_File(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dnstap.fstrm")
+	w, err := Open(path, "ns1")
+	assert.NoError(t, err)
+
+	w.Send(testMessage)
+	w.Send(testMessage)
+	w.Close()
+	w.Send(testMessage)
+
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+
+	controlType, _ := readFrame(t, file)
+	assert.Equal(t, uint32(controlStart), controlType)
+	for i := 0; i < 2; i++ {
+		_, data := readFrame(t, file)
+		assert.Equal(t, testMessage.marshal([]byte("ns1")), data)
+	}
+	controlType, _ = readFrame(t, file)
+	assert.Equal(t, uint32(controlStop), controlType)
+	assert.Equal(t, uint64(0), w.Dropped())
+}
+
+func TestWriter_Socket(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dnstap.sock")
+	listener, err := net.Listen("unix", path)
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	w, err := Open("unix:"+path, "")
+	assert.NoError(t, err)
+
+	conn, err := listener.Accept()
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	controlType, _ := readFrame(t, conn)
+	assert.Equal(t, uint32(controlReady), controlType)
+	assert.NoError(t, writeControl(conn, controlAccept))
+	controlType, _ = readFrame(t, conn)
+	assert.Equal(t, uint32(controlStart), controlType)
+
+	w.Send(testMessage)
+	_, data := readFrame(t, conn)
+	assert.Equal(t, testMessage.marshal(nil), data)
+
+	closed := make(chan struct{})
+	go func() {
+		w.Close()
+		close(closed)
+	}()
+	controlType, _ = readFrame(t, conn)
+	assert.Equal(t, uint32(controlStop), controlType)
+	assert.NoError(t, writeControl(conn, controlFinish))
+	<-closed
+}
+
+func TestWriter_DropsWithoutCollector(t *testing.T) {
+	w, err := Open("unix:"+filepath.Join(t.TempDir(), "missing.sock"), "")
+	assert.NoError(t, err)
+
+	for i := 0; i < queueSize+10; i++ {
+		w.Send(testMessage)
+	}
+	w.Close()
+
+	assert.Equal(t, uint64(queueSize+10), w.Dropped())
+}
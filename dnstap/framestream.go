@@ -0,0 +1,73 @@
+package dnstap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// contentType names the payload of the data frames in the Frame Streams handshake
+const contentType = "protobuf:dnstap.Dnstap"
+
+// Frame Streams control frame types
+const (
+	controlAccept = 0x01
+	controlStart  = 0x02
+	controlStop   = 0x03
+	controlReady  = 0x04
+	controlFinish = 0x05
+
+	fieldContentType = 0x01
+)
+
+// maxControlSize bounds the control frames read from a collector
+const maxControlSize = 512
+
+// writeFrame writes a data frame: the length of the payload followed by the payload
+func writeFrame(w io.Writer, data []byte) error {
+	frame := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	_, err := w.Write(append(frame, data...))
+	return err
+}
+
+// writeControl writes a control frame, which is escaped with a zero length, carrying the content type
+// unless it is a STOP or FINISH
+func writeControl(w io.Writer, controlType uint32) error {
+	control := binary.BigEndian.AppendUint32(nil, controlType)
+	if controlType != controlStop && controlType != controlFinish {
+		control = binary.BigEndian.AppendUint32(control, fieldContentType)
+		control = binary.BigEndian.AppendUint32(control, uint32(len(contentType)))
+		control = append(control, contentType...)
+	}
+
+	frame := binary.BigEndian.AppendUint32(nil, 0)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(control)))
+	_, err := w.Write(append(frame, control...))
+	return err
+}
+
+// readControl reads a control frame from a collector and checks its type
+func readControl(r io.Reader, expected uint32) error {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return err
+	}
+	if escape := binary.BigEndian.Uint32(header[:4]); escape != 0 {
+		return fmt.Errorf("expected a control frame, got a data frame")
+	}
+
+	length := binary.BigEndian.Uint32(header[4:])
+	if length < 4 || length > maxControlSize {
+		return fmt.Errorf("invalid control frame length %d", length)
+	}
+	control := make([]byte, length)
+	if _, err := io.ReadFull(r, control); err != nil {
+		return err
+	}
+
+	if controlType := binary.BigEndian.Uint32(control[:4]); controlType != expected {
+		return fmt.Errorf("expected control frame type %d, got %d", expected, controlType)
+	}
+
+	return nil
+}
@@ -0,0 +1,162 @@
+package dnstap
+
+import (
+	"encoding/binary"
+	"net"
+	"time"
+)
+
+// MessageType is the kind of a captured message, as numbered in dnstap.proto
+type MessageType uint64
+
+const (
+	AuthQuery        MessageType = 1
+	AuthResponse     MessageType = 2
+	ResolverQuery    MessageType = 3
+	ResolverResponse MessageType = 4
+	ClientQuery      MessageType = 5
+	ClientResponse   MessageType = 6
+)
+
+// version is sent as the version of the server in every message
+const version = "dns-client-go"
+
+// Field numbers and enum values of dnstap.proto
+const (
+	dnstapIdentity = 1
+	dnstapVersion  = 2
+	dnstapMessage  = 14
+	dnstapType     = 15
+
+	dnstapTypeMessage = 1
+
+	messageType             = 1
+	messageSocketFamily     = 2
+	messageSocketProtocol   = 3
+	messageQueryAddress     = 4
+	messageResponseAddress  = 5
+	messageQueryPort        = 6
+	messageResponsePort     = 7
+	messageQueryTimeSec     = 8
+	messageQueryTimeNsec    = 9
+	messageQueryMessage     = 10
+	messageResponseTimeSec  = 12
+	messageResponseTimeNsec = 13
+	messageResponseMessage  = 14
+
+	socketFamilyINET  = 1
+	socketFamilyINET6 = 2
+
+	socketProtocolUDP = 1
+	socketProtocolTCP = 2
+)
+
+// Protobuf wire types
+const (
+	wireVarint  = 0
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// Message is a DNS message seen by the server. The query address is the side that sent the query: the
+// client for client messages and the server itself for resolver messages. The addresses also tell the
+// transport, *net.UDPAddr or *net.TCPAddr.
+type Message struct {
+	Type            MessageType
+	QueryAddress    net.Addr
+	ResponseAddress net.Addr
+	QueryTime       time.Time
+	QueryMessage    []byte // wire format
+	ResponseTime    time.Time
+	ResponseMessage []byte // wire format
+}
+
+// marshal encodes the message in a Dnstap protobuf envelope
+func (m *Message) marshal(identity []byte) []byte {
+	var message []byte
+	message = appendVarint(message, messageType, uint64(m.Type))
+
+	queryIP, queryPort, queryProtocol := splitAddr(m.QueryAddress)
+	responseIP, responsePort, responseProtocol := splitAddr(m.ResponseAddress)
+	if protocol := max(queryProtocol, responseProtocol); protocol != 0 {
+		family := socketFamilyINET6
+		if queryIP.To4() != nil || responseIP.To4() != nil {
+			family = socketFamilyINET
+		}
+		message = appendVarint(message, messageSocketFamily, uint64(family))
+		message = appendVarint(message, messageSocketProtocol, protocol)
+	}
+	if queryIP != nil {
+		message = appendBytes(message, messageQueryAddress, ipBytes(queryIP))
+	}
+	if responseIP != nil {
+		message = appendBytes(message, messageResponseAddress, ipBytes(responseIP))
+	}
+	if queryIP != nil {
+		message = appendVarint(message, messageQueryPort, uint64(queryPort))
+	}
+	if responseIP != nil {
+		message = appendVarint(message, messageResponsePort, uint64(responsePort))
+	}
+	if !m.QueryTime.IsZero() {
+		message = appendVarint(message, messageQueryTimeSec, uint64(m.QueryTime.Unix()))
+		message = appendFixed32(message, messageQueryTimeNsec, uint32(m.QueryTime.Nanosecond()))
+	}
+	if m.QueryMessage != nil {
+		message = appendBytes(message, messageQueryMessage, m.QueryMessage)
+	}
+	if !m.ResponseTime.IsZero() {
+		message = appendVarint(message, messageResponseTimeSec, uint64(m.ResponseTime.Unix()))
+		message = appendFixed32(message, messageResponseTimeNsec, uint32(m.ResponseTime.Nanosecond()))
+	}
+	if m.ResponseMessage != nil {
+		message = appendBytes(message, messageResponseMessage, m.ResponseMessage)
+	}
+
+	var envelope []byte
+	if len(identity) > 0 {
+		envelope = appendBytes(envelope, dnstapIdentity, identity)
+	}
+	envelope = appendBytes(envelope, dnstapVersion, []byte(version))
+	envelope = appendBytes(envelope, dnstapMessage, message)
+	envelope = appendVarint(envelope, dnstapType, dnstapTypeMessage)
+
+	return envelope
+}
+
+// splitAddr returns the IP, port and dnstap socket protocol of an address, zeros for other addresses
+func splitAddr(addr net.Addr) (net.IP, int, uint64) {
+	switch addr := addr.(type) {
+	case *net.UDPAddr:
+		return addr.IP, addr.Port, socketProtocolUDP
+	case *net.TCPAddr:
+		return addr.IP, addr.Port, socketProtocolTCP
+	}
+
+	return nil, 0, 0
+}
+
+func ipBytes(ip net.IP) []byte {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4
+	}
+
+	return ip.To16()
+}
+
+func appendTag(b []byte, field int, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wireType))
+}
+
+func appendVarint(b []byte, field int, value uint64) []byte {
+	return binary.AppendUvarint(appendTag(b, field, wireVarint), value)
+}
+
+func appendFixed32(b []byte, field int, value uint32) []byte {
+	return binary.LittleEndian.AppendUint32(appendTag(b, field, wireFixed32), value)
+}
+
+func appendBytes(b []byte, field int, value []byte) []byte {
+	b = binary.AppendUvarint(appendTag(b, field, wireBytes), uint64(len(value)))
+	return append(b, value...)
+}
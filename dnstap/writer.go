@@ -0,0 +1,199 @@
+package dnstap
+
+import (
+	"bufio"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// queueSize is the number of messages waiting to be written, further ones are dropped
+const queueSize = 10000
+
+// socketTimeout bounds connecting to a collector and every write to it
+const socketTimeout = 5 * time.Second
+
+// reconnectInterval is the wait before connecting again to a collector that went away
+const reconnectInterval = 5 * time.Second
+
+// Writer sends messages in Frame Streams to a file or to the Unix socket of a collector. Messages are
+// written in the background, when the output falls behind they are dropped rather than slowing down
+// the queries they belong to.
+type Writer struct {
+	output   string
+	identity []byte
+	file     *os.File // nil when writing to a socket
+	frames   chan []byte
+	dropped  atomic.Uint64
+	mu       sync.RWMutex
+	closed   bool
+	done     chan struct{}
+}
+
+// Open starts writing to output, a file or "unix:" followed by the path of a socket. A file is
+// replaced, a socket is connected to in the background and reconnected to when the collector goes
+// away. The identity is sent with every message.
+func Open(output string, identity string) (*Writer, error) {
+	w := &Writer{
+		output:   output,
+		identity: []byte(identity),
+		frames:   make(chan []byte, queueSize),
+		done:     make(chan struct{}),
+	}
+
+	if !strings.HasPrefix(output, "unix:") {
+		file, err := os.Create(output)
+		if err != nil {
+			return nil, err
+		}
+		w.file = file
+	}
+
+	go w.run()
+	return w, nil
+}
+
+// Send queues a message without waiting, it is dropped when the queue is full
+func (w *Writer) Send(m *Message) {
+	frame := m.marshal(w.identity)
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	if w.closed {
+		return
+	}
+
+	select {
+	case w.frames <- frame:
+	default:
+		w.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of messages that were not written
+func (w *Writer) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+// Close writes the queued messages, ends the stream and closes the output
+func (w *Writer) Close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.frames)
+	}
+	w.mu.Unlock()
+
+	<-w.done
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+
+	if w.file != nil {
+		defer w.file.Close()
+		if closed, err := w.stream(w.file, false); !closed {
+			slog.Error("failed to write dnstap file, dropping messages", "output", w.output, "error", err)
+			for w.discard(time.Hour) {
+			}
+		}
+		return
+	}
+
+	path := strings.TrimPrefix(w.output, "unix:")
+	for {
+		conn, err := net.DialTimeout("unix", path, socketTimeout)
+		if err == nil {
+			var closed bool
+			closed, err = w.stream(conn, true)
+			conn.Close()
+			if closed {
+				return
+			}
+		}
+
+		slog.Warn("dnstap collector unavailable, dropping messages", "output", w.output, "error", err)
+		if !w.discard(reconnectInterval) {
+			return
+		}
+	}
+}
+
+// stream writes the queued messages until the writer is closed, which it reports, or until an error.
+// Bidirectional streams, used with sockets, start with a handshake and wait for the collector to
+// acknowledge the end of the stream.
+func (w *Writer) stream(out io.ReadWriter, bidirectional bool) (bool, error) {
+	buffer := bufio.NewWriter(out)
+	flush := func() error {
+		if conn, ok := out.(net.Conn); ok {
+			conn.SetDeadline(time.Now().Add(socketTimeout))
+		}
+		return buffer.Flush()
+	}
+
+	if bidirectional {
+		if err := writeControl(buffer, controlReady); err != nil {
+			return false, err
+		}
+		if err := flush(); err != nil {
+			return false, err
+		}
+		if err := readControl(out, controlAccept); err != nil {
+			return false, err
+		}
+	}
+	if err := writeControl(buffer, controlStart); err != nil {
+		return false, err
+	}
+	if err := flush(); err != nil {
+		return false, err
+	}
+
+	for frame := range w.frames {
+		if err := writeFrame(buffer, frame); err != nil {
+			return false, err
+		}
+		// Flushed whenever the queue runs empty, so messages are not held back while it is quiet
+		if len(w.frames) == 0 {
+			if err := flush(); err != nil {
+				return false, err
+			}
+		}
+	}
+
+	if err := writeControl(buffer, controlStop); err != nil {
+		return true, err
+	}
+	if err := flush(); err != nil {
+		return true, err
+	}
+	if bidirectional {
+		return true, readControl(out, controlFinish)
+	}
+
+	return true, nil
+}
+
+// discard drops the queued messages for a while. It returns false when the writer was closed before.
+func (w *Writer) discard(duration time.Duration) bool {
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	for {
+		select {
+		case _, ok := <-w.frames:
+			if !ok {
+				return false
+			}
+			w.dropped.Add(1)
+		case <-timer.C:
+			return true
+		}
+	}
+}
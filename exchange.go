@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"dns-client-go/dns"
+	"dns-client-go/dnstap"
+	"dns-client-go/handler"
+	"dns-client-go/opcode"
+	packetbuffer "dns-client-go/packetbuffer"
+	resultcode "dns-client-go/result-code"
+	"dns-client-go/transport"
+	"dns-client-go/tsig"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// exchange is a request received on a socket, it is the ResponseWriter the chain answers it through.
+// Responses are signed with the TSIG key the request was signed with.
+type exchange struct {
+	s         *server
+	message   []byte
+	received  time.Time
+	local     net.Addr
+	remote    net.Addr
+	conn      net.Conn // the TCP connection, nil for UDP
+	udpSize   int      // responses above are truncated, 0 over TCP
+	send      func(data []byte) error
+	signature *tsig.Signature
+	key       *tsig.Key
+	tsigError *tsig.Error // the signature of the request failed verification
+	closing   bool        // the TCP connection is done
+	err       error
+}
+
+type exchangeKey struct{}
+
+// exchangeFrom returns the exchange of a request, for the middleware working on the wire format
+func exchangeFrom(ctx context.Context) *exchange {
+	return ctx.Value(exchangeKey{}).(*exchange)
+}
+
+func (x *exchange) LocalAddr() net.Addr {
+	return x.local
+}
+
+func (x *exchange) RemoteAddr() net.Addr {
+	return x.remote
+}
+
+// Write serializes, signs and sends a response. Over UDP responses that do not fit a datagram are cut
+// down to the question and flagged as truncated, so the client retries over TCP.
+func (x *exchange) Write(response *dns.DnsPacket) error {
+	data, err := x.serialize(response)
+	if err == nil && x.udpSize > 0 && len(data) > x.udpSize {
+		truncated := *response
+		truncated.Header.TruncatedMessage = true
+		truncated.Answers = nil
+		truncated.Authorities = nil
+		truncated.Resources = nil
+		data, err = x.serialize(&truncated)
+	}
+	if err != nil {
+		x.err = fmt.Errorf("failed to serialize response packet: %w", err)
+		return x.err
+	}
+
+	x.s.tapClient(dnstap.ClientResponse, data, x)
+	if err := x.send(data); err != nil {
+		x.err = fmt.Errorf("failed to send response: %w", err)
+	}
+
+	return x.err
+}
+
+func (x *exchange) serialize(response *dns.DnsPacket) ([]byte, error) {
+	data, err := transport.Serialize(response)
+	if err != nil {
+		return nil, err
+	}
+	if x.tsigError != nil {
+		return tsig.ErrorResponse(data, x.signature, x.key, x.tsigError.Code), nil
+	}
+
+	return signResponse(data, x.signature, x.key), nil
+}
+
+// serve answers the request of an exchange through the chain
+func (s *server) serve(x *exchange) error {
+	s.tapClient(dnstap.ClientQuery, x.message, x)
+	requestBuffer := packetbuffer.NewPacketBufferFrom(x.message)
+	request := dns.NewPacket().FromBuffer(&requestBuffer)
+
+	ctx := context.WithValue(context.Background(), exchangeKey{}, x)
+	s.handler.ServeDNS(ctx, x, request)
+
+	return x.err
+}
+
+// handleQuery answers a request received on a UDP socket
+func (s *server) handleQuery(conn *net.UDPConn, src *net.UDPAddr, message []byte) error {
+	return s.serve(&exchange{
+		s:        s,
+		message:  message,
+		received: time.Now(),
+		local:    conn.LocalAddr(),
+		remote:   src,
+		udpSize:  s.config.Listen.UDPSize,
+		send: func(data []byte) error {
+			_, err := conn.WriteToUDP(data, src)
+			return err
+		},
+	})
+}
+
+// chain assembles the handler answering the requests from the parts of the server
+func (s *server) chain() handler.Handler {
+	return handler.Chain(handler.HandlerFunc(s.resolveQuery),
+		observeQueries,
+		handler.QueryLog(s.queryLog),
+		handler.RateLimit(s.rateLimiter),
+		handler.Refuse(s.refuse),
+		s.limitClients,
+		s.authenticate,
+		s.transfer,
+		s.dispatch,
+		handler.ACL(s.allowQuery),
+		handler.AllowRecursion(s.allowRecursion),
+		handler.ClientGroup(s.groups),
+		handler.Block(s.pause),
+		handler.Static(s.static),
+		handler.Zones(s.zones),
+		handler.SafeSearch(s.pause),
+		handler.RefuseRecursion(),
+		handler.Cache(s.cache),
+		s.shedOverload,
+	)
+}
+
+// authenticate checks the TSIG record of a request. A request whose signature could not be verified is
+// answered with NOTAUTH and the TSIG error (RFC 8945 section 5.2), a malformed TSIG record with FORMERR.
+func (s *server) authenticate(next handler.Handler) handler.Handler {
+	return handler.HandlerFunc(func(ctx context.Context, w handler.ResponseWriter, request *dns.DnsPacket) {
+		x := exchangeFrom(ctx)
+		signature, key, err := s.verifyRequest(x.message)
+		if err == nil {
+			x.signature, x.key = signature, key
+			next.ServeDNS(ctx, w, request)
+			return
+		}
+
+		slog.Warn("rejecting request", "client", handler.ClientIP(w), "error", err)
+		x.closing = true
+
+		response := dns.NewPacket()
+		response.Header.ID = request.Header.ID
+		response.Header.Opcode = request.Header.Opcode
+		response.Header.Response = true
+		response.Question = request.Question
+		response.Header.Rescode = resultcode.FORMERR
+
+		var tsigErr *tsig.Error
+		if errors.As(err, &tsigErr) && signature != nil {
+			x.signature, x.key, x.tsigError = signature, key, tsigErr
+			response.Header.Rescode = resultcode.NOTAUTH
+		}
+		w.Write(response)
+	})
+}
+
+// dispatch passes queries on to the rest of the chain and answers NOTIFY and UPDATE requests
+func (s *server) dispatch(next handler.Handler) handler.Handler {
+	return handler.HandlerFunc(func(ctx context.Context, w handler.ResponseWriter, request *dns.DnsPacket) {
+		if len(request.Question) == 0 {
+			handler.Error(w, request, resultcode.FORMERR)
+			return
+		}
+
+		response := handler.Reply(request)
+		switch request.Header.Opcode {
+		case opcode.QUERY:
+			next.ServeDNS(ctx, w, request)
+			return
+		case opcode.NOTIFY:
+			s.handleNotify(request, response, handler.ClientIP(w), exchangeFrom(ctx).key)
+		case opcode.UPDATE:
+			s.handleUpdate(request, response, handler.ClientIP(w), exchangeFrom(ctx).key)
+		default:
+			response.Header.Rescode = resultcode.NOTIMP
+		}
+		w.Write(response)
+	})
+}
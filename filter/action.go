@@ -0,0 +1,63 @@
+package filter
+
+import (
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+	resultcode "dns-client-go/result-code"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// DefaultBlockTTL is the TTL of the addresses returned for blocked names
+const DefaultBlockTTL = 60
+
+// Action describes how a blocked query is answered: with an error code, or with fixed addresses
+// for A and AAAA queries and an empty answer for other types
+type Action struct {
+	Rescode resultcode.ResultCode
+	IPv4    net.IP // returned for A queries when set
+	IPv6    net.IP // returned for AAAA queries when set
+	TTL     uint32
+}
+
+// ParseAction reads the block response setting: "nxdomain", "refused", "null" for 0.0.0.0 and ::,
+// or an IPv4 or IPv6 address to answer with
+func ParseAction(value string) (Action, error) {
+	action := Action{Rescode: resultcode.NOERROR, TTL: DefaultBlockTTL}
+
+	switch strings.ToLower(value) {
+	case "nxdomain":
+		action.Rescode = resultcode.NXDOMAIN
+	case "refused":
+		action.Rescode = resultcode.REFUSED
+	case "null":
+		action.IPv4 = net.IPv4zero.To4()
+		action.IPv6 = net.IPv6zero
+	default:
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return action, fmt.Errorf("invalid block response %q, expected nxdomain, refused, null or an IP address", value)
+		}
+		if ip.To4() != nil {
+			action.IPv4 = ip.To4()
+		} else {
+			action.IPv6 = ip
+		}
+	}
+
+	return action, nil
+}
+
+// Respond fills the response to a blocked question
+func (a Action) Respond(question dns.DnsQuestion, response *dns.DnsPacket) {
+	response.Header.Rescode = a.Rescode
+	response.Question = append(response.Question, question)
+
+	switch {
+	case question.Qtype == querytype.A && a.IPv4 != nil:
+		response.Answers = append(response.Answers, dns.NewARecord(question.Name, a.IPv4.String(), a.TTL))
+	case question.Qtype == querytype.AAAA && a.IPv6 != nil:
+		response.Answers = append(response.Answers, dns.NewAAAARecord(question.Name, a.IPv6.String(), a.TTL))
+	}
+}
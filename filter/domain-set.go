@@ -0,0 +1,48 @@
+package filter
+
+import "dns-client-go/dns"
+
+// DomainSet matches names against a set of domains, where every domain also covers its subdomains.
+// Lookups walk up the labels of the name, so their cost depends on the name and not on the size of the
+// set, which keeps lists with millions of entries cheap to query.
+type DomainSet struct {
+	domains map[dns.Name]struct{}
+}
+
+func NewDomainSet() *DomainSet {
+	return &DomainSet{domains: map[dns.Name]struct{}{}}
+}
+
+func (ds *DomainSet) Add(domain string) {
+	ds.domains[canonical(domain)] = struct{}{}
+}
+
+func (ds *DomainSet) Len() int {
+	return len(ds.domains)
+}
+
+// Match reports whether name or one of its parent domains is in the set
+func (ds *DomainSet) Match(name dns.Name) bool {
+	if len(ds.domains) == 0 {
+		return false
+	}
+
+	for domain := name.Canonical(); !domain.IsRoot(); domain = domain.Parent() {
+		if _, ok := ds.domains[domain]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Merge adds every domain of other to the set
+func (ds *DomainSet) Merge(other *DomainSet) {
+	for domain := range other.domains {
+		ds.domains[domain] = struct{}{}
+	}
+}
+
+func canonical(name string) dns.Name {
+	return dns.Name(name).Canonical()
+}
@@ -0,0 +1,109 @@
+package filter
+
+import (
+	"dns-client-go/dns"
+	"net"
+)
+
+// Group is the filtering policy of a set of clients, identified by their source networks
+type Group struct {
+	Name       string
+	Networks   []*net.IPNet
+	Blocklists *Lists
+	Allowlists *Lists // every domain of these lists is allowed, whatever the blocklists say
+	Action     Action
+	Upstreams  []string // host:port of the resolvers queries are forwarded to, empty to resolve recursively
+	SafeSearch bool     // rewrite search engines to their safe search variants
+}
+
+func NewGroup(name string) *Group {
+	return &Group{
+		Name:       name,
+		Blocklists: NewLists(nil),
+		Allowlists: NewLists(nil),
+		Action:     Action{TTL: DefaultBlockTTL, IPv4: net.IPv4zero.To4(), IPv6: net.IPv6zero},
+	}
+}
+
+// Blocked reports whether name is covered by a blocked domain and neither by an exception of the
+// blocklists nor by an allowlist
+func (g *Group) Blocked(name dns.Name) bool {
+	blocklist := g.Blocklists.Current()
+	if !blocklist.Blocked.Match(name) || blocklist.Allowed.Match(name) {
+		return false
+	}
+
+	allowlist := g.Allowlists.Current()
+	return !allowlist.Blocked.Match(name) && !allowlist.Allowed.Match(name)
+}
+
+// Groups selects the group of a client. A client belongs to the group with the most specific network
+// containing its address, clients outside of every network to the default group.
+type Groups struct {
+	Default *Group
+	Groups  []*Group
+}
+
+func (gs *Groups) ForClient(ip net.IP) *Group {
+	group, longest := gs.Default, -1
+	for _, candidate := range gs.Groups {
+		for _, network := range candidate.Networks {
+			ones, _ := network.Mask.Size()
+			if network.Contains(ip) && ones > longest {
+				group, longest = candidate, ones
+			}
+		}
+	}
+
+	return group
+}
+
+// All returns the default group followed by the client groups
+func (gs *Groups) All() []*Group {
+	return append([]*Group{gs.Default}, gs.Groups...)
+}
+
+// safeSearchTargets maps search engine domains to the hosts that enforce safe search for them
+var safeSearchTargets = map[dns.Name]dns.Name{
+	"www.bing.com":             "strict.bing.com",
+	"bing.com":                 "strict.bing.com",
+	"duckduckgo.com":           "safe.duckduckgo.com",
+	"www.duckduckgo.com":       "safe.duckduckgo.com",
+	"www.youtube.com":          "restrict.youtube.com",
+	"m.youtube.com":            "restrict.youtube.com",
+	"youtubei.googleapis.com":  "restrict.youtube.com",
+	"youtube.googleapis.com":   "restrict.youtube.com",
+	"www.youtube-nocookie.com": "restrict.youtube.com",
+	"search.yahoo.com":         "safe.search.yahoo.com",
+}
+
+// SafeSearchTarget returns the host that answers for name when safe search is enforced. Google is matched
+// on all of its country domains, e.g. www.google.co.uk.
+func SafeSearchTarget(name dns.Name) (dns.Name, bool) {
+	if target, ok := safeSearchTargets[name.Canonical()]; ok {
+		return target, true
+	}
+
+	labels := name.Canonical().Labels()
+	if len(labels) > 0 && labels[0] == "www" {
+		labels = labels[1:]
+	}
+	if len(labels) > 0 && labels[0] == "google" && isGoogleSuffix(labels[1:]) {
+		return "forcesafesearch.google.com", true
+	}
+
+	return "", false
+}
+
+// isGoogleSuffix accepts the public suffixes Google search is served under: com, a country code,
+// or co or com followed by a country code
+func isGoogleSuffix(labels []string) bool {
+	switch len(labels) {
+	case 1:
+		return labels[0] == "com" || len(labels[0]) == 2
+	case 2:
+		return (labels[0] == "co" || labels[0] == "com") && len(labels[1]) == 2
+	}
+
+	return false
+}
@@ -0,0 +1,91 @@
+package filter
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dns-client-go/dns"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroups_ForClient(t *testing.T) {
+	_, office, _ := net.ParseCIDR("10.0.0.0/8")
+	_, builds, _ := net.ParseCIDR("10.1.0.0/16")
+
+	groups := &Groups{Default: NewGroup("default")}
+	officeGroup, buildGroup := NewGroup("office"), NewGroup("builds")
+	officeGroup.Networks = []*net.IPNet{office}
+	buildGroup.Networks = []*net.IPNet{builds}
+	groups.Groups = []*Group{buildGroup, officeGroup}
+
+	assert.Equal(t, "builds", groups.ForClient(net.ParseIP("10.1.2.3")).Name)
+	assert.Equal(t, "office", groups.ForClient(net.ParseIP("10.2.2.3")).Name)
+	assert.Equal(t, "default", groups.ForClient(net.ParseIP("192.0.2.1")).Name)
+}
+
+func TestGroup_Allowlist(t *testing.T) {
+	dir := t.TempDir()
+	blocklist := filepath.Join(dir, "blocklist.txt")
+	allowlist := filepath.Join(dir, "allowlist.txt")
+	assert.NoError(t, os.WriteFile(blocklist, []byte("||example.com^\n"), 0o644))
+	assert.NoError(t, os.WriteFile(allowlist, []byte("telemetry.example.com\n"), 0o644))
+
+	group := NewGroup("builds")
+	group.Blocklists = NewLists([]string{blocklist})
+	group.Allowlists = NewLists([]string{allowlist})
+	assert.NoError(t, group.Blocklists.Load())
+	assert.NoError(t, group.Allowlists.Load())
+
+	assert.True(t, group.Blocked("ads.example.com"))
+	assert.False(t, group.Blocked("telemetry.example.com"))
+	assert.False(t, group.Blocked("eu.telemetry.example.com"))
+	assert.False(t, NewGroup("default").Blocked("ads.example.com"))
+}
+
+func TestSafeSearchTarget(t *testing.T) {
+	for name, expected := range map[dns.Name]dns.Name{
+		"www.google.com":   "forcesafesearch.google.com",
+		"google.co.uk.":    "forcesafesearch.google.com",
+		"www.bing.com":     "strict.bing.com",
+		"WWW.YouTube.com":  "restrict.youtube.com",
+		"duckduckgo.com":   "safe.duckduckgo.com",
+		"mail.google.com":  "",
+		"www.example.com":  "",
+		"google.example.c": "",
+		`www\.google.com`:  "",
+	} {
+		target, ok := SafeSearchTarget(name)
+		assert.Equal(t, expected, target, name)
+		assert.Equal(t, expected != "", ok, name)
+	}
+}
+
+func TestPause(t *testing.T) {
+	current := time.Unix(1700000000, 0)
+	now = func() time.Time { return current }
+	t.Cleanup(func() { now = time.Now })
+
+	var pause Pause
+	assert.False(t, pause.Paused())
+
+	deadline := current.Add(10 * time.Minute)
+	pause.Set(deadline)
+	paused, until := pause.State()
+	assert.True(t, paused)
+	assert.Equal(t, deadline, until)
+
+	current = deadline
+	assert.False(t, pause.Paused(), "the pause ends at the deadline")
+
+	pause.Set(time.Time{})
+	paused, until = pause.State()
+	assert.True(t, paused)
+	assert.True(t, until.IsZero())
+
+	pause.Resume()
+	assert.False(t, pause.Paused())
+}
@@ -0,0 +1,145 @@
+package filter
+
+import (
+	"bufio"
+	"dns-client-go/dns"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+)
+
+// List holds the domains of a filter list. Adblock exception rules (@@||domain^) end up in Allowed.
+type List struct {
+	Blocked *DomainSet
+	Allowed *DomainSet
+}
+
+func NewList() *List {
+	return &List{Blocked: NewDomainSet(), Allowed: NewDomainSet()}
+}
+
+// hostsLocalNames are the entries of a stock hosts file, which must never be blocked
+var hostsLocalNames = map[dns.Name]bool{
+	"localhost":             true,
+	"localhost.localdomain": true,
+	"local":                 true,
+	"broadcasthost":         true,
+	"ip6-localhost":         true,
+	"ip6-loopback":          true,
+	"ip6-localnet":          true,
+	"ip6-mcastprefix":       true,
+	"ip6-allnodes":          true,
+	"ip6-allrouters":        true,
+	"ip6-allhosts":          true,
+	"0.0.0.0":               true,
+}
+
+// LoadList reads a filter list file, see List.Parse
+func LoadList(path string) (*List, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	list := NewList()
+	if err := list.Parse(file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return list, nil
+}
+
+// Parse adds the entries of a filter list to the list. Each line may be in hosts file format
+// ("0.0.0.0 ads.example.com"), a plain domain ("ads.example.com") or an adblock rule ("||ads.example.com^").
+// Comments starting with # or !, adblock rules with modifiers and rules that do not describe a whole
+// domain are skipped, since they cannot be enforced by a DNS server.
+func (l *List) Parse(reader io.Reader) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = strings.TrimSpace(line[:comment])
+		}
+		if line == "" || strings.HasPrefix(line, "!") || strings.HasPrefix(line, "[") {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "@@||"):
+			if domain, ok := adblockDomain(line[4:]); ok {
+				l.Allowed.Add(domain)
+			}
+		case strings.HasPrefix(line, "||"):
+			if domain, ok := adblockDomain(line[2:]); ok {
+				l.Blocked.Add(domain)
+			}
+		default:
+			fields := strings.Fields(line)
+			if len(fields) > 1 && net.ParseIP(fields[0]) != nil {
+				for _, name := range fields[1:] {
+					if name, ok := listDomain(name); ok && !hostsLocalNames[canonical(name)] {
+						l.Blocked.Add(name)
+					}
+				}
+			} else if len(fields) == 1 {
+				if name, ok := listDomain(strings.TrimPrefix(fields[0], "*.")); ok {
+					l.Blocked.Add(name)
+				}
+			}
+		}
+	}
+
+	return scanner.Err()
+}
+
+// adblockDomain extracts the domain of a "||domain^" rule without the leading bars
+func adblockDomain(rule string) (string, bool) {
+	domain, rest, found := strings.Cut(rule, "^")
+	if !found && strings.HasSuffix(rule, "|") {
+		domain, rest, found = strings.TrimSuffix(rule, "|"), "", true
+	}
+	if !found || (rest != "" && rest != "|") {
+		return "", false
+	}
+
+	return listDomain(domain)
+}
+
+// listDomain returns the domain of a list entry, with Unicode labels converted to A-labels, if the entry is
+// a domain
+func listDomain(name string) (string, bool) {
+	name, err := dns.ToASCII(name)
+	if err != nil || !isDomain(name) {
+		return "", false
+	}
+
+	return name, true
+}
+
+// isDomain accepts names made of letters, digits, hyphens and underscores that contain at least one dot
+func isDomain(name string) bool {
+	name = strings.TrimSuffix(name, ".")
+	if len(name) == 0 || len(name) > 253 || !strings.Contains(name, ".") {
+		return false
+	}
+
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			valid := c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+			if !valid {
+				return false
+			}
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,100 @@
+package filter
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// pollInterval is how often the list files are checked for changes
+const pollInterval = 5 * time.Second
+
+// Lists combines filter list files into a single List, which is rebuilt whenever one of the files changes.
+// Queries read the current List without locking, a reload swaps in a complete new one.
+type Lists struct {
+	files    []string
+	list     atomic.Pointer[List]
+	mu       sync.Mutex // serializes reloads
+	modified map[string]time.Time
+}
+
+func NewLists(files []string) *Lists {
+	lists := &Lists{files: files, modified: map[string]time.Time{}}
+	lists.list.Store(NewList())
+	return lists
+}
+
+// Load reads every list file and installs the combined list. On error the previous list stays in place.
+func (l *Lists) Load() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	combined := NewList()
+	modified := map[string]time.Time{}
+	for _, path := range l.files {
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+
+		list, err := LoadList(path)
+		if err != nil {
+			return err
+		}
+		combined.Blocked.Merge(list.Blocked)
+		combined.Allowed.Merge(list.Allowed)
+		modified[path] = info.ModTime()
+	}
+
+	l.modified = modified
+	l.list.Store(combined)
+	return nil
+}
+
+// Current returns the combined list
+func (l *Lists) Current() *List {
+	return l.list.Load()
+}
+
+// Run reloads the lists whenever the modification time of one of the files changes, until the context is cancelled
+func (l *Lists) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if !l.changed() {
+			continue
+		}
+
+		if err := l.Load(); err != nil {
+			slog.Error("failed to reload filter lists", "error", err)
+			continue
+		}
+
+		list := l.Current()
+		slog.Info("reloaded filter lists", "blocked", list.Blocked.Len(), "allowed", list.Allowed.Len())
+	}
+}
+
+func (l *Lists) changed() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, path := range l.files {
+		info, err := os.Stat(path)
+		if err == nil && !info.ModTime().Equal(l.modified[path]) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,121 @@
+package filter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+	resultcode "dns-client-go/result-code"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDomainSet_Match(t *testing.T) {
+	set := NewDomainSet()
+	set.Add("Ads.Example.com.")
+
+	assert.True(t, set.Match("ads.example.com"))
+	assert.True(t, set.Match("tracker.ads.example.com."))
+	assert.False(t, set.Match("example.com"))
+	assert.False(t, set.Match("badads.example.com"))
+	assert.False(t, NewDomainSet().Match("ads.example.com"))
+
+	// A dot inside a label does not start a parent domain
+	evil := NewDomainSet()
+	evil.Add("evil.com")
+	assert.False(t, evil.Match(`ads\.evil.com`))
+	assert.True(t, evil.Match(`ads\.x.evil.com`))
+}
+
+func TestList_Parse(t *testing.T) {
+	content := `# hosts format
+127.0.0.1 localhost
+0.0.0.0 ads.example.com tracker.example.net # trailing comment
+::1 ip6-localhost
+! adblock format
+[Adblock Plus 2.0]
+||malware.example.org^
+||third-party.example.org^$third-party
+||example.org/path^
+@@||good.malware.example.org^
+plain.example.com
+*.wild.example.com
+||bücher.example^
+not-a-domain
+`
+	list := NewList()
+	assert.NoError(t, list.Parse(strings.NewReader(content)))
+
+	for _, name := range []dns.Name{"ads.example.com", "tracker.example.net", "malware.example.org", "plain.example.com", "a.wild.example.com", "xn--bcher-kva.example"} {
+		assert.True(t, list.Blocked.Match(name), name)
+	}
+	for _, name := range []dns.Name{"localhost", "ip6-localhost", "third-party.example.org", "example.org", "not-a-domain"} {
+		assert.False(t, list.Blocked.Match(name), name)
+	}
+	assert.True(t, list.Allowed.Match("good.malware.example.org"))
+	assert.Equal(t, 6, list.Blocked.Len())
+}
+
+func TestParseAction(t *testing.T) {
+	question := *dns.NewQuestion("ads.example.com", querytype.A)
+
+	action, err := ParseAction("nxdomain")
+	assert.NoError(t, err)
+	response := dns.NewPacket()
+	action.Respond(question, response)
+	assert.Equal(t, resultcode.NXDOMAIN, response.Header.Rescode)
+	assert.Empty(t, response.Answers)
+
+	action, err = ParseAction("null")
+	assert.NoError(t, err)
+	response = dns.NewPacket()
+	action.Respond(*dns.NewQuestion("ads.example.com", querytype.AAAA), response)
+	assert.Equal(t, resultcode.NOERROR, response.Header.Rescode)
+	assert.Equal(t, "ads.example.com. 60 IN AAAA ::", response.Answers[0].String())
+
+	action, err = ParseAction("192.0.2.53")
+	assert.NoError(t, err)
+	response = dns.NewPacket()
+	action.Respond(question, response)
+	assert.Equal(t, "ads.example.com. 60 IN A 192.0.2.53", response.Answers[0].String())
+
+	// Without an IPv6 address AAAA queries get an empty answer
+	response = dns.NewPacket()
+	action.Respond(*dns.NewQuestion("ads.example.com", querytype.AAAA), response)
+	assert.Empty(t, response.Answers)
+
+	_, err = ParseAction("block")
+	assert.Error(t, err)
+}
+
+func TestLists_Reload(t *testing.T) {
+	dir := t.TempDir()
+	blocklist := filepath.Join(dir, "blocklist.txt")
+	exceptions := filepath.Join(dir, "exceptions.txt")
+	assert.NoError(t, os.WriteFile(blocklist, []byte("||example.com^\n"), 0o644))
+	assert.NoError(t, os.WriteFile(exceptions, []byte("@@||www.example.com^\n"), 0o644))
+
+	lists := NewLists([]string{blocklist, exceptions})
+	assert.NoError(t, lists.Load())
+	f := NewGroup("default")
+	f.Blocklists = lists
+	assert.True(t, f.Blocked("ads.example.com"))
+	assert.False(t, f.Blocked("www.example.com"))
+	assert.False(t, lists.changed())
+
+	assert.NoError(t, os.WriteFile(blocklist, []byte("ads.example.net\n"), 0o644))
+	assert.NoError(t, os.Chtimes(blocklist, time.Now(), time.Now().Add(time.Minute)))
+	assert.True(t, lists.changed())
+	assert.NoError(t, lists.Load())
+	assert.False(t, f.Blocked("ads.example.com"))
+	assert.True(t, f.Blocked("ads.example.net"))
+
+	// A list that cannot be read keeps the previous one in place
+	assert.NoError(t, os.Remove(exceptions))
+	assert.Error(t, lists.Load())
+	assert.True(t, f.Blocked("ads.example.net"))
+}
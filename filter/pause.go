@@ -0,0 +1,48 @@
+package filter
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+var now = time.Now
+
+// Pause suspends filtering for all client groups until a deadline. It is shared by the servers that
+// replace each other on reload, so a pause survives a reload.
+type Pause struct {
+	until atomic.Int64 // Unix nanoseconds, 0 when filtering is not paused
+}
+
+// Set pauses filtering until the deadline, the zero time pauses it until Resume
+func (p *Pause) Set(deadline time.Time) {
+	if deadline.IsZero() {
+		p.until.Store(-1)
+		return
+	}
+
+	p.until.Store(deadline.UnixNano())
+}
+
+func (p *Pause) Resume() {
+	p.until.Store(0)
+}
+
+// State reports whether filtering is paused and until when, the deadline is zero for a pause without one
+func (p *Pause) State() (bool, time.Time) {
+	until := p.until.Load()
+	switch {
+	case until == 0:
+		return false, time.Time{}
+	case until < 0:
+		return true, time.Time{}
+	}
+
+	deadline := time.Unix(0, until)
+	return now().Before(deadline), deadline
+}
+
+// Paused reports whether filtering is paused
+func (p *Pause) Paused() bool {
+	paused, _ := p.State()
+	return paused
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"dns-client-go/config"
+	"dns-client-go/dns"
+	"dns-client-go/filter"
+	"dns-client-go/handler"
+	querytype "dns-client-go/query-type"
+	resultcode "dns-client-go/result-code"
+	"dns-client-go/transport"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// newGroup loads the lists of a client group
+func newGroup(settings config.Group) (*filter.Group, error) {
+	group := filter.NewGroup(settings.Name)
+	group.Networks = parseNetworks(settings.Networks)
+	group.Upstreams = withDefaultPorts(settings.Upstreams)
+	group.SafeSearch = settings.SafeSearch
+	group.Blocklists = filter.NewLists(settings.Blocklists)
+	group.Allowlists = filter.NewLists(settings.Allowlists)
+
+	if settings.BlockResponse != "" {
+		action, err := filter.ParseAction(settings.BlockResponse)
+		if err != nil {
+			return nil, err
+		}
+		group.Action = action
+	}
+
+	if err := group.Blocklists.Load(); err != nil {
+		return nil, err
+	}
+	if err := group.Allowlists.Load(); err != nil {
+		return nil, err
+	}
+
+	if len(settings.Blocklists) > 0 || len(settings.Allowlists) > 0 {
+		slog.Info("loaded filter lists", "group", settings.Name,
+			"blocked", group.Blocklists.Current().Blocked.Len(), "allowed", group.Allowlists.Current().Blocked.Len())
+	}
+
+	return group, nil
+}
+
+// resolveQuery answers a query by forwarding it to the upstream resolvers of the client's group, or by
+// resolving it recursively from the root servers. It is the last handler of the chain.
+func (s *server) resolveQuery(ctx context.Context, w handler.ResponseWriter, request *dns.DnsPacket) {
+	question := request.Question[0]
+	trace := handler.TraceFrom(ctx)
+
+	var upstreams []string
+	if group := handler.GroupFrom(ctx); group != nil {
+		upstreams = group.Upstreams
+	}
+
+	var response *dns.DnsPacket
+	var err error
+	if len(upstreams) == 0 {
+		response, err = s.recursiveLookup(question.Name, question.Qtype, trace)
+	} else {
+		response, err = s.forward(question.Name, question.Qtype, upstreams, trace)
+	}
+	if err != nil {
+		handler.Error(w, request, resultcode.SERVFAIL)
+		return
+	}
+
+	handler.Answer(w, request, response)
+}
+
+// forward asks the upstream resolvers until one of them answers. Concurrent
+// queries for the same question to the same upstreams share one exchange.
+func (s *server) forward(qname dns.Name, qtype querytype.QueryType, upstreams []string, trace *handler.Trace) (*dns.DnsPacket, error) {
+	key := flightKey{name: qname.Canonical(), qtype: qtype, upstreams: strings.Join(upstreams, ",")}
+	f, err, _ := s.flights.Do(key, func() (flight, error) {
+		f := flight{trace: &handler.Trace{}}
+		var err error
+		f.response, err = s.exchangeUpstreams(qname, qtype, upstreams, f.trace)
+		return f, err
+	})
+	trace.Merge(f.trace)
+
+	return f.response, err
+}
+
+// exchangeUpstreams tries the upstream resolvers in turn
+func (s *server) exchangeUpstreams(qname dns.Name, qtype querytype.QueryType, upstreams []string, trace *handler.Trace) (*dns.DnsPacket, error) {
+	var err error
+	for _, upstream := range upstreams {
+		query := transport.NewQuery(qname, qtype)
+		query.Header.RecursionDesired = true
+
+		var response *dns.DnsPacket
+		start := time.Now()
+		trace.Contacted(upstream)
+		response, err = transport.Exchange(query, upstream, s.config.Resolver.Timeout, nil)
+		if err == nil && response.Header.TruncatedMessage {
+			response, err = transport.ExchangeTCP(query, upstream, s.config.Resolver.Timeout, nil)
+		}
+		observeUpstream(upstream, start, err)
+		if err == nil {
+			return response, nil
+		}
+
+		slog.Warn("upstream failed to answer", "upstream", upstream, "name", qname, "type", qtype.String(), "error", err)
+	}
+
+	return nil, err
+}
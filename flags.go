@@ -0,0 +1,370 @@
+package main
+
+import (
+	"dns-client-go/config"
+	"dns-client-go/dns"
+	"flag"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// registerFlags defines the command line flags, which override the settings of cfg read from the
+// configuration file. A list flag replaces the list of the file, zone and group flags change the
+// zone or group they name.
+func registerFlags(flags *flag.FlagSet, cfg *config.Config) zoneKeyFlags {
+	flags.String("config", "", "YAML configuration file, flags override its settings")
+
+	udp, tcp := &listFlags{values: &cfg.Listen.UDP}, &listFlags{values: &cfg.Listen.TCP}
+	flags.Var(listenFlags{udp, tcp}, "listen", "accept UDP and TCP queries on host:port (repeatable)")
+	flags.Var(udp, "listen-udp", "accept UDP queries on host:port (repeatable)")
+	flags.Var(tcp, "listen-tcp", "accept TCP queries on host:port (repeatable)")
+	flags.IntVar(&cfg.Listen.UDPSize, "udp-size", cfg.Listen.UDPSize, "largest response sent over UDP, larger ones are truncated")
+	flags.DurationVar(&cfg.Listen.TCPIdleTimeout, "tcp-idle-timeout", cfg.Listen.TCPIdleTimeout, "close TCP connections idle for this long")
+	flags.DurationVar(&cfg.Listen.ShutdownTimeout, "shutdown-timeout", cfg.Listen.ShutdownTimeout, "on SIGTERM or SIGINT wait this long for queries in flight")
+
+	flags.StringVar(&cfg.Resolver.Mode, "resolver-mode", cfg.Resolver.Mode, "resolve names recursively from the root servers or forward them to -upstream resolvers: recursive or forward")
+	flags.Var(upstreamFlags{&listFlags{values: &cfg.Resolver.Upstreams}, &cfg.Resolver.Mode}, "upstream", "forward queries to this resolver (host[:port]) instead of resolving them recursively (repeatable)")
+	flags.Var(&listFlags{values: &cfg.Resolver.RootHints}, "root-hint", "address of a root server to start recursive resolution at (repeatable)")
+	flags.DurationVar(&cfg.Resolver.Timeout, "timeout", cfg.Resolver.Timeout, "timeout of a single query to an upstream or authoritative server")
+	flags.StringVar(&cfg.Resolver.QnameMinimisation, "qname-minimisation", cfg.Resolver.QnameMinimisation, "only send authoritative servers the labels they need to refer to the next zone: off, relaxed or strict")
+	flags.BoolVar(&cfg.Resolver.CaseRandomization, "case-randomization", cfg.Resolver.CaseRandomization, "randomize the case of the names sent to authoritative servers and drop responses that do not echo it")
+
+	flags.IntVar(&cfg.Cache.Size, "cache-size", cfg.Cache.Size, "number of responses kept in the cache, 0 disables it")
+	flags.Var((*uint32Flag)(&cfg.Cache.MinTTL), "cache-min-ttl", "lowest TTL in seconds responses are cached for")
+	flags.Var((*uint32Flag)(&cfg.Cache.MaxTTL), "cache-max-ttl", "highest TTL in seconds responses are cached for")
+	flags.Var((*uint32Flag)(&cfg.Cache.NegativeTTL), "cache-negative-ttl", "highest TTL in seconds NXDOMAIN and NODATA responses are cached for")
+
+	flags.StringVar(&cfg.Logging.Level, "log-level", cfg.Logging.Level, "least severe messages logged: debug, info, warn or error")
+	flags.StringVar(&cfg.Logging.Format, "log-format", cfg.Logging.Format, "log format: text or json")
+	flags.StringVar(&cfg.Logging.QueryLog.File, "query-log", cfg.Logging.QueryLog.File, "write one JSON line per client query to this file, - for standard output")
+	flags.BoolVar(&cfg.Logging.QueryLog.AnonymizeClientIP, "query-log-anonymize", cfg.Logging.QueryLog.AnonymizeClientIP, "truncate client addresses in the query log to /24 for IPv4 and /48 for IPv6")
+	flags.StringVar(&cfg.Metrics.Listen, "metrics-listen", cfg.Metrics.Listen, "serve Prometheus metrics at /metrics on host:port")
+	flags.StringVar(&cfg.Dnstap.Output, "dnstap", cfg.Dnstap.Output, "write dnstap messages to a file, or to a collector's socket as unix:path")
+	flags.StringVar(&cfg.Dnstap.Identity, "dnstap-identity", cfg.Dnstap.Identity, "server identity sent in dnstap messages, the host name by default")
+	flags.StringVar(&cfg.Admin.Listen, "admin-listen", cfg.Admin.Listen, "serve the admin API on a loopback host:port")
+	flags.StringVar(&cfg.Admin.Token, "admin-token", cfg.Admin.Token, "token the admin API requires, better set in the configuration file")
+
+	flags.Var(&listFlags{values: &cfg.ACL.Refuse}, "refuse", "refuse every request from this network, as cidr (repeatable)")
+	flags.Var(&listFlags{values: &cfg.ACL.AllowQuery}, "allow-query", "only answer queries from this network, as cidr (repeatable)")
+	flags.Var(&listFlags{values: &cfg.ACL.AllowRecursion}, "allow-recursion", "only resolve names outside of the local data for this network, as cidr (repeatable)")
+	flags.Var(&listFlags{values: &cfg.ACL.AllowTransfer}, "allow-transfer", "allow transfers of every primary zone to this network, as cidr (repeatable)")
+
+	flags.IntVar(&cfg.RateLimit.ResponsesPerSecond, "rate-limit", cfg.RateLimit.ResponsesPerSecond, "UDP responses per second to a client network, 0 disables rate limiting")
+	flags.IntVar(&cfg.RateLimit.Slip, "rate-limit-slip", cfg.RateLimit.Slip, "send every n-th rate limited response truncated instead of dropping it, 0 drops them all")
+	flags.BoolVar(&cfg.RateLimit.LogOnly, "rate-limit-log-only", cfg.RateLimit.LogOnly, "only log the responses rate limiting would drop")
+
+	flags.IntVar(&cfg.Limits.ClientQueriesPerSecond, "client-qps", cfg.Limits.ClientQueriesPerSecond, "queries per second a client address may send, 0 disables the limit")
+	flags.IntVar(&cfg.Limits.ClientBurst, "client-burst", cfg.Limits.ClientBurst, "queries a client address may send at once, the client-qps by default")
+	flags.IntVar(&cfg.Limits.MaxInflight, "max-inflight", cfg.Limits.MaxInflight, "queries being answered above which the ones needing resolution are shed, 0 disables the cap")
+	flags.StringVar(&cfg.Limits.ShedAction, "shed-action", cfg.Limits.ShedAction, "what happens to shed queries: refuse or drop")
+
+	flags.StringVar(&cfg.TSIGKeys, "tsig-keys", cfg.TSIGKeys, "file with TSIG keys, one \"name algorithm base64-secret\" per line")
+	flags.Var(secondaryFlags{cfg}, "secondary", "transfer a zone from its primaries, as zone=primary[,primary] (repeatable)")
+	flags.Var(zoneFlag{cfg, "file", func(zone *config.PrimaryZone, value string) {
+		zone.File = value
+	}}, "primary", "serve a zone from a master file, as zone=file (repeatable)")
+	flags.Var(zoneFlag{cfg, "secondary[,secondary]", func(zone *config.PrimaryZone, value string) {
+		zone.AlsoNotify = append(zone.AlsoNotify, strings.Split(value, ",")...)
+	}}, "also-notify", "notify secondaries of changes to a primary zone, as zone=secondary[,secondary] (repeatable)")
+	flags.Var(zoneFlag{cfg, "cidr[,cidr]", func(zone *config.PrimaryZone, value string) {
+		zone.AllowUpdate = append(zone.AllowUpdate, strings.Split(value, ",")...)
+	}}, "allow-update", "accept dynamic updates to a primary zone, as zone=cidr[,cidr] (repeatable)")
+	flags.Var(zoneFlag{cfg, "key[,key]", func(zone *config.PrimaryZone, value string) {
+		zone.UpdateKeys = append(zone.UpdateKeys, strings.Split(value, ",")...)
+	}}, "update-key", "accept dynamic updates to a primary zone signed with a TSIG key, as zone=key[,key] (repeatable)")
+	zoneKeys := zoneKeyFlags{}
+	flags.Var(zoneKeys, "zone-key", "TSIG key for transfers and NOTIFY of a primary or secondary zone, as zone=key (repeatable)")
+
+	policy := &cfg.Filtering.Policy
+	flags.Var(&listFlags{values: &policy.Blocklists}, "blocklist", "block the domains of a hosts, plain domain or adblock list file (repeatable)")
+	flags.Var(&listFlags{values: &policy.Allowlists}, "allowlist", "never block the domains of a list file (repeatable)")
+	flags.StringVar(&policy.BlockResponse, "block-response", policy.BlockResponse, "answer to blocked queries: nxdomain, refused, null (0.0.0.0 and ::) or an IP address")
+	flags.BoolVar(&policy.SafeSearch, "safe-search", policy.SafeSearch, "enforce safe search on search engines")
+	flags.Var(groupFlag{cfg, "cidr[,cidr]", func(group *config.Group, value string) {
+		group.Networks = append(group.Networks, strings.Split(value, ",")...)
+	}}, "client-group", "define a group of clients by their source networks, as group=cidr[,cidr] (repeatable)")
+	flags.Var(groupFlag{cfg, "file", func(group *config.Group, value string) {
+		group.Blocklists = append(group.Blocklists, value)
+	}}, "group-blocklist", "blocklist of a client group, as group=file (repeatable)")
+	flags.Var(groupFlag{cfg, "file", func(group *config.Group, value string) {
+		group.Allowlists = append(group.Allowlists, value)
+	}}, "group-allowlist", "allowlist of a client group, as group=file (repeatable)")
+	flags.Var(groupFlag{cfg, "response", func(group *config.Group, value string) {
+		group.BlockResponse = value
+	}}, "group-block-response", "answer to blocked queries of a client group, as group=response")
+	flags.Var(groupFlag{cfg, "resolver", func(group *config.Group, value string) {
+		group.Upstreams = append(group.Upstreams, value)
+	}}, "group-upstream", "forward the queries of a client group to a resolver, as group=host[:port] (repeatable)")
+	flags.Var(groupSafeSearchFlags{cfg}, "group-safe-search", "enforce safe search for a client group (repeatable)")
+
+	flags.Var(&listFlags{values: &cfg.Static.Records}, "static", "answer a name with a fixed A, AAAA, CNAME, TXT or PTR record, as \"name [ttl] type data\" (repeatable)")
+	flags.StringVar(&cfg.Static.Hosts, "hosts", cfg.Static.Hosts, "answer the names of a file in /etc/hosts format, including their PTR records")
+	flags.Var((*uint32Flag)(&cfg.Static.HostsTTL), "hosts-ttl", "TTL of the records from the hosts file")
+
+	return zoneKeys
+}
+
+// loadConfig reads the configuration file named by -config, applies the flags to it, converts zone names
+// to A-labels and validates the result. It runs on startup with the command line flag set and on every reload with a fresh one.
+func loadConfig(flags *flag.FlagSet, args []string) (*config.Config, error) {
+	cfg := config.Default()
+	if path := configPath(args); path != "" {
+		loaded, err := config.Load(path)
+		if err != nil {
+			return nil, err
+		}
+		cfg = loaded
+	}
+
+	zoneKeys := registerFlags(flags, cfg)
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	cfg.ASCIIZoneNames()
+	if err := zoneKeys.apply(cfg); err != nil {
+		return nil, err
+	}
+
+	return cfg, cfg.Validate()
+}
+
+// configPath finds the -config flag before the flags are parsed, so that they can override the file
+func configPath(args []string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if !strings.HasPrefix(arg, "-") || name != "config" {
+			continue
+		}
+		if hasValue {
+			return value
+		}
+		if i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+
+	return ""
+}
+
+// listFlags collects the values of a repeatable flag. The first value given replaces the list
+// from the configuration file.
+type listFlags struct {
+	values *[]string
+	set    bool
+}
+
+func (lf *listFlags) String() string {
+	if lf.values == nil {
+		return ""
+	}
+
+	return strings.Join(*lf.values, ",")
+}
+
+func (lf *listFlags) Set(value string) error {
+	if !lf.set {
+		*lf.values = nil
+		lf.set = true
+	}
+
+	*lf.values = append(*lf.values, value)
+	return nil
+}
+
+// listenFlags adds the address of -listen to the UDP and the TCP listeners
+type listenFlags struct {
+	udp *listFlags
+	tcp *listFlags
+}
+
+func (lf listenFlags) String() string {
+	return ""
+}
+
+func (lf listenFlags) Set(value string) error {
+	if err := lf.udp.Set(value); err != nil {
+		return err
+	}
+
+	return lf.tcp.Set(value)
+}
+
+// upstreamFlags collects the -upstream resolvers, which switch the resolver to forward mode
+type upstreamFlags struct {
+	upstreams *listFlags
+	mode      *string
+}
+
+func (uf upstreamFlags) String() string {
+	return ""
+}
+
+func (uf upstreamFlags) Set(value string) error {
+	*uf.mode = config.ModeForward
+	return uf.upstreams.Set(value)
+}
+
+// uint32Flag sets a TTL or another 32 bit setting
+type uint32Flag uint32
+
+func (uf *uint32Flag) String() string {
+	if uf == nil {
+		return "0"
+	}
+
+	return strconv.FormatUint(uint64(*uf), 10)
+}
+
+func (uf *uint32Flag) Set(value string) error {
+	parsed, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return err
+	}
+
+	*uf = uint32Flag(parsed)
+	return nil
+}
+
+// secondaryFlags sets the primaries of -secondary zone=primary[,primary] flags
+type secondaryFlags struct {
+	cfg *config.Config
+}
+
+func (sf secondaryFlags) String() string {
+	return ""
+}
+
+func (sf secondaryFlags) Set(value string) error {
+	name, primaries, found := strings.Cut(value, "=")
+	if !found || name == "" || primaries == "" {
+		return fmt.Errorf("expected zone=primary[,primary], got %q", value)
+	}
+
+	sf.cfg.Secondary(name).Primaries = strings.Split(primaries, ",")
+	return nil
+}
+
+// zoneFlag sets one setting of a primary zone from a repeatable zone=value flag
+type zoneFlag struct {
+	cfg   *config.Config
+	usage string // the expected form of the value
+	set   func(zone *config.PrimaryZone, value string)
+}
+
+func (zf zoneFlag) String() string {
+	return ""
+}
+
+func (zf zoneFlag) Set(value string) error {
+	name, setting, found := strings.Cut(value, "=")
+	if !found || name == "" || setting == "" {
+		return fmt.Errorf("expected zone=%v, got %q", zf.usage, value)
+	}
+
+	zf.set(zf.cfg.Primary(name), setting)
+	return nil
+}
+
+// groupFlag sets one setting of a client group from a repeatable group=value flag
+type groupFlag struct {
+	cfg   *config.Config
+	usage string // the expected form of the value
+	set   func(group *config.Group, value string)
+}
+
+func (gf groupFlag) String() string {
+	return ""
+}
+
+func (gf groupFlag) Set(value string) error {
+	name, setting, found := strings.Cut(value, "=")
+	if !found || name == "" || setting == "" {
+		return fmt.Errorf("expected group=%v, got %q", gf.usage, value)
+	}
+
+	gf.set(gf.cfg.Group(name), setting)
+	return nil
+}
+
+// groupSafeSearchFlags enables safe search for the groups named by -group-safe-search
+type groupSafeSearchFlags struct {
+	cfg *config.Config
+}
+
+func (sf groupSafeSearchFlags) String() string {
+	return ""
+}
+
+func (sf groupSafeSearchFlags) Set(value string) error {
+	sf.cfg.Group(value).SafeSearch = true
+	return nil
+}
+
+// zoneKeyFlags collects repeated -zone-key zone=key flags naming the TSIG key of a primary or secondary zone
+type zoneKeyFlags map[dns.Name]string
+
+func (zf zoneKeyFlags) String() string {
+	return fmt.Sprint(map[dns.Name]string(zf))
+}
+
+func (zf zoneKeyFlags) Set(value string) error {
+	name, key, found := strings.Cut(value, "=")
+	if !found || name == "" || key == "" {
+		return fmt.Errorf("expected zone=key, got %q", value)
+	}
+
+	ascii, err := dns.ToASCII(name)
+	if err != nil {
+		return err
+	}
+
+	zf[dns.Name(ascii).Canonical()] = key
+	return nil
+}
+
+// apply sets the keys on the zones, which may be defined by flags following -zone-key
+func (zf zoneKeyFlags) apply(cfg *config.Config) error {
+	for name, key := range zf {
+		found := false
+		for i := range cfg.Zones.Primary {
+			if name.Equal(dns.Name(cfg.Zones.Primary[i].Name)) {
+				cfg.Zones.Primary[i].Key, found = key, true
+			}
+		}
+		for i := range cfg.Zones.Secondary {
+			if name.Equal(dns.Name(cfg.Zones.Secondary[i].Name)) {
+				cfg.Zones.Secondary[i].Key, found = key, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("-zone-key names zone %v, which is neither a primary nor a secondary zone", name)
+		}
+	}
+
+	return nil
+}
+
+// withDefaultPort adds the standard DNS port to addresses given without one
+func withDefaultPort(address string) string {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		return net.JoinHostPort(address, "53")
+	}
+
+	return address
+}
+
+// withDefaultPorts applies withDefaultPort to a list of addresses
+func withDefaultPorts(addresses []string) []string {
+	var result []string
+	for _, address := range addresses {
+		result = append(result, withDefaultPort(address))
+	}
+
+	return result
+}
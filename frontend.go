@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"dns-client-go/config"
+	packetbuffer "dns-client-go/packetbuffer"
+	"dns-client-go/transport"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// frontend owns the sockets and hands every request to the current server, which is replaced when the
+// configuration is reloaded. On shutdown it stops reading requests and waits for those in flight.
+type frontend struct {
+	current   atomic.Pointer[server]
+	conns     []*net.UDPConn
+	listeners []*net.TCPListener
+	inflight  sync.WaitGroup // requests being answered, TCP connections and the socket readers
+	draining  atomic.Bool
+	mu        sync.Mutex
+	tcpConns  map[*net.TCPConn]struct{}
+	reloading sync.Mutex // reloads come from SIGHUP and the admin API
+	started   time.Time
+}
+
+func newFrontend(s *server, conns []*net.UDPConn, listeners []*net.TCPListener) *frontend {
+	f := &frontend{conns: conns, listeners: listeners, tcpConns: map[*net.TCPConn]struct{}{}, started: time.Now()}
+	f.current.Store(s)
+	return f
+}
+
+// serve reads requests from all sockets in the background
+func (f *frontend) serve() {
+	for _, conn := range f.conns {
+		f.inflight.Add(1)
+		go f.serveUDP(conn)
+	}
+
+	for _, listener := range f.listeners {
+		f.inflight.Add(1)
+		go f.serveTCP(listener)
+	}
+}
+
+// serveUDP answers every datagram in its own goroutine, so a slow recursive lookup does not hold up
+// the other clients
+func (f *frontend) serveUDP(conn *net.UDPConn) {
+	defer f.inflight.Done()
+	buffer := make([]byte, packetbuffer.MaxMessageSize)
+
+	for {
+		n, src, err := conn.ReadFromUDP(buffer)
+		if f.draining.Load() {
+			return
+		}
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			slog.Error("failed to read from UDP socket", "error", err)
+			continue
+		}
+
+		message := append([]byte(nil), buffer[:n]...)
+		f.inflight.Add(1)
+		go func() {
+			defer f.inflight.Done()
+			s := f.current.Load()
+			defer s.trackQuery()()
+			if err := s.handleQuery(conn, src, message); err != nil {
+				slog.Error("failed to handle query", "client", src.IP, "error", err)
+			}
+		}()
+	}
+}
+
+func (f *frontend) serveTCP(listener *net.TCPListener) {
+	defer f.inflight.Done()
+
+	for {
+		conn, err := listener.AcceptTCP()
+		if err != nil {
+			if f.draining.Load() || errors.Is(err, net.ErrClosed) {
+				return
+			}
+			slog.Error("failed to accept TCP connection", "error", err)
+			continue
+		}
+
+		f.inflight.Add(1)
+		go func() {
+			defer f.inflight.Done()
+			if err := f.handleTCPConnection(conn); err != nil {
+				slog.Error("failed to handle TCP connection", "client", conn.RemoteAddr(), "error", err)
+			}
+		}()
+	}
+}
+
+// handleTCPConnection answers length prefixed messages until the client closes the connection, goes
+// idle or the server shuts down
+func (f *frontend) handleTCPConnection(conn *net.TCPConn) error {
+	f.mu.Lock()
+	f.tcpConns[conn] = struct{}{}
+	f.mu.Unlock()
+
+	defer func() {
+		f.mu.Lock()
+		delete(f.tcpConns, conn)
+		f.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		s := f.current.Load()
+		conn.SetDeadline(time.Now().Add(s.config.Listen.TCPIdleTimeout))
+		// Checked after setting the deadline, which would otherwise override the one set by shutdown
+		if f.draining.Load() {
+			return nil
+		}
+
+		requestBuffer, err := transport.ReadMessage(conn)
+		if errors.Is(err, io.EOF) || (err != nil && f.draining.Load()) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read from TCP connection: %w", err)
+		}
+
+		tracked := s.trackQuery()
+		done, err := s.handleTCPMessage(conn, requestBuffer)
+		tracked()
+		if done || err != nil {
+			return err
+		}
+	}
+}
+
+// reload builds a server from cfg and swaps it in. The sockets are kept, so changed listen addresses
+// only take effect on restart, and so is the cache.
+func (f *frontend) reload(ctx context.Context, cfg *config.Config) error {
+	f.reloading.Lock()
+	defer f.reloading.Unlock()
+
+	previous := f.current.Load()
+	next, err := newServer(cfg, previous)
+	if err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(cfg.Listen.UDP, previous.config.Listen.UDP) || !reflect.DeepEqual(cfg.Listen.TCP, previous.config.Listen.TCP) {
+		slog.Warn("listen addresses changed, restart the server to apply them")
+		next.config.Listen.UDP, next.config.Listen.TCP = previous.config.Listen.UDP, previous.config.Listen.TCP
+	}
+	if cfg.Metrics.Listen != previous.config.Metrics.Listen {
+		slog.Warn("metrics address changed, restart the server to apply it")
+		next.config.Metrics.Listen = previous.config.Metrics.Listen
+	}
+	if cfg.Admin.Listen != previous.config.Admin.Listen {
+		slog.Warn("admin API address changed, restart the server to apply it")
+		next.config.Admin.Listen = previous.config.Admin.Listen
+	}
+	next.cache.Resize(cfg.Cache.Size, cfg.Cache.MinTTL, cfg.Cache.MaxTTL, cfg.Cache.NegativeTTL)
+	setupLogging(cfg.Logging)
+
+	next.start(ctx)
+	f.current.Store(next)
+	previous.stop(next)
+
+	return nil
+}
+
+// shutdown stops accepting requests, waits up to timeout for the ones in flight and closes the sockets.
+// It reports whether all requests were answered in time.
+func (f *frontend) shutdown(timeout time.Duration) bool {
+	f.draining.Store(true)
+
+	for _, listener := range f.listeners {
+		listener.Close()
+	}
+	for _, conn := range f.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+	f.mu.Lock()
+	for conn := range f.tcpConns {
+		conn.SetReadDeadline(time.Now())
+	}
+	f.mu.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		f.inflight.Wait()
+		close(drained)
+	}()
+
+	finished := true
+	select {
+	case <-drained:
+	case <-time.After(timeout):
+		finished = false
+	}
+
+	for _, conn := range f.conns {
+		conn.Close()
+	}
+	f.mu.Lock()
+	for conn := range f.tcpConns {
+		conn.Close()
+	}
+	f.mu.Unlock()
+
+	return finished
+}
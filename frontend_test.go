@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+	"dns-client-go/transport"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeTestConfig writes a configuration listening on loopback, answering www.staging with address and
+// forwarding the other queries to upstream
+func writeTestConfig(t *testing.T, path string, address string, upstream string) {
+	data := fmt.Sprintf(`listen:
+  udp: ["127.0.0.1:0"]
+  tcp: ["127.0.0.1:0"]
+resolver:
+  mode: forward
+  upstreams: [%q]
+static:
+  records: ["www.staging A %v"]
+`, upstream, address)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatalf("failed to write the configuration: %v", err)
+	}
+}
+
+func ask(t *testing.T, address string, name dns.Name, tcp bool) []net.IP {
+	query := transport.NewQuery(name, querytype.A)
+	query.Header.RecursionDesired = true
+
+	exchange := transport.Exchange
+	if tcp {
+		exchange = transport.ExchangeTCP
+	}
+	response, err := exchange(query, address, time.Second, nil)
+	if !assert.NoError(t, err, name) {
+		return nil
+	}
+	return response.GetAddresses()
+}
+
+func TestFrontend_ReloadAndDrain(t *testing.T) {
+	// The upstream resolver answers slowly, so that a query is in flight when the server drains
+	asked := make(chan struct{}, 1)
+	fakeNameServer(t, func(request *dns.DnsPacket) *dns.DnsPacket {
+		asked <- struct{}{}
+		time.Sleep(200 * time.Millisecond)
+		response := dns.NewPacket()
+		response.Question = request.Question
+		response.Answers = []dns.DnsRecord{dns.NewARecord(request.Question[0].Name, "192.0.2.10", 300)}
+		return response
+	})
+	upstream := net.JoinHostPort("127.0.0.1", nameServerPort)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeTestConfig(t, path, "10.0.0.1", upstream)
+	args := os.Args
+	os.Args = []string{"dns-client-go", "-config", path}
+	t.Cleanup(func() { os.Args = args })
+
+	cfg, err := loadConfig(flag.NewFlagSet(os.Args[0], flag.ContinueOnError), os.Args[1:])
+	if err != nil {
+		t.Fatalf("failed to load the configuration: %v", err)
+	}
+	s, err := newServer(cfg, nil)
+	if err != nil {
+		t.Fatalf("failed to build the server: %v", err)
+	}
+	conns, listeners, err := s.listen()
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.start(ctx)
+	f := newFrontend(s, conns, listeners)
+	f.serve()
+
+	udp, tcp := conns[0].LocalAddr().String(), listeners[0].Addr().String()
+	assert.Equal(t, "10.0.0.1", ask(t, udp, "www.staging", false)[0].String())
+	assert.Equal(t, "10.0.0.1", ask(t, tcp, "www.staging", true)[0].String())
+
+	// A reload, as on SIGHUP, swaps in a server built from the changed file on the same sockets
+	writeTestConfig(t, path, "10.0.0.2", upstream)
+	assert.NoError(t, reload(ctx, f))
+	next := f.current.Load()
+	assert.NotSame(t, s, next)
+	assert.Same(t, s.cache, next.cache, "the cache is taken over")
+	assert.Same(t, s.inflight, next.inflight)
+	assert.Equal(t, "10.0.0.2", ask(t, udp, "www.staging", false)[0].String())
+	assert.Equal(t, "10.0.0.2", ask(t, tcp, "www.staging", true)[0].String())
+
+	// A broken file keeps the current server
+	assert.NoError(t, os.WriteFile(path, []byte("listen: [\n"), 0o644))
+	assert.Error(t, reload(ctx, f))
+	assert.Same(t, next, f.current.Load())
+
+	// Draining, as on SIGTERM, answers the query in flight and then stops serving
+	answered := make(chan []net.IP, 1)
+	go func() {
+		answered <- ask(t, udp, "slow.example", false)
+	}()
+	<-asked
+	assert.True(t, f.shutdown(2*time.Second))
+	assert.Equal(t, "192.0.2.10", (<-answered)[0].String())
+
+	query := transport.NewQuery("www.staging", querytype.A)
+	_, err = transport.Exchange(query, udp, 200*time.Millisecond, nil)
+	assert.Error(t, err, "no queries are answered after draining")
+	_, err = transport.ExchangeTCP(query, tcp, 200*time.Millisecond, nil)
+	assert.Error(t, err)
+}
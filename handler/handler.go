@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"context"
+	"dns-client-go/dns"
+	"dns-client-go/filter"
+	resultcode "dns-client-go/result-code"
+	"net"
+)
+
+// ResponseWriter sends the response to a request back to the client
+type ResponseWriter interface {
+	// LocalAddr is the address the request was received on
+	LocalAddr() net.Addr
+	// RemoteAddr is the address of the client, a *net.UDPAddr or a *net.TCPAddr
+	RemoteAddr() net.Addr
+	// Write serializes the response and sends it, it is called once per request
+	Write(response *dns.DnsPacket) error
+}
+
+// Handler answers DNS requests, in the spirit of net/http
+type Handler interface {
+	ServeDNS(ctx context.Context, w ResponseWriter, request *dns.DnsPacket)
+}
+
+// HandlerFunc adapts a function to a Handler
+type HandlerFunc func(ctx context.Context, w ResponseWriter, request *dns.DnsPacket)
+
+func (f HandlerFunc) ServeDNS(ctx context.Context, w ResponseWriter, request *dns.DnsPacket) {
+	f(ctx, w, request)
+}
+
+// Middleware answers some requests itself and passes the others on to next
+type Middleware func(next Handler) Handler
+
+// Chain puts the middleware in front of h. The first middleware sees requests first.
+func Chain(h Handler, middleware ...Middleware) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		h = middleware[i](h)
+	}
+
+	return h
+}
+
+// Reply returns an empty response to request
+func Reply(request *dns.DnsPacket) *dns.DnsPacket {
+	response := dns.NewPacket()
+	response.Header.ID = request.Header.ID
+	response.Header.Opcode = request.Header.Opcode
+	response.Header.RecursionDesired = true
+	response.Header.RecursionAvailable = true
+	response.Header.Response = true
+
+	return response
+}
+
+// Error answers request with rcode and its question
+func Error(w ResponseWriter, request *dns.DnsPacket, rcode resultcode.ResultCode) error {
+	response := Reply(request)
+	response.Header.Rescode = rcode
+	response.Question = append(response.Question, request.Question...)
+
+	return w.Write(response)
+}
+
+// Answer answers request with the response code and the records of result, the response of a resolver
+func Answer(w ResponseWriter, request *dns.DnsPacket, result *dns.DnsPacket) error {
+	response := Reply(request)
+	response.Header.Rescode = result.Header.Rescode
+	response.Question = append(response.Question, request.Question...)
+	response.Answers = result.Answers
+	response.Authorities = result.Authorities
+	response.Resources = result.Resources
+
+	return w.Write(response)
+}
+
+// ClientIP returns the IP address of the client
+func ClientIP(w ResponseWriter) net.IP {
+	switch addr := w.RemoteAddr().(type) {
+	case *net.UDPAddr:
+		return addr.IP
+	case *net.TCPAddr:
+		return addr.IP
+	}
+
+	return nil
+}
+
+// Recorder keeps the response instead of sending it. Middleware uses it to ask the rest of the chain
+// another question, such as the target of a CNAME.
+type Recorder struct {
+	ResponseWriter
+	Response *dns.DnsPacket // nil until written
+}
+
+func (r *Recorder) Write(response *dns.DnsPacket) error {
+	r.Response = response
+	return nil
+}
+
+// Resolve passes the question of request for name to h and returns the response it wrote, a SERVFAIL
+// when it wrote none
+func Resolve(ctx context.Context, h Handler, w ResponseWriter, request *dns.DnsPacket, name dns.Name) *dns.DnsPacket {
+	query := *request
+	query.Question = []dns.DnsQuestion{*dns.NewQuestion(name, request.Question[0].Qtype)}
+
+	recorder := &Recorder{ResponseWriter: w}
+	h.ServeDNS(ctx, recorder, &query)
+	if recorder.Response == nil {
+		failed := Reply(&query)
+		failed.Header.Rescode = resultcode.SERVFAIL
+		return failed
+	}
+
+	return recorder.Response
+}
+
+// observer calls a function with every response before writing it
+type observer struct {
+	ResponseWriter
+	observe func(response *dns.DnsPacket)
+}
+
+func (o *observer) Write(response *dns.DnsPacket) error {
+	o.observe(response)
+	return o.ResponseWriter.Write(response)
+}
+
+// Observe returns a ResponseWriter that calls observe with the response before writing it to w
+func Observe(w ResponseWriter, observe func(response *dns.DnsPacket)) ResponseWriter {
+	return &observer{ResponseWriter: w, observe: observe}
+}
+
+type contextKey int
+
+const (
+	groupKey contextKey = iota
+	recursionKey
+	traceKey
+)
+
+// WithGroup returns a context carrying the filtering group of the client
+func WithGroup(ctx context.Context, group *filter.Group) context.Context {
+	return context.WithValue(ctx, groupKey, group)
+}
+
+// GroupFrom returns the filtering group of the client, nil when no middleware selected one
+func GroupFrom(ctx context.Context) *filter.Group {
+	group, _ := ctx.Value(groupKey).(*filter.Group)
+	return group
+}
+
+// WithRecursion returns a context recording whether the client may ask for recursion
+func WithRecursion(ctx context.Context, allowed bool) context.Context {
+	return context.WithValue(ctx, recursionKey, allowed)
+}
+
+// RecursionAllowed reports whether the client may ask for recursion, which it may unless a middleware
+// decided otherwise
+func RecursionAllowed(ctx context.Context) bool {
+	allowed, ok := ctx.Value(recursionKey).(bool)
+	return !ok || allowed
+}
@@ -0,0 +1,192 @@
+package handler
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dns-client-go/cache"
+	"dns-client-go/dns"
+	"dns-client-go/filter"
+	querylog "dns-client-go/query-log"
+	querytype "dns-client-go/query-type"
+	ratelimit "dns-client-go/rate-limit"
+	resultcode "dns-client-go/result-code"
+	"dns-client-go/static"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testWriter keeps the responses written for a client at 192.0.2.1
+type testWriter struct {
+	responses []*dns.DnsPacket
+}
+
+func (w *testWriter) LocalAddr() net.Addr {
+	return &net.UDPAddr{IP: net.ParseIP("192.0.2.53"), Port: 53}
+}
+
+func (w *testWriter) RemoteAddr() net.Addr {
+	return &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 5353}
+}
+
+func (w *testWriter) Write(response *dns.DnsPacket) error {
+	w.responses = append(w.responses, response)
+	return nil
+}
+
+func query(name dns.Name, qtype querytype.QueryType) *dns.DnsPacket {
+	request := dns.NewPacket()
+	request.Header.ID = 42
+	request.Header.RecursionDesired = true
+	request.Question = append(request.Question, *dns.NewQuestion(name, qtype))
+	return request
+}
+
+// resolver answers every question with an A record and counts the questions it was asked
+type resolver struct {
+	asked []dns.Name
+}
+
+func (r *resolver) ServeDNS(ctx context.Context, w ResponseWriter, request *dns.DnsPacket) {
+	question := request.Question[0]
+	r.asked = append(r.asked, question.Name)
+	TraceFrom(ctx).Contacted("192.0.2.200:53")
+
+	result := dns.NewPacket()
+	result.Answers = append(result.Answers, dns.NewARecord(question.Name, "198.51.100.1", 300))
+	Answer(w, request, result)
+}
+
+func serve(h Handler, request *dns.DnsPacket) *dns.DnsPacket {
+	w := &testWriter{}
+	h.ServeDNS(context.Background(), w, request)
+	if len(w.responses) != 1 {
+		return nil
+	}
+	return w.responses[0]
+}
+
+func TestChain_Order(t *testing.T) {
+	var order []string
+	middleware := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return HandlerFunc(func(ctx context.Context, w ResponseWriter, request *dns.DnsPacket) {
+				order = append(order, name)
+				next.ServeDNS(ctx, w, request)
+			})
+		}
+	}
+
+	h := Chain(&resolver{}, middleware("first"), middleware("second"))
+	response := serve(h, query("example.com", querytype.A))
+
+	assert.Equal(t, []string{"first", "second"}, order)
+	assert.Equal(t, uint16(42), response.Header.ID)
+	assert.True(t, response.Header.Response)
+	assert.Len(t, response.Answers, 1)
+}
+
+func TestACL(t *testing.T) {
+	_, inside, _ := net.ParseCIDR("192.0.2.0/24")
+	_, outside, _ := net.ParseCIDR("10.0.0.0/8")
+
+	response := serve(Chain(&resolver{}, ACL([]*net.IPNet{inside})), query("example.com", querytype.A))
+	assert.Equal(t, resultcode.NOERROR, response.Header.Rescode)
+
+	response = serve(Chain(&resolver{}, ACL([]*net.IPNet{outside})), query("example.com", querytype.A))
+	assert.Equal(t, resultcode.REFUSED, response.Header.Rescode)
+	assert.Len(t, response.Question, 1)
+
+	response = serve(Chain(&resolver{}, AllowRecursion([]*net.IPNet{outside}), RefuseRecursion()), query("example.com", querytype.A))
+	assert.Equal(t, resultcode.REFUSED, response.Header.Rescode)
+
+	response = serve(Chain(&resolver{}, Refuse([]*net.IPNet{inside}), ACL([]*net.IPNet{inside})), query("example.com", querytype.A))
+	assert.Equal(t, resultcode.REFUSED, response.Header.Rescode, "refused clients are refused whatever the other lists say")
+
+	response = serve(Chain(&resolver{}, Refuse([]*net.IPNet{outside})), query("example.com", querytype.A))
+	assert.Equal(t, resultcode.NOERROR, response.Header.Rescode)
+}
+
+func TestBlock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "blocklist")
+	assert.NoError(t, os.WriteFile(path, []byte("ads.example.com\n"), 0o644))
+	group := filter.NewGroup("default")
+	group.Blocklists = filter.NewLists([]string{path})
+	assert.NoError(t, group.Blocklists.Load())
+
+	pause := &filter.Pause{}
+	r := &resolver{}
+	h := Chain(r, ClientGroup(&filter.Groups{Default: group}), Block(pause))
+
+	response := serve(h, query("tracker.ads.example.com", querytype.A))
+	assert.Equal(t, "tracker.ads.example.com. 60 IN A 0.0.0.0", response.Answers[0].String())
+	assert.Empty(t, r.asked)
+
+	pause.Set(time.Time{})
+	response = serve(h, query("tracker.ads.example.com", querytype.A))
+	assert.Equal(t, "tracker.ads.example.com. 300 IN A 198.51.100.1", response.Answers[0].String())
+}
+
+func TestStatic_ResolvesCNAMETarget(t *testing.T) {
+	records, err := static.New([]string{"web.staging 60 CNAME www.example.com", "db.staging 60 A 10.0.0.5"}, "", static.DefaultTTL)
+	assert.NoError(t, err)
+	r := &resolver{}
+	h := Chain(r, Static(records))
+
+	response := serve(h, query("db.staging", querytype.A))
+	assert.True(t, response.Header.AuthoritativeAnswer)
+	assert.Len(t, response.Answers, 1)
+
+	response = serve(h, query("web.staging", querytype.A))
+	assert.False(t, response.Header.AuthoritativeAnswer)
+	assert.Equal(t, []dns.Name{"www.example.com"}, r.asked)
+	assert.Len(t, response.Answers, 2)
+	assert.Equal(t, dns.Name("web.staging"), response.Question[0].Name)
+
+	// Clients that may not recurse only get the static records
+	_, other, _ := net.ParseCIDR("10.0.0.0/8")
+	response = serve(Chain(r, AllowRecursion([]*net.IPNet{other}), Static(records)), query("web.staging", querytype.A))
+	assert.Len(t, response.Answers, 1)
+}
+
+func TestCache(t *testing.T) {
+	logged := filepath.Join(t.TempDir(), "queries.log")
+	logger, err := querylog.Open(logged, false)
+	assert.NoError(t, err)
+	defer logger.Close()
+
+	r := &resolver{}
+	h := Chain(r, QueryLog(logger), Cache(cache.New(10, 0, 86400, 3600)))
+
+	first := serve(h, query("example.com", querytype.A))
+	request := query("EXAMPLE.com", querytype.A)
+	request.Header.ID = 7
+	second := serve(h, request)
+
+	assert.Equal(t, []dns.Name{"example.com"}, r.asked)
+	assert.Equal(t, first.Answers[0].String(), second.Answers[0].String())
+	assert.Equal(t, uint16(7), second.Header.ID)
+	assert.Equal(t, dns.Name("EXAMPLE.com"), second.Question[0].Name)
+
+	data, err := os.ReadFile(logged)
+	assert.NoError(t, err)
+	assert.Contains(t, string(data), `"cache":"miss","upstreams":["192.0.2.200:53"]`)
+	assert.Contains(t, string(data), `"cache":"hit"`)
+}
+
+func TestRateLimit(t *testing.T) {
+	limiter := ratelimit.New(ratelimit.Config{ResponsesPerSecond: 1, Window: time.Second, Slip: 2, IPv4PrefixLength: 24, IPv6PrefixLength: 56})
+	h := Chain(&resolver{}, RateLimit(limiter))
+
+	response := serve(h, query("example.com", querytype.A))
+	assert.Len(t, response.Answers, 1)
+	assert.Nil(t, serve(h, query("example.com", querytype.A)), "dropped")
+
+	response = serve(h, query("example.com", querytype.A))
+	assert.True(t, response.Header.TruncatedMessage, "slipped")
+	assert.Empty(t, response.Answers)
+}
@@ -0,0 +1,321 @@
+package handler
+
+import (
+	"context"
+	"dns-client-go/cache"
+	"dns-client-go/dns"
+	"dns-client-go/filter"
+	querylog "dns-client-go/query-log"
+	querytype "dns-client-go/query-type"
+	ratelimit "dns-client-go/rate-limit"
+	resultcode "dns-client-go/result-code"
+	"dns-client-go/static"
+	"dns-client-go/zone"
+	"log/slog"
+	"net"
+	"strings"
+	"time"
+)
+
+// safeSearchTTL is the TTL of the CNAME pointing a search engine to its safe search host
+const safeSearchTTL = 300
+
+// passThrough is the middleware of a part that is not configured
+func passThrough(next Handler) Handler {
+	return next
+}
+
+// contains reports whether ip is in one of the networks, an empty list contains every address
+func contains(networks []*net.IPNet, ip net.IP) bool {
+	if len(networks) == 0 {
+		return true
+	}
+
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// QueryLog writes an entry to logger for every response, nothing when logger is nil
+func QueryLog(logger *querylog.Logger) Middleware {
+	if logger == nil {
+		return passThrough
+	}
+
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, w ResponseWriter, request *dns.DnsPacket) {
+			start := time.Now()
+			trace := TraceFrom(ctx)
+			if trace == nil {
+				trace = &Trace{}
+				ctx = WithTrace(ctx, trace)
+			}
+
+			next.ServeDNS(ctx, Observe(w, func(response *dns.DnsPacket) {
+				entry := querylog.Entry{
+					Time:      start,
+					Client:    ClientIP(w),
+					Transport: w.RemoteAddr().Network(),
+					Rcode:     response.Header.Rescode.String(),
+					Answers:   len(response.Answers),
+					LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+					Cache:     trace.Cache(),
+					Upstreams: trace.Upstreams(),
+				}
+				if len(request.Question) > 0 {
+					entry.Name = request.Question[0].Name.String()
+					if display := request.Question[0].Name.Unicode(); display != entry.Name {
+						entry.UnicodeName = display
+					}
+					entry.Type = request.Question[0].Qtype.String()
+				}
+
+				if err := logger.Log(entry); err != nil {
+					slog.Error("failed to write query log", "error", err)
+				}
+			}), request)
+		})
+	}
+}
+
+// RateLimit drops the UDP responses to client networks over the rates of limiter, or sends them
+// truncated so legitimate clients retry over TCP. TCP is not limited, its sources cannot be spoofed.
+func RateLimit(limiter *ratelimit.Limiter) Middleware {
+	if limiter == nil {
+		return passThrough
+	}
+
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, w ResponseWriter, request *dns.DnsPacket) {
+			client, ok := w.RemoteAddr().(*net.UDPAddr)
+			if !ok {
+				next.ServeDNS(ctx, w, request)
+				return
+			}
+			next.ServeDNS(ctx, &limitedWriter{ResponseWriter: w, limiter: limiter, client: client.IP}, request)
+		})
+	}
+}
+
+type limitedWriter struct {
+	ResponseWriter
+	limiter *ratelimit.Limiter
+	client  net.IP
+}
+
+func (l *limitedWriter) Write(response *dns.DnsPacket) error {
+	switch l.limiter.Check(l.client, ratelimit.ClassOf(response.Header.Rescode)) {
+	case ratelimit.Drop:
+		return nil
+	case ratelimit.Slip:
+		truncated := *response
+		truncated.Header.TruncatedMessage = true
+		truncated.Answers = nil
+		truncated.Authorities = nil
+		truncated.Resources = nil
+		return l.ResponseWriter.Write(&truncated)
+	}
+
+	return l.ResponseWriter.Write(response)
+}
+
+// ACL refuses the requests of clients outside of networks, an empty list lets every client through
+func ACL(networks []*net.IPNet) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, w ResponseWriter, request *dns.DnsPacket) {
+			if !contains(networks, ClientIP(w)) {
+				Error(w, request, resultcode.REFUSED)
+				return
+			}
+			next.ServeDNS(ctx, w, request)
+		})
+	}
+}
+
+// Refuse refuses the requests of clients in networks, whatever they ask
+func Refuse(networks []*net.IPNet) Middleware {
+	if len(networks) == 0 {
+		return passThrough
+	}
+
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, w ResponseWriter, request *dns.DnsPacket) {
+			if contains(networks, ClientIP(w)) {
+				Error(w, request, resultcode.REFUSED)
+				return
+			}
+			next.ServeDNS(ctx, w, request)
+		})
+	}
+}
+
+// AllowRecursion records whether the client may ask for recursion, which clients in networks may.
+// An empty list allows every client. RefuseRecursion enforces it.
+func AllowRecursion(networks []*net.IPNet) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, w ResponseWriter, request *dns.DnsPacket) {
+			next.ServeDNS(WithRecursion(ctx, contains(networks, ClientIP(w))), w, request)
+		})
+	}
+}
+
+// RefuseRecursion refuses the requests of clients that may not ask for recursion, it goes in front of
+// the parts that resolve
+func RefuseRecursion() Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, w ResponseWriter, request *dns.DnsPacket) {
+			if !RecursionAllowed(ctx) {
+				Error(w, request, resultcode.REFUSED)
+				return
+			}
+			next.ServeDNS(ctx, w, request)
+		})
+	}
+}
+
+// ClientGroup selects the filtering group of the client
+func ClientGroup(groups *filter.Groups) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, w ResponseWriter, request *dns.DnsPacket) {
+			next.ServeDNS(WithGroup(ctx, groups.ForClient(ClientIP(w))), w, request)
+		})
+	}
+}
+
+// filtering reports whether the blocklists and safe search apply to a request
+func filtering(ctx context.Context, pause *filter.Pause) (*filter.Group, bool) {
+	group := GroupFrom(ctx)
+	return group, group != nil && (pause == nil || !pause.Paused())
+}
+
+// Block answers the questions for names blocked in the client's group with the group's block response.
+// Nothing is blocked while pause is in effect.
+func Block(pause *filter.Pause) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, w ResponseWriter, request *dns.DnsPacket) {
+			question := request.Question[0]
+			if group, ok := filtering(ctx, pause); ok && group.Blocked(question.Name) {
+				response := Reply(request)
+				group.Action.Respond(question, response)
+				w.Write(response)
+				return
+			}
+			next.ServeDNS(ctx, w, request)
+		})
+	}
+}
+
+// SafeSearch points search engines to the hosts enforcing safe search with a CNAME, for the groups that
+// ask for it. The addresses of the host are resolved by next for clients that may recurse.
+func SafeSearch(pause *filter.Pause) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, w ResponseWriter, request *dns.DnsPacket) {
+			question := request.Question[0]
+			target, isSearchEngine := filter.SafeSearchTarget(question.Name)
+			group, ok := filtering(ctx, pause)
+			if !isSearchEngine || !ok || !group.SafeSearch {
+				next.ServeDNS(ctx, w, request)
+				return
+			}
+
+			response := Reply(request)
+			response.Question = append(response.Question, question)
+			response.Answers = append(response.Answers, dns.NewCNAMERecord(question.Name, target.String(), safeSearchTTL))
+			if question.Qtype != querytype.CNAME && RecursionAllowed(ctx) {
+				result := Resolve(ctx, next, w, request, target)
+				response.Header.Rescode = result.Header.Rescode
+				response.Answers = append(response.Answers, result.Answers...)
+			}
+			w.Write(response)
+		})
+	}
+}
+
+// Static answers from the static records. A CNAME pointing outside of them is resolved by next for
+// clients that may recurse.
+func Static(records *static.Records) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, w ResponseWriter, request *dns.DnsPacket) {
+			question := request.Question[0]
+			answers, ok := records.Lookup(question.Name, question.Qtype)
+			if !ok {
+				next.ServeDNS(ctx, w, request)
+				return
+			}
+
+			response := Reply(request)
+			response.Header.AuthoritativeAnswer = true
+			response.Question = append(response.Question, question)
+			response.Answers = answers
+
+			last := len(answers) - 1
+			if last >= 0 && answers[last].CNAME != nil && question.Qtype != querytype.CNAME && RecursionAllowed(ctx) {
+				result := Resolve(ctx, next, w, request, dns.Name(answers[last].CNAME.Host()))
+				response.Header.AuthoritativeAnswer = false
+				response.Header.Rescode = result.Header.Rescode
+				response.Answers = append(append([]dns.DnsRecord(nil), answers...), result.Answers...)
+			}
+			w.Write(response)
+		})
+	}
+}
+
+// Zones answers from the zones the server is authoritative for. Referrals to delegated children are
+// only returned to clients that did not ask for recursion, the others are passed on to next.
+func Zones(store *zone.Store) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, w ResponseWriter, request *dns.DnsPacket) {
+			question := request.Question[0]
+			authority := store.Find(question.Name)
+			if authority == nil {
+				next.ServeDNS(ctx, w, request)
+				return
+			}
+
+			answer := authority.Lookup(question.Name, question.Qtype)
+			if !answer.Authoritative && request.Header.RecursionDesired {
+				next.ServeDNS(ctx, w, request)
+				return
+			}
+
+			response := Reply(request)
+			response.Header.AuthoritativeAnswer = answer.Authoritative
+			response.Header.Rescode = answer.Rescode
+			response.Question = append(response.Question, question)
+			response.Answers = answer.Answers
+			response.Authorities = answer.Authorities
+			response.Resources = answer.Resources
+			w.Write(response)
+		})
+	}
+}
+
+// Cache answers from c and stores the responses of next in it. Responses are cached per upstream
+// resolvers of the client's group, as groups may resolve through different ones.
+func Cache(c *cache.Cache) Middleware {
+	return func(next Handler) Handler {
+		return HandlerFunc(func(ctx context.Context, w ResponseWriter, request *dns.DnsPacket) {
+			question := request.Question[0]
+			view := ""
+			if group := GroupFrom(ctx); group != nil {
+				view = strings.Join(group.Upstreams, ",")
+			}
+			key := cache.NewKey(question.Name, question.Qtype, view)
+
+			cached, ok := c.Get(key)
+			TraceFrom(ctx).Cached(ok)
+			if ok {
+				Answer(w, request, cached)
+				return
+			}
+
+			next.ServeDNS(ctx, Observe(w, func(response *dns.DnsPacket) {
+				c.Put(key, response)
+			}), request)
+		})
+	}
+}
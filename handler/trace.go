@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	querylog "dns-client-go/query-log"
+	"sync"
+)
+
+// Trace collects what answering a request involved, for the query log. It is safe for concurrent use.
+type Trace struct {
+	mu        sync.Mutex
+	cache     string   // querylog.CacheHit or querylog.CacheMiss, empty when the cache was not asked
+	upstreams []string // the authoritative servers and upstream resolvers contacted
+}
+
+// WithTrace returns a context collecting into trace
+func WithTrace(ctx context.Context, trace *Trace) context.Context {
+	return context.WithValue(ctx, traceKey, trace)
+}
+
+// TraceFrom returns the trace of a request, nil when it is not traced
+func TraceFrom(ctx context.Context) *Trace {
+	trace, _ := ctx.Value(traceKey).(*Trace)
+	return trace
+}
+
+// Cached records a cache lookup. A request that needed several lookups, such as a CNAME chase, is a
+// hit only if all of them were.
+func (t *Trace) Cached(hit bool) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !hit {
+		t.cache = querylog.CacheMiss
+	} else if t.cache == "" {
+		t.cache = querylog.CacheHit
+	}
+}
+
+// Contacted records a query sent to an authoritative server or upstream resolver
+func (t *Trace) Contacted(server string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.upstreams = append(t.upstreams, server)
+}
+
+// Merge records the servers contacted for other, such as a lookup shared with concurrent requests that
+// each log the servers it contacted
+func (t *Trace) Merge(other *Trace) {
+	if t == nil || other == nil || t == other {
+		return
+	}
+
+	upstreams := other.Upstreams()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.upstreams = append(t.upstreams, upstreams...)
+}
+
+// Cache returns querylog.CacheHit or querylog.CacheMiss, or nothing when the cache was not asked
+func (t *Trace) Cache() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.cache
+}
+
+// Upstreams returns the authoritative servers and upstream resolvers contacted
+func (t *Trace) Upstreams() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return append([]string(nil), t.upstreams...)
+}
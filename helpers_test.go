@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"dns-client-go/dns"
+	bytepacketbuffer "dns-client-go/packetbuffer"
+	"dns-client-go/transport"
+)
+
+// recordStrings returns records in presentation format
+func recordStrings(records []dns.DnsRecord) []string {
+	var lines []string
+	for _, record := range records {
+		lines = append(lines, record.String())
+	}
+
+	return lines
+}
+
+// fakeNameServer answers the queries sent over UDP and TCP to a loopback port with what respond returns,
+// and points nameServerPort at it for the test. UDP queries are answered concurrently. It counts the TCP
+// queries.
+func fakeNameServer(t *testing.T, respond func(request *dns.DnsPacket) *dns.DnsPacket) *atomic.Int32 {
+	var (
+		listener net.Listener
+		conn     net.PacketConn
+		err      error
+	)
+	for attempt := 0; conn == nil && attempt < 10; attempt++ {
+		if listener, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+			t.Fatalf("failed to listen: %v", err)
+		}
+		if conn, err = net.ListenPacket("udp", listener.Addr().String()); err != nil {
+			listener.Close()
+		}
+	}
+	if conn == nil {
+		t.Fatalf("failed to listen on UDP: %v", err)
+	}
+
+	_, port, _ := net.SplitHostPort(listener.Addr().String())
+	defaultPort := nameServerPort
+	nameServerPort = port
+	t.Cleanup(func() {
+		nameServerPort = defaultPort
+		listener.Close()
+		conn.Close()
+	})
+
+	answer := func(request *dns.DnsPacket) []byte {
+		response := respond(request)
+		response.Header.ID = request.Header.ID
+		response.Header.Response = true
+		data, _ := transport.Serialize(response)
+		return data
+	}
+
+	go func() {
+		received := make([]byte, 512)
+		for {
+			n, address, err := conn.ReadFrom(received)
+			if err != nil {
+				return
+			}
+			buffer := bytepacketbuffer.NewPacketBufferFrom(received[:n])
+			request := dns.NewPacket().FromBuffer(&buffer)
+			go func() {
+				conn.WriteTo(answer(request), address)
+			}()
+		}
+	}()
+
+	tcpQueries := &atomic.Int32{}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			if buffer, err := transport.ReadMessage(conn); err == nil {
+				tcpQueries.Add(1)
+				transport.WriteMessage(conn, answer(dns.NewPacket().FromBuffer(buffer)))
+			}
+			conn.Close()
+		}
+	}()
+
+	return tcpQueries
+}
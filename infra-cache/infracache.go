@@ -0,0 +1,217 @@
+package infracache
+
+import (
+	"dns-client-go/dns"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	// unknownRTT is the smoothed RTT assumed for a server never asked, as in Unbound. Servers known to be
+	// faster are preferred, slower ones are given a chance.
+	unknownRTT = 376 * time.Millisecond
+	// maxRTT caps the smoothed RTT that timeouts push up
+	maxRTT = 2 * time.Minute
+	// exploreRatio is the share of selections that go to a random server, to keep the RTT of the others
+	// up to date
+	exploreRatio = 0.05
+	// maxFailures is the number of failed exchanges in a row, timeouts or errors, after which a server is
+	// held down
+	maxFailures = 3
+	// holdDown is how long a failing server is avoided, lameHoldDown a server lame for a zone
+	holdDown     = time.Minute
+	lameHoldDown = 10 * time.Minute
+	// caseHoldDown is how long names are sent as they are to a server that does not echo their case
+	caseHoldDown = time.Hour
+	// ttl is how long the cache remembers a server after it was last used
+	ttl = 15 * time.Minute
+)
+
+var (
+	now    = time.Now
+	random = rand.Float64
+)
+
+type entry struct {
+	srtt      time.Duration
+	failures  int // failed exchanges in a row
+	heldUntil time.Time
+	lame      map[dns.Name]time.Time // zones the server is lame for, until when
+	caseUntil time.Time              // the server does not echo the case of names until then
+	used      time.Time
+}
+
+// Cache is the infrastructure cache of a recursive resolver: the smoothed round trip time of the name
+// servers it asks and their failures, to select the fastest server of a zone and to avoid the ones that do
+// not answer. It is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	servers map[string]*entry
+	swept   time.Time
+}
+
+func New() *Cache {
+	return &Cache{servers: map[string]*entry{}, swept: now()}
+}
+
+// Select picks the server of zone to ask among servers: the one with the lowest smoothed RTT, or once in
+// a while another one. Servers held down or lame for zone are only picked when all of them are.
+func (c *Cache) Select(zone dns.Name, servers []net.IP) net.IP {
+	if len(servers) == 0 {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	current := now()
+	c.sweep(current)
+
+	candidates := make([]net.IP, 0, len(servers))
+	for _, server := range servers {
+		if !c.avoided(server.String(), zone, current) {
+			candidates = append(candidates, server)
+		}
+	}
+	if len(candidates) == 0 {
+		candidates = servers
+	}
+
+	if len(candidates) > 1 && random() < exploreRatio {
+		return candidates[int(random()*float64(len(candidates)))%len(candidates)]
+	}
+
+	best := candidates[0]
+	for _, server := range candidates[1:] {
+		if c.srtt(server.String()) < c.srtt(best.String()) {
+			best = server
+		}
+	}
+
+	return best
+}
+
+// Success records that server answered after rtt
+func (c *Cache) Success(server net.IP, rtt time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entry(server.String())
+	if ok {
+		e.srtt = (7*e.srtt + 3*rtt) / 10
+	} else {
+		e.srtt = rtt
+	}
+	e.failures = 0
+	e.heldUntil = time.Time{}
+}
+
+// Failure records that server did not answer in time. Its smoothed RTT doubles, and it is held down after
+// too many failed exchanges in a row.
+func (c *Cache) Failure(server net.IP) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, _ := c.entry(server.String())
+	e.srtt *= 2
+	if e.srtt > maxRTT {
+		e.srtt = maxRTT
+	}
+	e.failed()
+}
+
+// Error records that the exchange with server failed other than by a timeout, such as a refused
+// connection or a malformed response. It says nothing of the RTT of the server, but counts towards holding
+// it down.
+func (c *Cache) Error(server net.IP) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, _ := c.entry(server.String())
+	e.failed()
+}
+
+// Lame records that server is not authoritative for zone although it was delegated to, or refuses or
+// fails to answer for it
+func (c *Cache) Lame(server net.IP, zone dns.Name) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, _ := c.entry(server.String())
+	if e.lame == nil {
+		e.lame = map[dns.Name]time.Time{}
+	}
+	e.lame[zone.Canonical()] = now().Add(lameHoldDown)
+}
+
+// IgnoresCase records that server does not echo the case of the names it is asked about
+func (c *Cache) IgnoresCase(server net.IP) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, _ := c.entry(server.String())
+	e.caseUntil = now().Add(caseHoldDown)
+}
+
+// PreservesCase reports whether server can be sent names in random case, which it is until it was seen
+// not to echo it
+func (c *Cache) PreservesCase(server net.IP) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.servers[server.String()]
+	return !ok || !now().Before(e.caseUntil)
+}
+
+// entry returns the entry of a server, creating it when it is not known yet
+func (c *Cache) entry(address string) (*entry, bool) {
+	e, ok := c.servers[address]
+	if !ok {
+		e = &entry{srtt: unknownRTT}
+		c.servers[address] = e
+	}
+	e.used = now()
+
+	return e, ok
+}
+
+// failed counts a failed exchange, holding the server down after too many in a row
+func (e *entry) failed() {
+	e.failures++
+	if e.failures >= maxFailures {
+		e.heldUntil = now().Add(holdDown)
+	}
+}
+
+func (c *Cache) srtt(address string) time.Duration {
+	if e, ok := c.servers[address]; ok {
+		return e.srtt
+	}
+
+	return unknownRTT
+}
+
+func (c *Cache) avoided(address string, zone dns.Name, current time.Time) bool {
+	e, ok := c.servers[address]
+	if !ok {
+		return false
+	}
+
+	return current.Before(e.heldUntil) || current.Before(e.lame[zone.Canonical()])
+}
+
+// sweep forgets the servers not used for the ttl, at most once per ttl
+func (c *Cache) sweep(current time.Time) {
+	if current.Sub(c.swept) < ttl {
+		return
+	}
+
+	for address, e := range c.servers {
+		if current.Sub(e.used) >= ttl {
+			delete(c.servers, address)
+		}
+	}
+	c.swept = current
+}
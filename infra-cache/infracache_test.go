@@ -0,0 +1,119 @@
+package infracache
+
+import (
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var (
+	fast    = net.ParseIP("192.0.2.1")
+	slow    = net.ParseIP("192.0.2.2")
+	unknown = net.ParseIP("192.0.2.3")
+)
+
+func setClock(t *testing.T, current *time.Time) {
+	now = func() time.Time { return *current }
+	random = func() float64 { return 0.5 }
+	t.Cleanup(func() { now, random = time.Now, rand.Float64 })
+}
+
+func TestCache_Select(t *testing.T) {
+	current := time.Unix(1700000000, 0)
+	setClock(t, &current)
+	c := New()
+
+	c.Success(fast, 20*time.Millisecond)
+	c.Success(slow, 500*time.Millisecond)
+	assert.Equal(t, fast, c.Select("example.com", []net.IP{slow, unknown, fast}))
+	assert.Equal(t, unknown, c.Select("example.com", []net.IP{slow, unknown}), "unknown servers are tried before slow ones")
+
+	random = func() float64 { return 0.01 }
+	assert.Equal(t, slow, c.Select("example.com", []net.IP{slow, unknown, fast}), "other servers are explored once in a while")
+}
+
+func TestCache_Success(t *testing.T) {
+	c := New()
+
+	c.Success(fast, 100*time.Millisecond)
+	assert.Equal(t, 100*time.Millisecond, c.servers[fast.String()].srtt)
+	c.Success(fast, 200*time.Millisecond)
+	assert.Equal(t, 130*time.Millisecond, c.servers[fast.String()].srtt)
+}
+
+func TestCache_Failure(t *testing.T) {
+	current := time.Unix(1700000000, 0)
+	setClock(t, &current)
+	c := New()
+
+	c.Success(fast, 20*time.Millisecond)
+	c.Failure(fast)
+	c.Failure(fast)
+	assert.Equal(t, 80*time.Millisecond, c.servers[fast.String()].srtt)
+	assert.Equal(t, fast, c.Select("example.com", []net.IP{fast, slow}))
+
+	c.Failure(fast)
+	assert.Equal(t, slow, c.Select("example.com", []net.IP{fast, slow}), "servers failing in a row are held down")
+	assert.Equal(t, fast, c.Select("example.com", []net.IP{fast}), "held down servers are asked when there is no other")
+
+	current = current.Add(holdDown)
+	assert.Equal(t, fast, c.Select("example.com", []net.IP{fast, slow}))
+}
+
+func TestCache_Error(t *testing.T) {
+	current := time.Unix(1700000000, 0)
+	setClock(t, &current)
+	c := New()
+
+	c.Success(fast, 20*time.Millisecond)
+	c.Error(fast)
+	c.Failure(fast)
+	assert.Equal(t, 40*time.Millisecond, c.servers[fast.String()].srtt, "only timeouts raise the RTT")
+	assert.Equal(t, fast, c.Select("example.com", []net.IP{fast, slow}))
+
+	c.Error(fast)
+	assert.Equal(t, slow, c.Select("example.com", []net.IP{fast, slow}), "errors count towards holding a server down")
+}
+
+func TestCache_Lame(t *testing.T) {
+	current := time.Unix(1700000000, 0)
+	setClock(t, &current)
+	c := New()
+
+	c.Success(fast, 20*time.Millisecond)
+	c.Lame(fast, "example.com")
+	assert.Equal(t, slow, c.Select("example.com", []net.IP{fast, slow}))
+	assert.Equal(t, slow, c.Select("Example.COM.", []net.IP{fast, slow}), "zones are compared as names")
+	assert.Equal(t, fast, c.Select("example.org", []net.IP{fast, slow}), "servers are only lame for a zone")
+
+	current = current.Add(lameHoldDown)
+	assert.Equal(t, fast, c.Select("example.com", []net.IP{fast, slow}))
+}
+
+func TestCache_Sweep(t *testing.T) {
+	current := time.Unix(1700000000, 0)
+	setClock(t, &current)
+	c := New()
+
+	c.Success(fast, 20*time.Millisecond)
+	current = current.Add(ttl)
+	c.Select("example.com", []net.IP{slow})
+	assert.NotContains(t, c.servers, fast.String())
+}
+
+func TestCache_IgnoresCase(t *testing.T) {
+	current := time.Unix(1700000000, 0)
+	setClock(t, &current)
+	c := New()
+
+	assert.True(t, c.PreservesCase(fast))
+	c.IgnoresCase(fast)
+	assert.False(t, c.PreservesCase(fast))
+	assert.True(t, c.PreservesCase(slow))
+
+	current = current.Add(caseHoldDown)
+	assert.True(t, c.PreservesCase(fast))
+}
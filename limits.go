@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"dns-client-go/config"
+	"dns-client-go/dns"
+	"dns-client-go/handler"
+	resultcode "dns-client-go/result-code"
+	"net"
+)
+
+// Reasons for shedding queries, a label of dns_queries_shed_total
+const (
+	shedClientRate = "client_rate"
+	shedOverload   = "overload"
+)
+
+// shed turns away a query the server will not answer, with REFUSED or by dropping it when the shed
+// action is drop. TCP queries are always refused, a dropped one would only hold the connection open.
+func (s *server) shed(w handler.ResponseWriter, request *dns.DnsPacket, reason string) {
+	if _, udp := w.RemoteAddr().(*net.UDPAddr); udp && s.config.Limits.ShedAction == config.ShedDrop {
+		queriesShed.Inc(reason, "dropped")
+		return
+	}
+
+	queriesShed.Inc(reason, "refused")
+	handler.Error(w, request, resultcode.REFUSED)
+}
+
+// limitClients sheds the queries of clients above their query rate
+func (s *server) limitClients(next handler.Handler) handler.Handler {
+	if s.clientLimiter == nil {
+		return next
+	}
+
+	return handler.HandlerFunc(func(ctx context.Context, w handler.ResponseWriter, request *dns.DnsPacket) {
+		if !s.clientLimiter.Allow(handler.ClientIP(w)) {
+			s.shed(w, request, shedClientRate)
+			return
+		}
+		next.ServeDNS(ctx, w, request)
+	})
+}
+
+// shedOverload sheds the queries that need resolution while more than max_inflight queries are being
+// answered. It goes behind the cache and the local data, so what the server can answer at once still is
+// when resolution falls behind.
+func (s *server) shedOverload(next handler.Handler) handler.Handler {
+	limit := int64(s.config.Limits.MaxInflight)
+	if limit == 0 {
+		return next
+	}
+
+	return handler.HandlerFunc(func(ctx context.Context, w handler.ResponseWriter, request *dns.DnsPacket) {
+		if s.inflight.Load() > limit {
+			s.shed(w, request, shedOverload)
+			return
+		}
+		next.ServeDNS(ctx, w, request)
+	})
+}
+
+// trackQuery counts a client query as being answered until the returned function is called
+func (s *server) trackQuery() (done func()) {
+	s.inflight.Add(1)
+	inflightQueries.Inc()
+
+	return func() {
+		s.inflight.Add(-1)
+		inflightQueries.Dec()
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"dns-client-go/config"
+	"dns-client-go/dns"
+	"dns-client-go/handler"
+	querytype "dns-client-go/query-type"
+	resultcode "dns-client-go/result-code"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testWriter keeps the responses written for a client at remote
+type testWriter struct {
+	remote    net.Addr
+	responses []*dns.DnsPacket
+}
+
+func (w *testWriter) LocalAddr() net.Addr {
+	return &net.UDPAddr{IP: net.ParseIP("192.0.2.53"), Port: 53}
+}
+
+func (w *testWriter) RemoteAddr() net.Addr {
+	return w.remote
+}
+
+func (w *testWriter) Write(response *dns.DnsPacket) error {
+	w.responses = append(w.responses, response)
+	return nil
+}
+
+var (
+	udpClient = &net.UDPAddr{IP: net.ParseIP("192.0.2.1"), Port: 5353}
+	tcpClient = &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 5353}
+)
+
+func testQuery() *dns.DnsPacket {
+	request := dns.NewPacket()
+	request.Header.ID = 42
+	request.Question = []dns.DnsQuestion{{Name: "www.example.com", Qtype: querytype.A}}
+	return request
+}
+
+func TestServer_Shed(t *testing.T) {
+	testCases := []struct {
+		name    string
+		action  string
+		client  net.Addr
+		outcome string // the label of dns_queries_shed_total, refused queries are answered REFUSED
+	}{
+		{name: "refused over UDP", action: config.ShedRefuse, client: udpClient, outcome: "refused"},
+		{name: "dropped over UDP", action: config.ShedDrop, client: udpClient, outcome: "dropped"},
+		{name: "refused over TCP", action: config.ShedRefuse, client: tcpClient, outcome: "refused"},
+		{name: "always refused over TCP", action: config.ShedDrop, client: tcpClient, outcome: "refused"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Limits.ShedAction = tc.action
+			s := &server{config: cfg}
+			w := &testWriter{remote: tc.client}
+			before := queriesShed.Value(shedClientRate, tc.outcome)
+
+			s.shed(w, testQuery(), shedClientRate)
+			assert.Equal(t, before+1, queriesShed.Value(shedClientRate, tc.outcome))
+			if tc.outcome == "dropped" {
+				assert.Empty(t, w.responses)
+				return
+			}
+			assert.Len(t, w.responses, 1)
+			assert.Equal(t, resultcode.REFUSED, w.responses[0].Header.Rescode)
+			assert.Equal(t, uint16(42), w.responses[0].Header.ID)
+		})
+	}
+}
+
+func TestServer_ShedOverload(t *testing.T) {
+	testCases := []struct {
+		name        string
+		maxInflight int
+		inflight    int64 // including the query itself
+		action      string
+		answered    bool
+		responses   int
+	}{
+		{name: "under the limit", maxInflight: 2, inflight: 1, action: config.ShedRefuse, answered: true, responses: 1},
+		{name: "at the limit", maxInflight: 2, inflight: 2, action: config.ShedDrop, answered: true, responses: 1},
+		{name: "over the limit refused", maxInflight: 2, inflight: 3, action: config.ShedRefuse, responses: 1},
+		{name: "over the limit dropped", maxInflight: 2, inflight: 3, action: config.ShedDrop},
+		{name: "no limit", inflight: 1000, action: config.ShedDrop, answered: true, responses: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := config.Default()
+			cfg.Limits.MaxInflight = tc.maxInflight
+			cfg.Limits.ShedAction = tc.action
+			s := &server{config: cfg, inflight: &atomic.Int64{}}
+			s.inflight.Store(tc.inflight)
+
+			answered := false
+			next := handler.HandlerFunc(func(ctx context.Context, w handler.ResponseWriter, request *dns.DnsPacket) {
+				answered = true
+				handler.Answer(w, request, handler.Reply(request))
+			})
+			w := &testWriter{remote: udpClient}
+			s.shedOverload(next).ServeDNS(context.Background(), w, testQuery())
+
+			assert.Equal(t, tc.answered, answered)
+			assert.Len(t, w.responses, tc.responses)
+			if tc.responses > 0 && !tc.answered {
+				assert.Equal(t, resultcode.REFUSED, w.responses[0].Header.Rescode)
+			}
+		})
+	}
+}
+
+func TestServer_TrackQuery(t *testing.T) {
+	s := &server{inflight: &atomic.Int64{}}
+	first, second := s.trackQuery(), s.trackQuery()
+	assert.Equal(t, int64(2), s.inflight.Load())
+
+	first()
+	second()
+	assert.Equal(t, int64(0), s.inflight.Load())
+}
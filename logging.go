@@ -0,0 +1,31 @@
+package main
+
+import (
+	"dns-client-go/config"
+	"log/slog"
+	"os"
+)
+
+// logLevels maps the levels of the logging configuration to slog levels
+var logLevels = map[string]slog.Level{
+	config.LevelDebug: slog.LevelDebug,
+	config.LevelInfo:  slog.LevelInfo,
+	config.LevelWarn:  slog.LevelWarn,
+	config.LevelError: slog.LevelError,
+}
+
+// logLevel is the severity of the least severe messages that are logged, it changes on reload
+var logLevel slog.LevelVar
+
+// setupLogging makes the default slog logger, which the packages of the server log to, write messages
+// of the configured level and format to standard output
+func setupLogging(settings config.Logging) {
+	logLevel.Set(logLevels[settings.Level])
+
+	options := &slog.HandlerOptions{Level: &logLevel}
+	var handler slog.Handler = slog.NewTextHandler(os.Stdout, options)
+	if settings.Format == config.FormatJSON {
+		handler = slog.NewJSONHandler(os.Stdout, options)
+	}
+	slog.SetDefault(slog.New(handler))
+}
@@ -1,23 +1,162 @@
 package main
 
 import (
+	"context"
+	"dns-client-go/cache"
+	"dns-client-go/config"
 	"dns-client-go/dns"
+	"dns-client-go/dnstap"
+	"dns-client-go/filter"
+	"dns-client-go/handler"
+	infracache "dns-client-go/infra-cache"
 	packetbuffer "dns-client-go/packetbuffer"
+	"dns-client-go/primary"
+	querylog "dns-client-go/query-log"
 	querytype "dns-client-go/query-type"
+	ratelimit "dns-client-go/rate-limit"
 	resultcode "dns-client-go/result-code"
+	"dns-client-go/secondary"
+	singleflight "dns-client-go/single-flight"
+	"dns-client-go/static"
+	"dns-client-go/transport"
+	"dns-client-go/tsig"
+	"dns-client-go/zone"
+	"errors"
+	"flag"
 	"fmt"
+	"log/slog"
+	"math/rand"
 	"net"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
 )
 
-func recursiveLookup(qname string, qtype querytype.QueryType) (*dns.DnsPacket, error) {
-	ns := net.ParseIP("198.41.0.4") // a.root-servers.net
+type server struct {
+	config         *config.Config
+	zones          *zone.Store
+	primaries      map[dns.Name]*primary.Primary
+	secondaries    map[dns.Name]*secondary.Secondary
+	keys           tsig.KeyStore
+	groups         *filter.Groups
+	pause          *filter.Pause // set through the admin API
+	static         *static.Records
+	cache          *cache.Cache
+	infra          *infracache.Cache // RTT and failures of the name servers, kept across reloads
+	inflight       *atomic.Int64     // client queries being answered, kept across reloads
+	rootHints      []net.IP
+	queryLog       *querylog.Logger   // nil when queries are not logged
+	dnstap         *dnstap.Writer     // nil when messages are not captured
+	rateLimiter    *ratelimit.Limiter // nil when responses are not rate limited
+	clientLimiter  *ratelimit.Clients // nil when client queries are not limited
+	refuse         []*net.IPNet
+	allowQuery     []*net.IPNet
+	allowRecursion []*net.IPNet
+	flights        *singleflight.Group[flightKey, flight]
+	handler        handler.Handler                 // answers the requests, see chain
+	cancel         context.CancelFunc              // stops the reloading of lists and static records
+	zoneRuns       map[dns.Name]context.CancelFunc // stop the maintenance of each zone
+}
+
+// flightKey identifies the resolutions that share one walk from the root servers, or one exchange with
+// the upstream resolvers of a group. Questions are all of class IN.
+type flightKey struct {
+	name      dns.Name
+	qtype     querytype.QueryType
+	upstreams string // empty for recursive resolution
+}
+
+// flight is the result of a shared walk or exchange, with the servers it contacted for the trace of every
+// request that shared it
+type flight struct {
+	response *dns.DnsPacket
+	trace    *handler.Trace
+}
+
+// resolutionQueries is about the most queries one resolution sends, counting the referrals it follows,
+// the servers it retries and the name servers it looks up. Times the timeout of a query, it is how long a
+// resolution may take.
+const resolutionQueries = 30
+
+// recursiveLookup resolves a name from the root servers. Concurrent resolutions of the same question
+// share one walk.
+func (s *server) recursiveLookup(qname dns.Name, qtype querytype.QueryType, trace *handler.Trace) (*dns.DnsPacket, error) {
+	return s.sharedLookup(qname, qtype, trace, nil)
+}
+
+// sharedLookup joins or starts the walk for a question. parents are the questions whose walk needs this
+// one, a name server that can only be found through itself is an error rather than a wait forever. So is
+// one found through a concurrent walk that needs the parent's.
+func (s *server) sharedLookup(qname dns.Name, qtype querytype.QueryType, trace *handler.Trace, parents []flightKey) (*dns.DnsPacket, error) {
+	key := flightKey{name: qname.Canonical(), qtype: qtype}
+	for _, parent := range parents {
+		if parent == key {
+			return nil, fmt.Errorf("resolving %v %v depends on itself", qname, qtype.String())
+		}
+	}
+
+	walk := func() (flight, error) {
+		f := flight{trace: &handler.Trace{}}
+		var err error
+		f.response, err = s.walk(qname, qtype, f.trace, append(parents[:len(parents):len(parents)], key))
+		return f, err
+	}
+
+	var f flight
+	var err error
+	if len(parents) == 0 {
+		f, err, _ = s.flights.Do(key, walk)
+	} else {
+		f, err, _ = s.flights.DoWithin(parents[len(parents)-1], key, walk)
+	}
+	trace.Merge(f.trace)
+
+	return f.response, err
+}
+
+// walk follows the referrals from the root servers to the servers answering for qname. The server of a
+// zone is selected by the infrastructure cache, the others are tried when it fails to answer or refers
+// elsewhere than closer to qname. Responses are stripped of the records outside of the zone of the server,
+// see sanitize. With QNAME minimisation every zone is only asked for the next label of qname, see minimise.
+func (s *server) walk(qname dns.Name, qtype querytype.QueryType, trace *handler.Trace, parents []flightKey) (*dns.DnsPacket, error) {
+	zone, servers := dns.Name("."), s.rootHints
+	m := newMinimiser(s.config.Resolver.QnameMinimisation, qname)
 
 	for {
-		fmt.Printf("attempting lookup of %v %v with ns %v\n", qtype, qname, ns)
+		ns := s.infra.Select(zone, servers)
+		name, nameType := m.next(zone, qtype)
+		slog.Debug("attempting lookup", "name", name, "type", nameType.String(), "zone", zone, "ns", ns)
 
-		response, err := lookup(qname, qtype, ns)
-		if err != nil {
-			return nil, err
+		response, err := s.lookup(name, nameType, ns, trace)
+		referral, bogus := dns.Name(""), false
+		if err == nil {
+			var ok bool
+			referral, ok = sanitize(response, name, nameType, zone)
+			bogus = !ok
+		}
+		if err == nil && !bogus && name != qname {
+			switch m.handle(name, referral, response) {
+			case stepNext:
+				continue
+			case stepDone:
+				return response, nil
+			}
+		}
+
+		failed := err == nil && (bogus || lame(response))
+		if failed {
+			s.infra.Lame(ns, zone)
+		}
+		if err != nil || failed {
+			if servers = without(servers, ns); len(servers) > 0 {
+				continue
+			}
+			if bogus {
+				return nil, fmt.Errorf("no server of %v refers closer to %v", zone, qname)
+			}
+			return response, err
 		}
 
 		if len(response.Answers) > 0 && response.Header.Rescode == resultcode.NOERROR {
@@ -28,128 +167,348 @@ func recursiveLookup(qname string, qtype querytype.QueryType) (*dns.DnsPacket, e
 			return response, nil
 		}
 
-		newNs := response.GetResolvedNS(qname)
-		if newNs != nil {
-			ns = newNs
-			continue
+		if referral == "" {
+			return response, nil
 		}
+		zone = referral
 
-		newNsName := response.GetUnresolvedNS(qname)
-		if newNsName == "" {
+		if servers = response.GetResolvedNS(qname); len(servers) > 0 {
+			continue
+		}
+		if servers = s.resolveNS(response.GetUnresolvedNS(qname), trace, parents); len(servers) == 0 {
 			return response, nil
 		}
+	}
+}
 
-		recursiveResponse, err := recursiveLookup(newNsName, querytype.A)
+// resolveNS returns the addresses of the first of the name server hosts, in random order, that resolves
+func (s *server) resolveNS(hosts []string, trace *handler.Trace, parents []flightKey) []net.IP {
+	for _, i := range rand.Perm(len(hosts)) {
+		response, err := s.sharedLookup(dns.Name(hosts[i]), querytype.A, trace, parents)
 		if err != nil {
-			return nil, err
+			slog.Debug("failed to resolve name server", "ns", hosts[i], "error", err)
+			continue
+		}
+		if addresses := response.GetAddresses(); len(addresses) > 0 {
+			return addresses
 		}
+	}
 
-		newIp := recursiveResponse.GetRandomA()
-		if newIp != nil {
-			ns = newIp
-		} else {
-			return response, nil
+	return nil
+}
+
+// lame reports whether a name server answered that it cannot answer for the zone it was asked about
+func lame(response *dns.DnsPacket) bool {
+	switch response.Header.Rescode {
+	case resultcode.SERVFAIL, resultcode.REFUSED, resultcode.NOTIMP:
+		return true
+	}
+
+	return false
+}
+
+// without returns servers without ns
+func without(servers []net.IP, ns net.IP) []net.IP {
+	var rest []net.IP
+	for _, server := range servers {
+		if !server.Equal(ns) {
+			rest = append(rest, server)
 		}
 	}
+
+	return rest
 }
 
-func lookup(qname string, qtype querytype.QueryType, ns net.IP) (*dns.DnsPacket, error) {
+// nameServerPort is the port name servers are asked on, tests point it at fake servers
+var nameServerPort = "53"
+
+// lookup asks a name server about qname. With case randomization the name is sent in random case and a
+// response that does not echo it is taken for spoofed: the query is repeated over TCP, and when the
+// server does not echo the case over TCP either, names are sent to it as they are for a while.
+func (s *server) lookup(qname dns.Name, qtype querytype.QueryType, ns net.IP, trace *handler.Trace) (response *dns.DnsPacket, err error) {
+	start := time.Now()
+	trace.Contacted(ns.String())
+	defer func() {
+		observeUpstream(ns.String(), start, err)
+		switch {
+		case isTimeout(err):
+			s.infra.Failure(ns)
+		case err != nil:
+			s.infra.Error(ns)
+		default:
+			s.infra.Success(ns, time.Since(start))
+		}
+	}()
+
+	sent, randomized := qname, s.config.Resolver.CaseRandomization && s.infra.PreservesCase(ns)
+	if randomized {
+		sent = randomizeCase(qname)
+	}
 
-	conn, err := net.Dial("udp", fmt.Sprintf("%s:53", ns.To4().String()))
+	response, err = s.exchangeUDP(sent, qtype, ns)
 	if err != nil {
 		return nil, err
 	}
-	defer conn.Close()
 
-	rawPacket := &dns.DnsPacket{}
+	mismatch := randomized && !echoesCase(response, sent)
+	if mismatch {
+		upstreamCaseMismatches.Inc(ns.String())
+		slog.Warn("response does not echo the case of the query name, asking over TCP", "ns", ns, "name", sent)
+	}
+	if !mismatch && !response.Header.TruncatedMessage {
+		return restoreCase(response, sent, qname), nil
+	}
 
-	rawPacket.Header.ID = 1
-	rawPacket.Header.RecursionDesired = true
-	question := dns.NewQuestion(qname, qtype)
-	rawPacket.Question = append(rawPacket.Question, *question)
-	rawPacket.Header.Questions = 1
+	if response, err = s.exchangeTCP(sent, qtype, ns); err != nil {
+		return nil, err
+	}
+	if randomized && !echoesCase(response, sent) {
+		s.infra.IgnoresCase(ns)
+	}
 
-	requestBuffer := packetbuffer.NewPacketBuffer()
-	rawPacket.Write(&requestBuffer)
+	return restoreCase(response, sent, qname), nil
+}
 
-	conn.Write(requestBuffer.Buffer)
+// exchangeUDP sends a query to a name server over UDP and waits for the response with the same ID,
+// other datagrams are ignored
+func (s *server) exchangeUDP(qname dns.Name, qtype querytype.QueryType, ns net.IP) (*dns.DnsPacket, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(ns.String(), nameServerPort))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(s.config.Resolver.Timeout))
 
-	bpb := packetbuffer.NewPacketBuffer()
+	rawPacket := transport.NewQuery(qname, qtype)
+	rawPacket.Header.RecursionDesired = true
 
-	_, err = conn.Read(bpb.Buffer)
+	query, err := transport.Serialize(rawPacket)
 	if err != nil {
 		return nil, err
 	}
 
-	return rawPacket.FromBuffer(&bpb), nil
+	conn.Write(query)
+	tapped := &dnstap.Message{Type: dnstap.ResolverQuery, QueryAddress: conn.LocalAddr(), ResponseAddress: conn.RemoteAddr(),
+		QueryTime: time.Now(), QueryMessage: query}
+	s.tap(tapped)
+
+	for {
+		bpb := packetbuffer.NewPacketBufferWithSize(packetbuffer.MaxMessageSize)
+		n, err := conn.Read(bpb.Buffer)
+		if err != nil {
+			return nil, err
+		}
+
+		response := rawPacket.FromBuffer(&bpb)
+		if response.Header.ID != rawPacket.Header.ID {
+			slog.Warn("ignoring response with another ID", "ns", ns, "name", qname)
+			continue
+		}
+
+		tapped.Type, tapped.ResponseTime, tapped.ResponseMessage = dnstap.ResolverResponse, time.Now(), bpb.Buffer[:n]
+		s.tap(tapped)
+
+		return response, nil
+	}
 }
 
-func handleQuery(conn *net.UDPConn) error {
-	requestBuffer := packetbuffer.NewPacketBuffer()
-	_, src, err := conn.ReadFromUDP(requestBuffer.Buffer)
+// exchangeTCP sends a query to a name server over TCP, for a response that was truncated over UDP or did
+// not echo the case of the query name
+func (s *server) exchangeTCP(qname dns.Name, qtype querytype.QueryType, ns net.IP) (*dns.DnsPacket, error) {
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(ns.String(), nameServerPort), s.config.Resolver.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(s.config.Resolver.Timeout))
 
+	rawPacket := transport.NewQuery(qname, qtype)
+	rawPacket.Header.RecursionDesired = true
+
+	query, err := transport.Serialize(rawPacket)
 	if err != nil {
-		return fmt.Errorf("failed to read from UDP Socket: %w", err)
+		return nil, err
+	}
+
+	if err := transport.WriteMessage(conn, query); err != nil {
+		return nil, err
 	}
+	tapped := &dnstap.Message{Type: dnstap.ResolverQuery, QueryAddress: conn.LocalAddr(), ResponseAddress: conn.RemoteAddr(),
+		QueryTime: time.Now(), QueryMessage: query}
+	s.tap(tapped)
 
-	reqPacket := dns.NewPacket()
-	request := reqPacket.FromBuffer(&requestBuffer)
+	bpb, err := transport.ReadMessage(conn)
+	if err != nil {
+		return nil, err
+	}
 
-	response := dns.NewPacket()
-	response.Header.ID = request.Header.ID
-	response.Header.RecursionDesired = true
-	response.Header.RecursionAvailable = true
-	response.Header.Response = true
+	response := rawPacket.FromBuffer(bpb)
+	if response.Header.ID != rawPacket.Header.ID {
+		return nil, fmt.Errorf("response from %v over TCP carries another ID", ns)
+	}
 
-	if len(request.Question) > 0 {
-		question := request.Question[0]
+	tapped.Type, tapped.ResponseTime, tapped.ResponseMessage = dnstap.ResolverResponse, time.Now(), bpb.Buffer
+	s.tap(tapped)
 
-		result, err := recursiveLookup(question.Name, question.Qtype)
-		if err != nil {
-			response.Header.Rescode = resultcode.SERVFAIL
-		} else {
-			response.Question = append(response.Question, question)
-			response.Header.Rescode = result.Header.Rescode
-			response.Answers = result.Answers
-			response.Authorities = result.Authorities
-			response.Resources = result.Resources
-		}
-	} else {
+	return response, nil
+}
+
+// verifyRequest checks the TSIG record of a request. It returns the signature and the key the request was
+// signed with, or nils for an unsigned request.
+func (s *server) verifyRequest(message []byte) (*tsig.Signature, *tsig.Key, error) {
+	signature, key, err := s.keys.Verify(message)
+	if errors.Is(err, tsig.ErrUnsigned) {
+		return nil, nil, nil
+	}
+
+	return signature, key, err
+}
+
+// signResponse signs a serialized response to a request that was signed with key
+func signResponse(data []byte, signature *tsig.Signature, key *tsig.Key) []byte {
+	if key == nil {
+		return data
+	}
+
+	signed, _ := key.Sign(data, signature.MAC)
+	return signed
+}
+
+// handleNotify acknowledges a NOTIFY (RFC 1996) from the primary of a secondary zone and schedules a refresh
+func (s *server) handleNotify(request *dns.DnsPacket, response *dns.DnsPacket, src net.IP, key *tsig.Key) {
+	question := request.Question[0]
+	response.Question = append(response.Question, question)
+
+	sec, ok := s.secondaries[question.Name.Canonical()]
+	if !ok {
+		response.Header.Rescode = resultcode.NOTAUTH
+		return
+	}
+
+	if !sec.AcceptsNotify(src, key) {
+		slog.Warn("ignoring NOTIFY, not from a primary or not signed", "zone", question.Name, "client", src)
+		response.Header.Rescode = resultcode.REFUSED
+		return
+	}
+
+	response.Header.AuthoritativeAnswer = true
+	sec.Notify()
+}
+
+// handleUpdate applies a dynamic update (RFC 2136) to a primary zone. Updates for secondary zones
+// would have to be forwarded to the primary, which is not supported.
+func (s *server) handleUpdate(request *dns.DnsPacket, response *dns.DnsPacket, src net.IP, key *tsig.Key) {
+	zoneSection := request.UpdateZone()
+	if zoneSection == nil || zoneSection.Qtype != querytype.SOA {
 		response.Header.Rescode = resultcode.FORMERR
+		return
 	}
+	response.Question = append(response.Question, *zoneSection)
 
-	resBuffer := packetbuffer.NewPacketBuffer()
-	response.Write(&resBuffer)
+	p, ok := s.primaries[zoneSection.Name.Canonical()]
+	switch {
+	case ok:
+		response.Header.Rescode = p.Update(request, src, key)
+	case s.secondaries[zoneSection.Name.Canonical()] != nil:
+		response.Header.Rescode = resultcode.NOTIMP
+	default:
+		response.Header.Rescode = resultcode.NOTAUTH
+	}
+}
 
-	len := resBuffer.Pos()
-	data, err := resBuffer.GetRange(0, len)
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "admin" {
+		os.Exit(runAdmin(os.Args[2:]))
+	}
+
+	cfg, err := loadConfig(flag.CommandLine, os.Args[1:])
 	if err != nil {
-		return fmt.Errorf("failed to serialize response packet: %w", err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
+	setupLogging(cfg.Logging)
 
-	_, err = conn.WriteToUDP(data, src)
+	s, err := newServer(cfg, nil)
 	if err != nil {
-		return fmt.Errorf("failed to send response: %w", err)
+		panic(err)
 	}
 
-	return nil
-}
+	conns, listeners, err := s.listen()
+	if err != nil {
+		panic(err)
+	}
 
-func main() {
-	addr := net.UDPAddr{
-		Port: 2053,
-		IP:   net.ParseIP("0.0.0.0"),
+	registerCacheMetrics(s.cache)
+	metricsServer, err := serveMetrics(cfg.Metrics.Listen)
+	if err != nil {
+		panic(err)
 	}
-	conn, err := net.ListenUDP("udp", &addr)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.start(ctx)
+
+	f := newFrontend(s, conns, listeners)
+	registerDnstapMetrics(f)
+	registerRateLimitMetrics(f)
+	adminServer, err := serveAdmin(ctx, cfg.Admin.Listen, f)
 	if err != nil {
 		panic(err)
 	}
-	defer conn.Close()
+	f.serve()
+	slog.Info("DNS server listening", "udp", cfg.Listen.UDP, "tcp", cfg.Listen.TCP)
+	if cfg.OpenResolver() {
+		slog.Warn("resolving for every client, restrict recursion with acl.allow_recursion or acl.allow_query")
+	}
+	if metricsServer != nil {
+		slog.Info("serving metrics", "url", "http://"+cfg.Metrics.Listen+"/metrics")
+	}
+	if adminServer != nil {
+		slog.Info("serving the admin API", "url", "http://"+cfg.Admin.Listen)
+	}
 
-	fmt.Println("DNS server listening on port 2053")
-	for {
-		if err := handleQuery(conn); err != nil {
-			fmt.Println("Error handling query:", err)
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM)
+	for sig := range signals {
+		if sig == syscall.SIGHUP {
+			if err := reload(ctx, f); err != nil {
+				slog.Error("keeping the current configuration, reload failed", "error", err)
+			}
+			continue
+		}
+
+		slog.Info("waiting for queries in flight", "signal", sig.String())
+		if !f.shutdown(f.current.Load().config.Listen.ShutdownTimeout) {
+			slog.Warn("shutdown timeout passed, dropping the queries still in flight")
+		}
+		if metricsServer != nil {
+			metricsServer.Close()
+		}
+		if adminServer != nil {
+			adminServer.Close()
+		}
+		if queryLog := f.current.Load().queryLog; queryLog != nil {
+			queryLog.Close()
+		}
+		if tap := f.current.Load().dnstap; tap != nil {
+			tap.Close()
 		}
+		return
 	}
 }
+
+// reload rereads the configuration file, applies the command line flags again and replaces the server.
+// On error the current server is kept.
+func reload(ctx context.Context, f *frontend) error {
+	cfg, err := loadConfig(flag.NewFlagSet(os.Args[0], flag.ContinueOnError), os.Args[1:])
+	if err != nil {
+		return err
+	}
+
+	if err := f.reload(ctx, cfg); err != nil {
+		return err
+	}
+
+	slog.Info("configuration reloaded")
+	return nil
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/binary"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"dns-client-go/config"
+	"dns-client-go/dns"
+	"dns-client-go/dnstap"
+	"dns-client-go/handler"
+	infracache "dns-client-go/infra-cache"
+	querytype "dns-client-go/query-type"
+	singleflight "dns-client-go/single-flight"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_SharedLookup_DependsOnItself(t *testing.T) {
+	s := &server{}
+	parents := []flightKey{{name: "www.example.com", qtype: querytype.A}, {name: "ns.example.com", qtype: querytype.A}}
+
+	_, err := s.sharedLookup("NS.Example.com.", querytype.A, nil, parents)
+	assert.ErrorContains(t, err, "depends on itself")
+}
+
+func TestServer_SharedLookup_GluelessCycle(t *testing.T) {
+	// The root refers example.com to ns.example.com without its address, which only the servers of
+	// example.com could give
+	var queries atomic.Int32
+	fakeNameServer(t, func(request *dns.DnsPacket) *dns.DnsPacket {
+		queries.Add(1)
+		response := dns.NewPacket()
+		response.Question = request.Question
+		response.Authorities = []dns.DnsRecord{dns.NewNSRecord("example.com", "ns.example.com", 300)}
+		return response
+	})
+
+	cfg := config.Default()
+	cfg.Resolver.Timeout = time.Second
+	cfg.Resolver.QnameMinimisation = config.MinimiseOff
+	s := &server{config: cfg, infra: infracache.New(), rootHints: []net.IP{net.ParseIP("127.0.0.1")},
+		flights: &singleflight.Group[flightKey, flight]{Wait: resolutionQueries * cfg.Resolver.Timeout}}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		response, err := s.recursiveLookup("www.example.com", querytype.A, nil)
+		assert.NoError(t, err)
+		assert.Empty(t, response.Answers)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the resolution waits for itself")
+	}
+	assert.Equal(t, int32(2), queries.Load(), "www.example.com and ns.example.com are each asked once")
+}
+
+func TestServer_SharedLookup_MutuallyDependentDelegations(t *testing.T) {
+	// The root refers a.test to ns.b.test and b.test to ns.a.test, both without addresses. The referrals
+	// for the name servers are held until both were asked, so that their walks run concurrently.
+	var asked sync.WaitGroup
+	asked.Add(2)
+	fakeNameServer(t, func(request *dns.DnsPacket) *dns.DnsPacket {
+		name := request.Question[0].Name
+		response := dns.NewPacket()
+		response.Question = request.Question
+		switch {
+		case name.IsSubdomainOf("a.test"):
+			response.Authorities = []dns.DnsRecord{dns.NewNSRecord("a.test", "ns.b.test", 300)}
+		case name.IsSubdomainOf("b.test"):
+			response.Authorities = []dns.DnsRecord{dns.NewNSRecord("b.test", "ns.a.test", 300)}
+		}
+		if strings.HasPrefix(name.Canonical().String(), "ns.") {
+			asked.Done()
+			asked.Wait()
+		}
+		return response
+	})
+
+	cfg := config.Default()
+	cfg.Resolver.Timeout = time.Second
+	cfg.Resolver.QnameMinimisation = config.MinimiseOff
+	s := &server{config: cfg, infra: infracache.New(), rootHints: []net.IP{net.ParseIP("127.0.0.1")},
+		flights: &singleflight.Group[flightKey, flight]{Wait: resolutionQueries * cfg.Resolver.Timeout}}
+
+	done := make(chan struct{})
+	for _, qname := range []dns.Name{"www.a.test", "www.b.test"} {
+		go func(qname dns.Name) {
+			defer func() { done <- struct{}{} }()
+			response, err := s.recursiveLookup(qname, querytype.A, nil)
+			assert.NoError(t, err, qname)
+			assert.Empty(t, response.Answers, qname)
+		}(qname)
+	}
+
+	for range []int{0, 1} {
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("the walks of ns.a.test and ns.b.test wait for each other")
+		}
+	}
+}
+
+func TestServer_Lookup_Truncated(t *testing.T) {
+	// The name server truncates its first response, over UDP, and answers the repeated query in full
+	var truncated atomic.Bool
+	tcpQueries := fakeNameServer(t, func(request *dns.DnsPacket) *dns.DnsPacket {
+		response := dns.NewPacket()
+		response.Question = request.Question
+		if truncated.CompareAndSwap(false, true) {
+			response.Header.TruncatedMessage = true
+			return response
+		}
+		response.Header.AuthoritativeAnswer = true
+		response.Answers = []dns.DnsRecord{dns.NewARecord(request.Question[0].Name, "192.0.2.10", 300)}
+		return response
+	})
+
+	path := filepath.Join(t.TempDir(), "dnstap.fstrm")
+	writer, err := dnstap.Open(path, "")
+	assert.NoError(t, err)
+
+	cfg := config.Default()
+	cfg.Resolver.Timeout = time.Second
+	cfg.Resolver.CaseRandomization = false
+	s := &server{config: cfg, infra: infracache.New(), dnstap: writer}
+
+	response, err := s.lookup("www.example.com", querytype.A, net.ParseIP("127.0.0.1"), nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), tcpQueries.Load(), "the query is repeated over TCP")
+	assert.False(t, response.Header.TruncatedMessage)
+	assert.Equal(t, []string{"www.example.com. 300 IN A 192.0.2.10"}, recordStrings(response.Answers))
+
+	writer.Close()
+	assert.Equal(t, 4, countFrames(t, path), "the queries and responses over UDP and TCP are captured")
+}
+
+// countFrames counts the data frames of a Frame Streams file
+func countFrames(t *testing.T, path string) int {
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+
+	frames := 0
+	for len(data) >= 4 {
+		length := binary.BigEndian.Uint32(data)
+		data = data[4:]
+		if length == 0 {
+			// A control frame, prefixed with its own length
+			length = binary.BigEndian.Uint32(data)
+			data = data[4:]
+		} else {
+			frames++
+		}
+		data = data[length:]
+	}
+
+	return frames
+}
+
+func TestServer_Forward_SharedTrace(t *testing.T) {
+	// The upstream answers slowly enough for the second request to join the exchange of the first
+	fakeNameServer(t, func(request *dns.DnsPacket) *dns.DnsPacket {
+		time.Sleep(100 * time.Millisecond)
+		response := dns.NewPacket()
+		response.Question = request.Question
+		response.Answers = []dns.DnsRecord{dns.NewARecord(request.Question[0].Name, "192.0.2.10", 300)}
+		return response
+	})
+	upstream := net.JoinHostPort("127.0.0.1", nameServerPort)
+
+	cfg := config.Default()
+	cfg.Resolver.Timeout = time.Second
+	s := &server{config: cfg, flights: &singleflight.Group[flightKey, flight]{Wait: cfg.Resolver.Timeout}}
+
+	traces := []*handler.Trace{{}, {}}
+	var wg sync.WaitGroup
+	for i, trace := range traces {
+		wg.Add(1)
+		go func(trace *handler.Trace) {
+			defer wg.Done()
+			_, err := s.forward("www.example.com", querytype.A, []string{upstream}, trace)
+			assert.NoError(t, err)
+		}(trace)
+		if i == 0 {
+			time.Sleep(20 * time.Millisecond)
+		}
+	}
+	wg.Wait()
+
+	for _, trace := range traces {
+		assert.Equal(t, []string{upstream}, trace.Upstreams(), "every request logs the upstream")
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"dns-client-go/cache"
+	"dns-client-go/dns"
+	"dns-client-go/handler"
+	"dns-client-go/metrics"
+	ratelimit "dns-client-go/rate-limit"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+var (
+	registry = metrics.NewRegistry()
+
+	queriesTotal = registry.NewCounterVec("dns_queries_total",
+		"Client queries answered, by transport, query type and response code.", "transport", "qtype", "rcode")
+	responseDuration = registry.NewHistogramVec("dns_response_duration_seconds",
+		"Time from receiving a client query to sending the response.", metrics.DefaultBuckets, "transport")
+	inflightQueries = registry.NewGauge("dns_inflight_queries", "Client queries being answered.")
+	queriesShed     = registry.NewCounterVec("dns_queries_shed_total",
+		"Client queries refused or dropped to protect the server, by reason and action.", "reason", "action")
+
+	upstreamQueries = registry.NewCounterVec("dns_upstream_queries_total",
+		"Queries sent to authoritative servers and upstream resolvers, by server.", "server")
+	upstreamTimeouts = registry.NewCounterVec("dns_upstream_timeouts_total",
+		"Queries to authoritative servers and upstream resolvers that timed out, by server.", "server")
+	upstreamCaseMismatches = registry.NewCounterVec("dns_upstream_case_mismatches_total",
+		"Responses of authoritative servers that did not echo the case of the query name, by server.", "server")
+	lookupDuration = registry.NewHistogramVec("dns_lookup_duration_seconds",
+		"Duration of the queries sent to authoritative servers and upstream resolvers.", metrics.DefaultBuckets)
+)
+
+// registerCacheMetrics exposes the statistics of the response cache, which survives reloads
+func registerCacheMetrics(c *cache.Cache) {
+	registry.NewCounterFunc("dns_cache_hits_total", "Responses answered from the cache.", func() float64 {
+		return float64(c.Stats().Hits)
+	})
+	registry.NewCounterFunc("dns_cache_misses_total", "Cache lookups that found no valid response.", func() float64 {
+		return float64(c.Stats().Misses)
+	})
+	registry.NewCounterFunc("dns_cache_evictions_total", "Responses evicted to make room in the cache.", func() float64 {
+		return float64(c.Stats().Evictions)
+	})
+	registry.NewGaugeFunc("dns_cache_entries", "Responses in the cache.", func() float64 {
+		return float64(c.Stats().Entries)
+	})
+}
+
+// registerDnstapMetrics exposes the dnstap messages dropped by the writer of the current server
+func registerDnstapMetrics(f *frontend) {
+	registry.NewCounterFunc("dns_dnstap_dropped_total", "Dnstap messages dropped because the output fell behind.", func() float64 {
+		if tap := f.current.Load().dnstap; tap != nil {
+			return float64(tap.Dropped())
+		}
+		return 0
+	})
+}
+
+// registerRateLimitMetrics exposes the responses limited by the rate limiter of the current server
+func registerRateLimitMetrics(f *frontend) {
+	stats := func() ratelimit.Stats {
+		if limiter := f.current.Load().rateLimiter; limiter != nil {
+			return limiter.Stats()
+		}
+		return ratelimit.Stats{}
+	}
+
+	registry.NewCounterFunc("dns_rate_limit_dropped_total", "UDP responses dropped by rate limiting.", func() float64 {
+		return float64(stats().Dropped)
+	})
+	registry.NewCounterFunc("dns_rate_limit_slipped_total", "UDP responses sent truncated by rate limiting.", func() float64 {
+		return float64(stats().Slipped)
+	})
+}
+
+// serveMetrics starts the HTTP endpoint Prometheus scrapes, it returns nil when none is configured
+func serveMetrics(address string) (*http.Server, error) {
+	if address == "" {
+		return nil, nil
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", registry)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("metrics endpoint failed", "error", err)
+		}
+	}()
+
+	return server, nil
+}
+
+// observeQueries counts the responses sent to clients by transport, query type and response code, and
+// the time answering took
+func observeQueries(next handler.Handler) handler.Handler {
+	return handler.HandlerFunc(func(ctx context.Context, w handler.ResponseWriter, request *dns.DnsPacket) {
+		start := time.Now()
+		next.ServeDNS(ctx, handler.Observe(w, func(response *dns.DnsPacket) {
+			qtype := "none"
+			if len(request.Question) > 0 {
+				qtype = request.Question[0].Qtype.String()
+			}
+
+			transport := w.RemoteAddr().Network()
+			queriesTotal.Inc(transport, qtype, response.Header.Rescode.String())
+			responseDuration.Observe(time.Since(start).Seconds(), transport)
+		}), request)
+	})
+}
+
+// observeUpstream counts a query sent to an authoritative server or upstream resolver
+func observeUpstream(server string, start time.Time, err error) {
+	upstreamQueries.Inc(server)
+	lookupDuration.Observe(time.Since(start).Seconds())
+
+	if isTimeout(err) {
+		upstreamTimeouts.Inc(server)
+	}
+}
+
+// isTimeout reports whether a query failed for want of a response in time
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
@@ -0,0 +1,285 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultBuckets are the upper bounds in seconds of the latency histograms
+var DefaultBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metric is a family of samples written in the Prometheus text exposition format
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds the metrics of the server and serves them to Prometheus
+type Registry struct {
+	mu      sync.Mutex
+	metrics []metric
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.metrics = append(r.metrics, m)
+}
+
+// Write writes all metrics, sorted by name
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	metrics := append([]metric(nil), r.metrics...)
+	r.mu.Unlock()
+
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name() < metrics[j].name() })
+	for _, m := range metrics {
+		m.write(w)
+	}
+}
+
+// ServeHTTP answers scrapes of the /metrics endpoint
+func (r *Registry) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	r.Write(w)
+}
+
+// family is the part shared by all metric types: the name, the help text and the label names
+type family struct {
+	metricName string
+	help       string
+	labels     []string
+}
+
+func (f *family) name() string {
+	return f.metricName
+}
+
+func (f *family) header(w io.Writer, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.metricName, f.help, f.metricName, kind)
+}
+
+// labelPairs formats the labels of a sample, extra is appended as is (used for the le label)
+func (f *family) labelPairs(values []string, extra string) string {
+	var pairs []string
+	for i, label := range f.labels {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, label, labelEscaper.Replace(values[i])))
+	}
+	if extra != "" {
+		pairs = append(pairs, extra)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (f *family) key(values []string) string {
+	if len(values) != len(f.labels) {
+		panic(fmt.Sprintf("metric %v takes %d label values, got %d", f.metricName, len(f.labels), len(values)))
+	}
+
+	return strings.Join(values, "\xff")
+}
+
+// CounterVec counts events, partitioned by label values
+type CounterVec struct {
+	family
+	mu          sync.Mutex
+	values      map[string]float64
+	labelValues map[string][]string
+}
+
+func (r *Registry) NewCounterVec(name string, help string, labels ...string) *CounterVec {
+	c := &CounterVec{family: family{name, help, labels}, values: map[string]float64{}, labelValues: map[string][]string{}}
+	r.register(c)
+	return c
+}
+
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *CounterVec) Add(value float64, labelValues ...string) {
+	key := c.key(labelValues)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.labelValues[key]; !ok {
+		c.labelValues[key] = append([]string(nil), labelValues...)
+	}
+	c.values[key] += value
+}
+
+// Value returns the count for the label values
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.values[c.key(labelValues)]
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.header(w, "counter")
+	for _, key := range sortedKeys(c.values) {
+		fmt.Fprintf(w, "%s%s %s\n", c.metricName, c.labelPairs(c.labelValues[key], ""), formatValue(c.values[key]))
+	}
+}
+
+// Gauge is a value that goes up and down, such as the number of queries in flight
+type Gauge struct {
+	family
+	mu    sync.Mutex
+	value float64
+}
+
+func (r *Registry) NewGauge(name string, help string) *Gauge {
+	g := &Gauge{family: family{metricName: name, help: help}}
+	r.register(g)
+	return g
+}
+
+func (g *Gauge) Add(value float64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.value += value
+}
+
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+func (g *Gauge) Value() float64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.value
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.header(w, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatValue(g.Value()))
+}
+
+// funcMetric reads its value when it is scraped, for values kept elsewhere such as the cache statistics
+type funcMetric struct {
+	family
+	kind  string
+	value func() float64
+}
+
+// NewCounterFunc registers a counter whose value is read from value on every scrape
+func (r *Registry) NewCounterFunc(name string, help string, value func() float64) {
+	r.register(&funcMetric{family{metricName: name, help: help}, "counter", value})
+}
+
+// NewGaugeFunc registers a gauge whose value is read from value on every scrape
+func (r *Registry) NewGaugeFunc(name string, help string, value func() float64) {
+	r.register(&funcMetric{family{metricName: name, help: help}, "gauge", value})
+}
+
+func (f *funcMetric) write(w io.Writer) {
+	f.header(w, f.kind)
+	fmt.Fprintf(w, "%s %s\n", f.metricName, formatValue(f.value()))
+}
+
+// HistogramVec counts observations, such as latencies in seconds, in cumulative buckets
+type HistogramVec struct {
+	family
+	buckets []float64
+	mu      sync.Mutex
+	series  map[string]*histogram
+}
+
+type histogram struct {
+	labels []string
+	counts []uint64 // per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+func (r *Registry) NewHistogramVec(name string, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{family: family{name, help, labels}, buckets: buckets, series: map[string]*histogram{}}
+	r.register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, labelValues ...string) {
+	key := h.key(labelValues)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	series, ok := h.series[key]
+	if !ok {
+		series = &histogram{labels: append([]string(nil), labelValues...), counts: make([]uint64, len(h.buckets))}
+		h.series[key] = series
+	}
+
+	series.count++
+	series.sum += value
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		series.counts[i]++
+	}
+}
+
+func (h *HistogramVec) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.header(w, "histogram")
+	for _, key := range sortedKeys(h.series) {
+		series := h.series[key]
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += series.counts[i]
+			le := fmt.Sprintf(`le="%s"`, formatValue(bound))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelPairs(series.labels, le), cumulative)
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, h.labelPairs(series.labels, `le="+Inf"`), series.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, h.labelPairs(series.labels, ""), formatValue(series.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, h.labelPairs(series.labels, ""), series.count)
+	}
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func formatValue(value float64) string {
+	if math.IsInf(value, 1) {
+		return "+Inf"
+	}
+
+	return strconv.FormatFloat(value, 'g', -1, 64)
+}
+
+// labelEscaper escapes label values as the exposition format requires
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	registry := NewRegistry()
+	queries := registry.NewCounterVec("dns_queries_total", "Queries answered", "transport", "rcode")
+	inflight := registry.NewGauge("dns_inflight_queries", "Queries being answered")
+	latency := registry.NewHistogramVec("dns_response_duration_seconds", "Response latency", []float64{0.01, 0.1}, "transport")
+	registry.NewCounterFunc("dns_cache_hits_total", "Cache hits", func() float64 { return 7 })
+
+	queries.Inc("udp", "NOERROR")
+	queries.Inc("udp", "NOERROR")
+	queries.Add(3, "tcp", `odd"value`)
+	inflight.Inc()
+	inflight.Inc()
+	inflight.Dec()
+	latency.Observe(0.005, "udp")
+	latency.Observe(0.05, "udp")
+	latency.Observe(2, "udp")
+
+	var buffer bytes.Buffer
+	registry.Write(&buffer)
+	assert.Equal(t, `# HELP dns_cache_hits_total Cache hits
+# TYPE dns_cache_hits_total counter
+dns_cache_hits_total 7
+# HELP dns_inflight_queries Queries being answered
+# TYPE dns_inflight_queries gauge
+dns_inflight_queries 1
+# HELP dns_queries_total Queries answered
+# TYPE dns_queries_total counter
+dns_queries_total{transport="tcp",rcode="odd\"value"} 3
+dns_queries_total{transport="udp",rcode="NOERROR"} 2
+# HELP dns_response_duration_seconds Response latency
+# TYPE dns_response_duration_seconds histogram
+dns_response_duration_seconds_bucket{transport="udp",le="0.01"} 1
+dns_response_duration_seconds_bucket{transport="udp",le="0.1"} 2
+dns_response_duration_seconds_bucket{transport="udp",le="+Inf"} 3
+dns_response_duration_seconds_sum{transport="udp"} 2.055
+dns_response_duration_seconds_count{transport="udp"} 3
+`, buffer.String())
+
+	assert.Equal(t, float64(2), queries.Value("udp", "NOERROR"))
+	assert.Panics(t, func() { queries.Inc("udp") })
+
+	recorder := httptest.NewRecorder()
+	registry.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	assert.Equal(t, buffer.String(), recorder.Body.String())
+	assert.Contains(t, recorder.Header().Get("Content-Type"), "text/plain")
+}
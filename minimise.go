@@ -0,0 +1,92 @@
+package main
+
+import (
+	"dns-client-go/config"
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+	resultcode "dns-client-go/result-code"
+)
+
+// maxMinimise is the most minimised queries a walk sends before asking for the full name, so names with
+// many labels do not turn into as many queries (RFC 9156 section 2.3)
+const maxMinimise = 10
+
+// step is what a walk does after the response to a minimised query
+type step int
+
+const (
+	stepFollow step = iota // handle the response like one to the full name: a referral or a failure
+	stepNext               // ask the next minimised name
+	stepDone               // the response answers the full name
+)
+
+// minimiser keeps the state of QNAME minimisation (RFC 9156) during a walk: how many labels of qname
+// were found to be inside the current zone
+type minimiser struct {
+	mode    string
+	qname   dns.Name
+	labels  []string
+	reached int // labels of the deepest name asked about that is no zone cut
+	queries int
+}
+
+func newMinimiser(mode string, qname dns.Name) *minimiser {
+	return &minimiser{mode: mode, qname: qname, labels: qname.Labels()}
+}
+
+func (m *minimiser) strict() bool {
+	return m.mode == config.MinimiseStrict
+}
+
+// next returns the name and type to ask the servers of zone: an NS query for one label more than is known
+// about, or the question itself once it is the next label, or minimisation is off or given up
+func (m *minimiser) next(zone dns.Name, qtype querytype.QueryType) (dns.Name, querytype.QueryType) {
+	if m.mode == config.MinimiseOff || m.queries >= maxMinimise || !m.qname.IsSubdomainOf(zone) {
+		return m.qname, qtype
+	}
+
+	known := zone.CountLabels()
+	if m.reached > known {
+		known = m.reached
+	}
+	if known+1 >= len(m.labels) {
+		return m.qname, qtype
+	}
+
+	return dns.NewName(m.labels[len(m.labels)-known-1:]...), querytype.NS
+}
+
+// handle takes in the sanitized response to the minimised query for name, a referral to the zone referral
+// if it is not empty. In relaxed mode minimisation is given up on NXDOMAIN and errors, which broken
+// servers answer for empty non-terminals.
+func (m *minimiser) handle(name dns.Name, referral dns.Name, response *dns.DnsPacket) step {
+	m.queries++
+
+	switch response.Header.Rescode {
+	case resultcode.NOERROR:
+		if referral != "" {
+			return stepFollow
+		}
+		for _, record := range response.Answers {
+			if record.NS == nil {
+				// A CNAME or other records at name, the full name tells what they mean for the question
+				m.mode = config.MinimiseOff
+				return stepNext
+			}
+		}
+		// No data, or the name servers of a zone on the same servers: a label deeper
+		m.reached = name.CountLabels()
+		return stepNext
+	case resultcode.NXDOMAIN:
+		if m.strict() {
+			return stepDone
+		}
+	default:
+		if m.strict() {
+			return stepFollow
+		}
+	}
+
+	m.mode = config.MinimiseOff
+	return stepNext
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"dns-client-go/config"
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+	resultcode "dns-client-go/result-code"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// minimisedResponse is what the servers of a zone answer to a minimised query
+type minimisedResponse struct {
+	rcode    resultcode.ResultCode
+	referral dns.Name
+	answers  []dns.DnsRecord
+}
+
+func TestMinimiser(t *testing.T) {
+	nodata := minimisedResponse{rcode: resultcode.NOERROR}
+	nxdomain := minimisedResponse{rcode: resultcode.NXDOMAIN}
+	servfail := minimisedResponse{rcode: resultcode.SERVFAIL}
+	deep := strings.Repeat("x.", maxMinimise+3) + "example.com"
+
+	testCases := []struct {
+		name      string
+		mode      string
+		qname     dns.Name
+		zone      dns.Name
+		responses []minimisedResponse
+		expected  []string // the queries sent, then the full question or what the walk does instead
+	}{
+		{
+			name:      "one label at a time",
+			mode:      config.MinimiseRelaxed,
+			qname:     "a.b.c.example.com",
+			zone:      "example.com",
+			responses: []minimisedResponse{nodata, nodata},
+			expected:  []string{"c.example.com NS", "b.c.example.com NS", "a.b.c.example.com A"},
+		},
+		{
+			name:      "from the root",
+			mode:      config.MinimiseStrict,
+			qname:     "www.example.com",
+			zone:      ".",
+			responses: []minimisedResponse{{rcode: resultcode.NOERROR, referral: "com"}},
+			expected:  []string{"com NS", "follow"},
+		},
+		{
+			name:     "off",
+			mode:     config.MinimiseOff,
+			qname:    "a.b.c.example.com",
+			zone:     "example.com",
+			expected: []string{"a.b.c.example.com A"},
+		},
+		{
+			name:      "referral followed",
+			mode:      config.MinimiseRelaxed,
+			qname:     "a.b.c.example.com",
+			zone:      "example.com",
+			responses: []minimisedResponse{{rcode: resultcode.NOERROR, referral: "c.example.com"}},
+			expected:  []string{"c.example.com NS", "follow"},
+		},
+		{
+			name:  "name servers of a zone on the same servers",
+			mode:  config.MinimiseStrict,
+			qname: "a.b.c.example.com",
+			zone:  "example.com",
+			responses: []minimisedResponse{
+				{rcode: resultcode.NOERROR, answers: []dns.DnsRecord{dns.NewNSRecord("c.example.com", "ns.example.com", 300)}},
+				nodata,
+			},
+			expected: []string{"c.example.com NS", "b.c.example.com NS", "a.b.c.example.com A"},
+		},
+		{
+			name:      "relaxed NXDOMAIN falls back to the full name",
+			mode:      config.MinimiseRelaxed,
+			qname:     "a.b.c.example.com",
+			zone:      "example.com",
+			responses: []minimisedResponse{nxdomain},
+			expected:  []string{"c.example.com NS", "a.b.c.example.com A"},
+		},
+		{
+			name:      "strict NXDOMAIN answers the full name",
+			mode:      config.MinimiseStrict,
+			qname:     "a.b.c.example.com",
+			zone:      "example.com",
+			responses: []minimisedResponse{nxdomain},
+			expected:  []string{"c.example.com NS", "done"},
+		},
+		{
+			name:      "relaxed error falls back to the full name",
+			mode:      config.MinimiseRelaxed,
+			qname:     "a.b.c.example.com",
+			zone:      "example.com",
+			responses: []minimisedResponse{nodata, servfail},
+			expected:  []string{"c.example.com NS", "b.c.example.com NS", "a.b.c.example.com A"},
+		},
+		{
+			name:      "strict error is a failure",
+			mode:      config.MinimiseStrict,
+			qname:     "a.b.c.example.com",
+			zone:      "example.com",
+			responses: []minimisedResponse{servfail},
+			expected:  []string{"c.example.com NS", "follow"},
+		},
+		{
+			name:  "CNAME at an intermediate name",
+			mode:  config.MinimiseStrict,
+			qname: "a.b.c.example.com",
+			zone:  "example.com",
+			responses: []minimisedResponse{
+				{rcode: resultcode.NOERROR, answers: []dns.DnsRecord{dns.NewCNAMERecord("c.example.com", "c.example.net", 300)}},
+			},
+			expected: []string{"c.example.com NS", "a.b.c.example.com A"},
+		},
+		{
+			name:      "capped",
+			mode:      config.MinimiseStrict,
+			qname:     dns.Name(deep),
+			zone:      "example.com",
+			responses: []minimisedResponse{nodata, nodata, nodata, nodata, nodata, nodata, nodata, nodata, nodata, nodata},
+			expected: []string{
+				"x.example.com NS", "x.x.example.com NS", "x.x.x.example.com NS", "x.x.x.x.example.com NS",
+				"x.x.x.x.x.example.com NS", "x.x.x.x.x.x.example.com NS", "x.x.x.x.x.x.x.example.com NS",
+				"x.x.x.x.x.x.x.x.example.com NS", "x.x.x.x.x.x.x.x.x.example.com NS",
+				"x.x.x.x.x.x.x.x.x.x.example.com NS", deep + " A",
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			m := newMinimiser(tc.mode, tc.qname)
+			var walked []string
+			for i := 0; ; i++ {
+				name, nameType := m.next(tc.zone, querytype.A)
+				walked = append(walked, name.String()+" "+nameType.String())
+				if name == tc.qname {
+					break
+				}
+				if !assert.Less(t, i, len(tc.responses), "unexpected query for %v", name) {
+					return
+				}
+
+				response := dns.NewPacket()
+				response.Header.Rescode = tc.responses[i].rcode
+				response.Answers = tc.responses[i].answers
+				switch m.handle(name, tc.responses[i].referral, response) {
+				case stepFollow:
+					walked = append(walked, "follow")
+				case stepDone:
+					walked = append(walked, "done")
+				default:
+					continue
+				}
+				break
+			}
+
+			assert.Equal(t, tc.expected, walked)
+		})
+	}
+}
@@ -0,0 +1,11 @@
+package opcode
+
+type Opcode uint8
+
+const (
+	QUERY  Opcode = 0
+	IQUERY Opcode = 1
+	STATUS Opcode = 2
+	NOTIFY Opcode = 4
+	UPDATE Opcode = 5
+)
@@ -0,0 +1,124 @@
+package packetbuffer
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Names are handled as text in presentation format (RFC 1035 section 5.1): labels are separated by dots,
+// and the bytes of a label that would be mistaken for a separator or are not printable ASCII are escaped as
+// \. or \DDD, so a label holding a dot, a space or binary data keeps its meaning between wire and text.
+
+// maxNameLength is the longest name on the wire, length octets and the terminating root label included
+const maxNameLength = 255
+
+var errNameTooLong = errors.New("name exceeds 255 octets")
+
+// EscapeLabel returns a raw label in presentation format. Besides dots and backslashes, the characters
+// special in zone files are escaped so that names survive them.
+func EscapeLabel(label string) string {
+	var b strings.Builder
+	for i := 0; i < len(label); i++ {
+		c := label[i]
+		switch {
+		case c == '.' || c == '\\' || c == '"' || c == '(' || c == ')' || c == ';' || c == '@' || c == '$':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x21 || c > 0x7e:
+			fmt.Fprintf(&b, "\\%03d", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+// JoinLabels returns the name made of the raw labels in presentation format, without a trailing dot
+func JoinLabels(labels []string) string {
+	escaped := make([]string, len(labels))
+	for i, label := range labels {
+		escaped[i] = EscapeLabel(label)
+	}
+
+	return strings.Join(escaped, ".")
+}
+
+// SplitName returns the raw labels of a name in presentation format, none for the root ("" or "."). A
+// trailing dot is optional. Malformed escapes are taken literally and reported along with empty labels,
+// the labels are returned either way.
+func SplitName(name string) ([]string, error) {
+	if name == "" || name == "." {
+		return nil, nil
+	}
+
+	var labels []string
+	var label []byte
+	var err error
+
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		switch {
+		case c == '\\' && i+4 <= len(name) && isDigits(name[i+1:i+4]):
+			value, _ := strconv.Atoi(name[i+1 : i+4])
+			if value > 255 {
+				err = fmt.Errorf("invalid escape in %q", name)
+				label = append(label, c)
+				continue
+			}
+			label = append(label, byte(value))
+			i += 3
+		case c == '\\' && i+1 < len(name):
+			i++
+			label = append(label, name[i])
+		case c == '\\':
+			err = fmt.Errorf("dangling escape in %q", name)
+			label = append(label, c)
+		case c == '.':
+			if len(label) == 0 {
+				err = fmt.Errorf("empty label in %q", name)
+			}
+			labels = append(labels, string(label))
+			label = label[:0]
+		default:
+			label = append(label, c)
+		}
+	}
+
+	// A trailing dot ends the name rather than starting an empty label
+	if len(label) > 0 {
+		labels = append(labels, string(label))
+	}
+
+	return labels, err
+}
+
+// IsAbsolute reports whether a name in presentation format ends with an unescaped dot
+func IsAbsolute(name string) bool {
+	if !strings.HasSuffix(name, ".") {
+		return false
+	}
+
+	backslashes := 0
+	for i := len(name) - 2; i >= 0 && name[i] == '\\'; i-- {
+		backslashes++
+	}
+
+	return backslashes%2 == 0
+}
+
+func isDigits(value string) bool {
+	if len(value) == 0 {
+		return false
+	}
+
+	for i := 0; i < len(value); i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -2,11 +2,14 @@ package packetbuffer
 
 import (
 	"errors"
-	"strings"
 )
 
 const bufferSize = 512
 
+// MaxMessageSize is the largest DNS message that fits the two byte length
+// prefix used on TCP connections.
+const MaxMessageSize = 65535
+
 var errEndOfBuffer = errors.New("end of buffer")
 
 type PacketBuffer struct {
@@ -21,6 +24,23 @@ func NewPacketBuffer() PacketBuffer {
 	}
 }
 
+// NewPacketBufferWithSize allocates a buffer for messages larger than the
+// classic 512 byte UDP limit, such as TCP responses and zone transfers.
+func NewPacketBufferWithSize(size uint) PacketBuffer {
+	return PacketBuffer{
+		Buffer:   make([]byte, size),
+		position: 0,
+	}
+}
+
+// NewPacketBufferFrom wraps an already received message for reading.
+func NewPacketBufferFrom(data []byte) PacketBuffer {
+	return PacketBuffer{
+		Buffer:   data,
+		position: 0,
+	}
+}
+
 func (pb PacketBuffer) Pos() uint {
 	return pb.position
 }
@@ -44,7 +64,7 @@ func (pb *PacketBuffer) SetValue_u16(pos uint, val uint16) {
 
 // Read single byte and move the position one step forward
 func (pb *PacketBuffer) Read() (byte, error) {
-	if pb.Pos() >= bufferSize {
+	if pb.Pos() >= uint(len(pb.Buffer)) {
 		newError := errEndOfBuffer
 		return 0, newError
 	}
@@ -56,18 +76,18 @@ func (pb *PacketBuffer) Read() (byte, error) {
 
 // Get a single byte, without changing the buffer position
 func (pb PacketBuffer) Get(pos uint) (byte, error) {
-	if pb.Pos() >= bufferSize {
+	if pos >= uint(len(pb.Buffer)) {
 		newError := errEndOfBuffer
 		return 0, newError
 	}
 	return pb.Buffer[pos], nil
 }
 
-func (pb PacketBuffer) GetRange(start uint, len uint) ([]byte, error) {
-	if start+len > bufferSize {
+func (pb PacketBuffer) GetRange(start uint, length uint) ([]byte, error) {
+	if start+length > uint(len(pb.Buffer)) {
 		return nil, errEndOfBuffer
 	}
-	buffer := pb.Buffer[start : start+len]
+	buffer := pb.Buffer[start : start+length]
 	return buffer, nil
 }
 
@@ -105,8 +125,11 @@ func (pb *PacketBuffer) Read_u32() (uint32, error) {
 	return result, nil
 }
 
+// ReadQname reads a possibly compressed name and returns it in presentation format, escaping the bytes of
+// labels that are not plain text
 func (pb *PacketBuffer) ReadQname() (string, error) {
 	var labels []string
+	nameLength := 1
 	var returnPos *uint
 
 	parseData := pb.Buffer
@@ -121,6 +144,11 @@ func (pb *PacketBuffer) ReadQname() (string, error) {
 		length := parseData[pos]
 		pos++
 
+		// A zero length label terminates the name, which also covers the root name
+		if length == 0 {
+			break
+		}
+
 		if length&0xC0 == 0xC0 {
 			if len(parseData) < int(pos+2) {
 				return "", errors.New("unexpected EOF")
@@ -149,32 +177,29 @@ func (pb *PacketBuffer) ReadQname() (string, error) {
 				return "", errors.New("unexpected EOF")
 			}
 
+			nameLength += 1 + int(length)
+			if nameLength > maxNameLength {
+				return "", errNameTooLong
+			}
+
 			labels = append(labels, string(parseData[pos:end]))
 			pos = uint(end)
-
-			if len(parseData) <= int(pos) {
-				return "", errors.New("unexpected EOF")
-			}
 		} else {
 			return "", errors.New("unknown label format")
 		}
-
-		if parseData[pos] == 0 {
-			break
-		}
 	}
 
 	if returnPos != nil {
 		pb.position = *returnPos + 2
 	} else {
-		pb.position = pos + 1
+		pb.position = pos
 	}
 
-	return strings.Join(labels, "."), nil
+	return JoinLabels(labels), nil
 }
 
 func (pb *PacketBuffer) Write(val uint8) error {
-	if pb.Pos() >= bufferSize {
+	if pb.Pos() >= uint(len(pb.Buffer)) {
 		newError := errEndOfBuffer
 		return newError
 	}
@@ -201,8 +226,33 @@ func (pb *PacketBuffer) Write_uint32(val uint32) error {
 	return nil
 }
 
+// WriteBytes copies raw data, e.g. record data of unknown types, into the buffer
+func (pb *PacketBuffer) WriteBytes(data []byte) error {
+	for _, b := range data {
+		if err := pb.Write(b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteQname writes a name given in presentation format, with its escapes resolved, without compression
 func (pb *PacketBuffer) WriteQname(qname string) error {
-	for _, label := range strings.Split(qname, ".") {
+	labels, err := SplitName(qname)
+	if err != nil {
+		return err
+	}
+
+	nameLength := 1
+	for _, label := range labels {
+		nameLength += 1 + len(label)
+	}
+	if nameLength > maxNameLength {
+		return errNameTooLong
+	}
+
+	// The root name is only the terminating empty label
+	for _, label := range labels {
 		len := len(label)
 		if len > 0x3F {
 			return errors.New("single label exceeds 63 characters of length")
@@ -219,7 +269,7 @@ func (pb *PacketBuffer) WriteQname(qname string) error {
 			}
 		}
 	}
-	err := pb.Write_uint8(uint8(0))
+	err = pb.Write_uint8(uint8(0))
 	if err != nil {
 		return err
 	}
@@ -2,6 +2,7 @@ package packetbuffer
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -51,6 +52,20 @@ func TestReadQname(t *testing.T) {
 	}
 }
 
+func TestReadQnamePreservesCase(t *testing.T) {
+	buffer := NewPacketBuffer()
+	buffer.WriteQname("wWw.ExAmple.COM")
+	buffer.SetPosition(0)
+
+	qname, err := buffer.ReadQname()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if qname != "wWw.ExAmple.COM" {
+		t.Errorf("Unexpected qname: expected wWw.ExAmple.COM, actual %v", qname)
+	}
+}
+
 func TestReadQnameWithJump(t *testing.T) {
 	packet := []byte{
 		0x12, 0x34, 0x81, 0x80, 0x00, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00,
@@ -186,6 +201,27 @@ func TestBytePacketBuffer_Write_qname(t *testing.T) {
 			expected: []byte{0x03, 'w', 'w', 'w', 0x07, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0x03, 'c', 'o', 'm', 0x00},
 			wantErr:  false,
 		},
+		{
+			name:     "escaped dot and decimal escape",
+			qname:    `a\.b\032c.com.`,
+			expected: []byte{0x05, 'a', '.', 'b', ' ', 'c', 0x03, 'c', 'o', 'm', 0x00},
+			wantErr:  false,
+		},
+		{
+			name:    "empty label",
+			qname:   "www..com",
+			wantErr: true,
+		},
+		{
+			name:    "escape out of range",
+			qname:   `a\256.com`,
+			wantErr: true,
+		},
+		{
+			name:    "exceeds name length",
+			qname:   strings.Repeat("abcdefghi.", 26),
+			wantErr: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -204,3 +240,51 @@ func TestBytePacketBuffer_Write_qname(t *testing.T) {
 		})
 	}
 }
+
+func TestQnameRoundTrip(t *testing.T) {
+	// Labels holding separators, spaces and binary data must come back as the same labels
+	names := []string{
+		`www\.example.com`,
+		`a\032b.example.com`,
+		`\000\255\\.example`,
+		`\;\(\)\"\@\$.example`,
+		`WwW.ExAmple.COM`,
+		"",
+	}
+
+	for _, name := range names {
+		buffer := NewPacketBuffer()
+		if err := buffer.WriteQname(name); err != nil {
+			t.Fatalf("WriteQname(%q) error = %v", name, err)
+		}
+		wire := append([]byte(nil), buffer.Buffer[:buffer.Pos()]...)
+
+		buffer.SetPosition(0)
+		read, err := buffer.ReadQname()
+		if err != nil {
+			t.Fatalf("ReadQname() error = %v", err)
+		}
+		if read != name {
+			t.Errorf("ReadQname() got = %q, want %q", read, name)
+		}
+
+		again := NewPacketBuffer()
+		again.WriteQname(read)
+		if !reflect.DeepEqual(again.Buffer[:again.Pos()], wire) {
+			t.Errorf("WriteQname(%q) got = %v, want %v", read, again.Buffer[:again.Pos()], wire)
+		}
+	}
+}
+
+func TestReadQnameEscapes(t *testing.T) {
+	data := []byte{0x04, 'a', '.', 'b', 0xff, 0x03, 'c', 'o', 'm', 0x00}
+	buffer := NewPacketBufferFrom(data)
+
+	qname, err := buffer.ReadQname()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if qname != `a\.b\255.com` {
+		t.Errorf("Unexpected qname: expected %v, actual %v", `a\.b\255.com`, qname)
+	}
+}
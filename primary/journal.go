@@ -0,0 +1,128 @@
+package primary
+
+import (
+	"bufio"
+	"dns-client-go/zone"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Journal persists the changes made by dynamic updates so they survive a restart. Every entry is
+// written in presentation format:
+//
+//	update <from serial> <to serial>
+//	- <old SOA>
+//	- <deleted record>
+//	+ <new SOA>
+//	+ <added record>
+//	end
+//
+// Entries without the closing end line, e.g. after a crash while writing, are ignored.
+type Journal struct {
+	path string
+}
+
+func NewJournal(path string) *Journal {
+	return &Journal{path: path}
+}
+
+// Append writes the difference to the journal and syncs it to disk
+func (j *Journal) Append(diff *zone.Diff) error {
+	file, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	var entry strings.Builder
+	fmt.Fprintf(&entry, "update %d %d\n", diff.From.SOA.Serial(), diff.To.SOA.Serial())
+	fmt.Fprintf(&entry, "- %s\n", diff.From.String())
+	for _, record := range diff.Deleted {
+		fmt.Fprintf(&entry, "- %s\n", record.String())
+	}
+	fmt.Fprintf(&entry, "+ %s\n", diff.To.String())
+	for _, record := range diff.Added {
+		fmt.Fprintf(&entry, "+ %s\n", record.String())
+	}
+	entry.WriteString("end\n")
+
+	if _, err := file.WriteString(entry.String()); err != nil {
+		return err
+	}
+
+	return file.Sync()
+}
+
+// Read returns the complete entries of the journal in the order they were written
+func (j *Journal) Read() ([]*zone.Diff, error) {
+	file, err := os.Open(j.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var diffs []*zone.Diff
+	var current *zone.Diff
+	var fromSeen, toSeen bool
+	lineNumber := 0
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "update "):
+			current, fromSeen, toSeen = &zone.Diff{}, false, false
+		case line == "end":
+			if current != nil && fromSeen && toSeen {
+				diffs = append(diffs, current)
+			}
+			current = nil
+		case current != nil && (strings.HasPrefix(line, "- ") || strings.HasPrefix(line, "+ ")):
+			record, err := zone.ParseRecord(line[2:])
+			if err != nil {
+				return nil, fmt.Errorf("%s line %d: %w", j.path, lineNumber, err)
+			}
+
+			switch {
+			case line[0] == '-' && !fromSeen:
+				current.From, fromSeen = *record, record.SOA != nil
+			case line[0] == '-':
+				current.Deleted = append(current.Deleted, *record)
+			case !toSeen:
+				current.To, toSeen = *record, record.SOA != nil
+			default:
+				current.Added = append(current.Added, *record)
+			}
+		}
+	}
+
+	return diffs, scanner.Err()
+}
+
+// Replay applies the journal entries that continue from the serial of z, skipping entries
+// made against older versions of the zone file. It returns the resulting zone and the applied differences.
+func (j *Journal) Replay(z *zone.Zone) (*zone.Zone, []*zone.Diff, error) {
+	diffs, err := j.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var applied []*zone.Diff
+	for _, diff := range diffs {
+		if diff.From.SOA.Serial() != z.Serial() {
+			continue
+		}
+
+		if z, err = diff.Apply(z); err != nil {
+			return nil, nil, err
+		}
+		applied = append(applied, diff)
+	}
+
+	return z, applied, nil
+}
@@ -0,0 +1,60 @@
+package primary
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+	"dns-client-go/zone"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJournal_Replay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "example.com.jnl")
+	journal := NewJournal(path)
+
+	soa := dns.NewSOARecord("example.com", "ns1.example.com", "admin.example.com", 1, 3600, 600, 86400, 300, 3600)
+	base := zone.New("example.com")
+	base.Add(soa)
+	base.Add(dns.NewARecord("www.example.com", "192.0.2.10", 300))
+
+	diffs := []*zone.Diff{
+		{
+			From:    soa,
+			To:      soa.SOA.WithSerial(2),
+			Deleted: []dns.DnsRecord{dns.NewARecord("www.example.com", "192.0.2.10", 300)},
+			Added:   []dns.DnsRecord{dns.NewARecord("www.example.com", "192.0.2.11", 300)},
+		},
+		{
+			From:  soa.SOA.WithSerial(2),
+			To:    soa.SOA.WithSerial(3),
+			Added: []dns.DnsRecord{dns.NewUnknownRecord("txt.example.com", querytype.QueryType(16), 1, []byte{3, 'a', 'b', 'c'}, 60)},
+		},
+	}
+	for _, diff := range diffs {
+		assert.NoError(t, journal.Append(diff))
+	}
+
+	// A torn entry at the end of the journal is ignored
+	file, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	file.WriteString("update 3 4\n- example.com. 3600 IN SOA ns1.example.com. admin.example.com. 3 3600 600 86400 300\n")
+	file.Close()
+
+	replayed, applied, err := journal.Replay(base)
+	assert.NoError(t, err)
+	assert.Len(t, applied, 2)
+	assert.Equal(t, uint32(3), replayed.Serial())
+	assert.Equal(t, []dns.DnsRecord{dns.NewARecord("www.example.com", "192.0.2.11", 300)}, replayed.RRset("www.example.com", querytype.A))
+	assert.Len(t, replayed.RRset("txt.example.com", querytype.QueryType(16)), 1)
+
+	// Entries made against an older zone file are skipped
+	newer := zone.New("example.com")
+	newer.Add(soa.SOA.WithSerial(5))
+	replayed, applied, err = journal.Replay(newer)
+	assert.NoError(t, err)
+	assert.Empty(t, applied)
+	assert.Equal(t, uint32(5), replayed.Serial())
+}
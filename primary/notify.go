@@ -0,0 +1,47 @@
+package primary
+
+import (
+	"dns-client-go/dns"
+	"dns-client-go/opcode"
+	querytype "dns-client-go/query-type"
+	resultcode "dns-client-go/result-code"
+	"dns-client-go/transport"
+	"dns-client-go/tsig"
+	"errors"
+	"fmt"
+	"time"
+)
+
+const notifyAttempts = 5
+
+// notifyTimeout is doubled after every unanswered attempt
+var notifyTimeout = 2 * time.Second
+
+// SendNotify tells target that zone changed (RFC 1996), repeating the NOTIFY until the target acknowledges it.
+// The NOTIFY is signed when key is not nil.
+func SendNotify(zone dns.Name, soa []dns.DnsRecord, target string, key *tsig.Key) error {
+	query := transport.NewQuery(zone, querytype.SOA)
+	query.Header.Opcode = opcode.NOTIFY
+	query.Header.AuthoritativeAnswer = true
+	query.Answers = soa
+
+	timeout := notifyTimeout
+	var err error
+	for attempt := 0; attempt < notifyAttempts; attempt++ {
+		var response *dns.DnsPacket
+		response, err = transport.Exchange(query, target, timeout, key)
+		if err == nil {
+			if !response.Header.Response || response.Header.Opcode != opcode.NOTIFY {
+				return errors.New("unexpected reply to NOTIFY")
+			}
+			if response.Header.Rescode != resultcode.NOERROR {
+				return fmt.Errorf("NOTIFY answered with rcode %v", response.Header.Rescode)
+			}
+			return nil
+		}
+
+		timeout *= 2
+	}
+
+	return fmt.Errorf("no answer after %d attempts: %w", notifyAttempts, err)
+}
@@ -0,0 +1,59 @@
+package primary
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"dns-client-go/dns"
+	"dns-client-go/opcode"
+	packetbuffer "dns-client-go/packetbuffer"
+	"dns-client-go/transport"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSendNotify_Retries(t *testing.T) {
+	notifyTimeout = 50 * time.Millisecond
+
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.ParseIP("127.0.0.1")})
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	defer conn.Close()
+
+	received := make(chan *dns.DnsPacket, notifyAttempts)
+	go func() {
+		for attempt := 0; ; attempt++ {
+			buffer := packetbuffer.NewPacketBuffer()
+			_, src, err := conn.ReadFromUDP(buffer.Buffer)
+			if err != nil {
+				return
+			}
+
+			request := dns.NewPacket().FromBuffer(&buffer)
+			received <- request
+			if attempt == 0 {
+				continue // drop the first NOTIFY to force a retry
+			}
+
+			response := dns.NewPacket()
+			response.Header.ID = request.Header.ID
+			response.Header.Opcode = opcode.NOTIFY
+			response.Header.Response = true
+			response.Question = request.Question
+			data, _ := transport.Serialize(response)
+			conn.WriteToUDP(data, src)
+		}
+	}()
+
+	soa := []dns.DnsRecord{dns.NewSOARecord("example.com", "ns1.example.com", "admin.example.com", 7, 3600, 600, 86400, 300, 3600)}
+	assert.NoError(t, SendNotify("example.com", soa, conn.LocalAddr().String(), nil))
+	assert.Len(t, received, 2)
+
+	request := <-received
+	assert.Equal(t, opcode.NOTIFY, request.Header.Opcode)
+	assert.True(t, request.Header.AuthoritativeAnswer)
+	assert.Equal(t, dns.Name("example.com"), request.Question[0].Name)
+	assert.Equal(t, uint32(7), request.GetSOA().Serial())
+}
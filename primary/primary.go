@@ -0,0 +1,213 @@
+package primary
+
+import (
+	"context"
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+	resultcode "dns-client-go/result-code"
+	"dns-client-go/tsig"
+	"dns-client-go/update"
+	"dns-client-go/zone"
+	"log/slog"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// pollInterval is how often the zone file is checked for changes
+const pollInterval = 5 * time.Second
+
+type Config struct {
+	Zone         dns.Name
+	File         string
+	Journal      string       // where dynamic updates are recorded, defaults to the zone file with a .jnl suffix
+	Secondaries  []string     // host:port of the servers that are notified and may transfer the zone
+	Transfers    []*net.IPNet // networks that may transfer the zone as well
+	Key          *tsig.Key    // signs the NOTIFY messages and, when set, is required for transfers instead of a secondary's address
+	UpdatePolicy update.Policy
+}
+
+// Primary serves a zone loaded from a master file. Whenever the file changes or a dynamic update is
+// applied the zone is swapped in the store and the secondaries are told about the new serial with a NOTIFY.
+type Primary struct {
+	config   Config
+	store    *zone.Store
+	journal  *Journal
+	mu       sync.Mutex // serializes reloads and updates
+	modified time.Time
+	history  []*zone.Diff // changes since the zone file was loaded, used to answer IXFR
+}
+
+func New(config Config, store *zone.Store) *Primary {
+	config.Zone = config.Zone.Canonical()
+	if config.Journal == "" {
+		config.Journal = config.File + ".jnl"
+	}
+
+	return &Primary{
+		config:  config,
+		store:   store,
+		journal: NewJournal(config.Journal),
+	}
+}
+
+func (p *Primary) Zone() dns.Name {
+	return p.config.Zone
+}
+
+// Load reads the zone file, replays the update journal on top of it and installs the zone,
+// notifying the secondaries when the serial changed
+func (p *Primary) Load() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	info, err := os.Stat(p.config.File)
+	if err != nil {
+		return err
+	}
+
+	loaded, err := zone.LoadFile(p.config.File, p.config.Zone)
+	if err != nil {
+		return err
+	}
+
+	loaded, history, err := p.journal.Replay(loaded)
+	if err != nil {
+		return err
+	}
+	p.modified = info.ModTime()
+	p.history = history
+
+	previous := p.store.Get(p.config.Zone)
+	p.store.Replace(loaded)
+
+	if previous != nil && previous.Serial() != loaded.Serial() {
+		p.NotifySecondaries()
+	}
+
+	return nil
+}
+
+// Run reloads the zone file whenever its modification time changes, until the context is cancelled
+func (p *Primary) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		p.mu.Lock()
+		modified := p.modified
+		p.mu.Unlock()
+
+		info, err := os.Stat(p.config.File)
+		if err != nil || info.ModTime().Equal(modified) {
+			continue
+		}
+
+		if err := p.Load(); err != nil {
+			slog.Error("failed to reload zone", "zone", p.config.Zone, "error", err)
+		}
+	}
+}
+
+// Update applies a dynamic update (RFC 2136) after checking the update policy. Accepted changes are
+// written to the journal before the new version of the zone is installed. key is the TSIG key the
+// request was signed with, nil for unsigned requests.
+func (p *Primary) Update(request *dns.DnsPacket, src net.IP, key *tsig.Key) resultcode.ResultCode {
+	if !p.config.UpdatePolicy.AllowsClient(src) && (key == nil || !p.config.UpdatePolicy.AllowsKey(key.Name)) {
+		slog.Warn("refusing update", "zone", p.config.Zone, "client", src)
+		return resultcode.REFUSED
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	current := p.store.Get(p.config.Zone)
+	if current == nil {
+		return resultcode.SERVFAIL
+	}
+
+	updated, diff, rescode := update.Apply(current, request, p.config.UpdatePolicy)
+	if rescode != resultcode.NOERROR || diff == nil {
+		return rescode
+	}
+
+	if err := p.journal.Append(diff); err != nil {
+		slog.Error("failed to journal update", "zone", p.config.Zone, "error", err)
+		return resultcode.SERVFAIL
+	}
+
+	p.history = append(p.history, diff)
+	p.store.Replace(updated)
+	p.NotifySecondaries()
+
+	return resultcode.NOERROR
+}
+
+// Diffs returns the changes leading from one serial to another, or false when the
+// history does not cover that range
+func (p *Primary) Diffs(from uint32, to uint32) ([]*zone.Diff, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var diffs []*zone.Diff
+	for _, diff := range p.history {
+		if len(diffs) == 0 && diff.From.SOA.Serial() != from {
+			continue
+		}
+
+		diffs = append(diffs, diff)
+		if diff.To.SOA.Serial() == to {
+			return diffs, true
+		}
+	}
+
+	return nil, false
+}
+
+// NotifySecondaries sends a NOTIFY for the current serial to every secondary in the background
+func (p *Primary) NotifySecondaries() {
+	current := p.store.Get(p.config.Zone)
+	if current == nil {
+		return
+	}
+
+	soa := current.RRset(p.config.Zone, querytype.SOA)
+	for _, secondary := range p.config.Secondaries {
+		go func(target string) {
+			if err := SendNotify(p.config.Zone, soa, target, p.config.Key); err != nil {
+				slog.Warn("NOTIFY failed", "zone", p.config.Zone, "secondary", target, "error", err)
+			}
+		}(secondary)
+	}
+}
+
+// AllowTransfer reports whether a transfer request from ip, signed with key or unsigned when key is nil, is
+// permitted. Zones with a key require it, otherwise ip must belong to one of the configured secondaries or
+// transfer networks.
+func (p *Primary) AllowTransfer(ip net.IP, key *tsig.Key) bool {
+	if p.config.Key != nil {
+		return key != nil && key.Name == p.config.Key.Name
+	}
+
+	for _, secondary := range p.config.Secondaries {
+		host, _, err := net.SplitHostPort(secondary)
+		if err == nil && net.ParseIP(host).Equal(ip) {
+			return true
+		}
+	}
+
+	for _, network := range p.config.Transfers {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,98 @@
+package querylog
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// Cache statuses of an entry
+const (
+	CacheHit  = "hit"
+	CacheMiss = "miss"
+)
+
+// Entry is one line of the query log
+type Entry struct {
+	Time      time.Time `json:"time"`
+	Client    net.IP    `json:"client"`
+	Transport string    `json:"transport"`
+	Name      string    `json:"name"`
+	// UnicodeName is the name with its A-labels in Unicode, set only for internationalized names
+	UnicodeName string  `json:"unicode_name,omitempty"`
+	Type        string  `json:"type"`
+	Rcode       string  `json:"rcode"`
+	Answers     int     `json:"answers"`
+	LatencyMs   float64 `json:"latency_ms"`
+	// Cache is empty for answers from zones, static records and blocklists, which are not cached
+	Cache     string   `json:"cache,omitempty"`
+	Upstreams []string `json:"upstreams,omitempty"`
+}
+
+// Logger writes entries as JSON lines. It is safe for concurrent use.
+type Logger struct {
+	mu        sync.Mutex
+	w         io.Writer
+	closer    io.Closer
+	anonymize bool
+}
+
+// New writes entries to w. With anonymize the client addresses are truncated, see Anonymize.
+func New(w io.Writer, anonymize bool) *Logger {
+	return &Logger{w: w, anonymize: anonymize}
+}
+
+// Open appends entries to a file, or writes them to standard output when path is "-"
+func Open(path string, anonymize bool) (*Logger, error) {
+	if path == "-" {
+		return New(os.Stdout, anonymize), nil
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o640)
+	if err != nil {
+		return nil, err
+	}
+
+	l := New(file, anonymize)
+	l.closer = file
+	return l, nil
+}
+
+func (l *Logger) Log(entry Entry) error {
+	if l.anonymize {
+		entry.Client = Anonymize(entry.Client)
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	_, err = l.w.Write(append(line, '\n'))
+	return err
+}
+
+// Close closes the file the entries are written to
+func (l *Logger) Close() error {
+	if l.closer == nil {
+		return nil
+	}
+
+	return l.closer.Close()
+}
+
+// Anonymize zeroes the host part of a client address: all but the first 24 bits of an IPv4 address and
+// all but the first 48 bits of an IPv6 address
+func Anonymize(ip net.IP) net.IP {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(24, 32))
+	}
+
+	return ip.Mask(net.CIDRMask(48, 128))
+}
@@ -0,0 +1,63 @@
+package querylog
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger_Log(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := New(&buffer, false)
+
+	entry := Entry{
+		Time:      time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Client:    net.ParseIP("192.0.2.10"),
+		Transport: "udp",
+		Name:      "example.com",
+		Type:      "A",
+		Rcode:     "NOERROR",
+		Answers:   2,
+		LatencyMs: 12.5,
+		Cache:     CacheMiss,
+		Upstreams: []string{"9.9.9.9:53"},
+	}
+	assert.NoError(t, logger.Log(entry))
+
+	entry.Cache, entry.Upstreams = "", nil
+	assert.NoError(t, logger.Log(entry))
+
+	assert.Equal(t, `{"time":"2024-05-01T12:00:00Z","client":"192.0.2.10","transport":"udp","name":"example.com","type":"A","rcode":"NOERROR","answers":2,"latency_ms":12.5,"cache":"miss","upstreams":["9.9.9.9:53"]}
+{"time":"2024-05-01T12:00:00Z","client":"192.0.2.10","transport":"udp","name":"example.com","type":"A","rcode":"NOERROR","answers":2,"latency_ms":12.5}
+`, buffer.String())
+}
+
+func TestLogger_Anonymize(t *testing.T) {
+	var buffer bytes.Buffer
+	logger := New(&buffer, true)
+
+	assert.NoError(t, logger.Log(Entry{Client: net.ParseIP("2001:db8:1234:5678::1")}))
+	assert.Contains(t, buffer.String(), `"client":"2001:db8:1234::"`)
+
+	assert.Equal(t, "192.0.2.0", Anonymize(net.ParseIP("192.0.2.10")).String())
+	assert.Equal(t, "10.1.2.0", Anonymize(net.IPv4(10, 1, 2, 3).To4()).String())
+}
+
+func TestOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "queries.log")
+	assert.NoError(t, os.WriteFile(path, []byte("earlier\n"), 0o640))
+
+	logger, err := Open(path, false)
+	assert.NoError(t, err)
+	assert.NoError(t, logger.Log(Entry{Name: "example.com"}))
+	assert.NoError(t, logger.Close())
+
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Regexp(t, `^earlier\n\{.*"name":"example.com".*\}\n$`, string(content))
+}
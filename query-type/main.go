@@ -1,5 +1,11 @@
 package querytype
 
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
 type QueryType uint16
 
 const (
@@ -7,6 +13,57 @@ const (
 	A       QueryType = 1
 	NS      QueryType = 2
 	CNAME   QueryType = 5
+	SOA     QueryType = 6
+	PTR     QueryType = 12
 	MX      QueryType = 15
+	TXT     QueryType = 16
 	AAAA    QueryType = 28
+	TSIG    QueryType = 250
+	IXFR    QueryType = 251
+	AXFR    QueryType = 252
+	ANY     QueryType = 255
 )
+
+var names = map[QueryType]string{
+	A:     "A",
+	NS:    "NS",
+	CNAME: "CNAME",
+	SOA:   "SOA",
+	PTR:   "PTR",
+	MX:    "MX",
+	TXT:   "TXT",
+	AAAA:  "AAAA",
+	TSIG:  "TSIG",
+	IXFR:  "IXFR",
+	AXFR:  "AXFR",
+	ANY:   "ANY",
+}
+
+// String returns the mnemonic of the type, or the generic TYPEnnn form of RFC 3597 for types without one
+func (qt QueryType) String() string {
+	if name, ok := names[qt]; ok {
+		return name
+	}
+
+	return fmt.Sprintf("TYPE%d", uint16(qt))
+}
+
+// Parse reads a type mnemonic or its TYPEnnn form
+func Parse(name string) (QueryType, bool) {
+	for qt, mnemonic := range names {
+		if mnemonic == name {
+			return qt, true
+		}
+	}
+
+	if number, err := strconv.ParseUint(strings.TrimPrefix(name, "TYPE"), 10, 16); err == nil && strings.HasPrefix(name, "TYPE") {
+		return QueryType(number), true
+	}
+
+	return UNKNOWN, false
+}
+
+// IsMeta reports whether the type only appears in queries and can never be stored in a zone
+func (qt QueryType) IsMeta() bool {
+	return qt == TSIG || qt == IXFR || qt == AXFR || qt == ANY || qt == UNKNOWN
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"dns-client-go/config"
+	querylog "dns-client-go/query-log"
+)
+
+// openQueryLog opens the query log of a configuration, nil when it has none. The query log of the
+// previous server is taken over when its settings did not change.
+func openQueryLog(settings config.QueryLog, previous *server) (*querylog.Logger, error) {
+	if previous != nil && previous.config.Logging.QueryLog == settings {
+		return previous.queryLog, nil
+	}
+	if settings.File == "" {
+		return nil, nil
+	}
+
+	return querylog.Open(settings.File, settings.AnonymizeClientIP)
+}
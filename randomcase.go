@@ -0,0 +1,59 @@
+package main
+
+import (
+	"crypto/rand"
+	"dns-client-go/dns"
+)
+
+// randomizeCase returns name with every letter in upper or lower case at random. Servers echo the name of
+// the question as it was sent, so a spoofed response has to guess one more bit per letter (DNS 0x20).
+func randomizeCase(name dns.Name) dns.Name {
+	bits := make([]byte, len(name)/8+1)
+	rand.Read(bits)
+
+	randomized := []byte(name)
+	for i, c := range randomized {
+		if 'a' <= c|0x20 && c|0x20 <= 'z' {
+			if bits[i/8]&(1<<(i%8)) != 0 {
+				randomized[i] = c | 0x20
+			} else {
+				randomized[i] = c &^ 0x20
+			}
+		}
+	}
+
+	return dns.Name(randomized)
+}
+
+// echoesCase reports whether the question of a response is name exactly as it was sent
+func echoesCase(response *dns.DnsPacket, name dns.Name) bool {
+	return len(response.Question) == 1 && response.Question[0].Name == name
+}
+
+// restoreCase gives the question and the records owned by the name sent in random case the name as it
+// was asked for, so clients do not see the randomized case
+func restoreCase(response *dns.DnsPacket, sent dns.Name, qname dns.Name) *dns.DnsPacket {
+	if response == nil || sent == qname {
+		return response
+	}
+
+	restore := func(records []dns.DnsRecord) []dns.DnsRecord {
+		for i := range records {
+			if records[i].Domain().Equal(qname) {
+				records[i] = records[i].WithDomain(qname)
+			}
+		}
+		return records
+	}
+
+	for i := range response.Question {
+		if response.Question[i].Name.Equal(qname) {
+			response.Question[i].Name = qname
+		}
+	}
+	response.Answers = restore(response.Answers)
+	response.Authorities = restore(response.Authorities)
+	response.Resources = restore(response.Resources)
+
+	return response
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"dns-client-go/config"
+	"dns-client-go/dns"
+	infracache "dns-client-go/infra-cache"
+	querytype "dns-client-go/query-type"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRandomizeCase(t *testing.T) {
+	name := dns.Name("www.example.com")
+	seen := map[dns.Name]bool{}
+	for i := 0; i < 20; i++ {
+		randomized := randomizeCase(name)
+		assert.True(t, strings.EqualFold(name.String(), randomized.String()), randomized)
+		seen[randomized] = true
+	}
+	assert.Greater(t, len(seen), 1, "the case is random")
+
+	// Only letters change, escapes are kept as they are
+	escaped := dns.Name(`a\.b\065\\c-1.example`)
+	randomized := randomizeCase(escaped)
+	assert.True(t, strings.EqualFold(escaped.String(), randomized.String()), randomized)
+	for i := 0; i < len(escaped); i++ {
+		if c := escaped[i] | 0x20; c < 'a' || c > 'z' {
+			assert.Equal(t, escaped[i], randomized[i], "byte %d of %v", i, randomized)
+		}
+	}
+}
+
+func TestEchoesCase(t *testing.T) {
+	response := dns.NewPacket()
+	assert.False(t, echoesCase(response, "wWw.ExAmPle.com"), "no question")
+
+	response.Question = []dns.DnsQuestion{{Name: "wWw.ExAmPle.com", Qtype: querytype.A}}
+	assert.True(t, echoesCase(response, "wWw.ExAmPle.com"))
+	assert.False(t, echoesCase(response, "www.example.com"))
+}
+
+func TestRestoreCase(t *testing.T) {
+	response := dns.NewPacket()
+	response.Question = []dns.DnsQuestion{{Name: "www.example.com", Qtype: querytype.A}}
+	response.Answers = []dns.DnsRecord{
+		dns.NewCNAMERecord("www.example.com", "Web.example.com", 300),
+		dns.NewARecord("Web.example.com", "192.0.2.10", 300),
+	}
+	response.Authorities = []dns.DnsRecord{dns.NewNSRecord("example.com", "ns.example.com", 300)}
+
+	restored := restoreCase(response, "wWw.ExAmPle.com", "WWW.example.com")
+	assert.Equal(t, dns.Name("WWW.example.com"), restored.Question[0].Name)
+	assert.Equal(t, []string{
+		"WWW.example.com. 300 IN CNAME Web.example.com.",
+		"Web.example.com. 300 IN A 192.0.2.10",
+	}, recordStrings(restored.Answers))
+	assert.Equal(t, []string{"example.com. 300 IN NS ns.example.com."}, recordStrings(restored.Authorities))
+
+	assert.Nil(t, restoreCase(nil, "wWw.ExAmPle.com", "www.example.com"))
+}
+
+// lowercase answers an A query with the question and the answer owned by the name in lower case, like
+// servers that do not preserve case
+func lowercase(request *dns.DnsPacket) *dns.DnsPacket {
+	name := request.Question[0].Name.Canonical()
+	response := dns.NewPacket()
+	response.Header.AuthoritativeAnswer = true
+	response.Question = []dns.DnsQuestion{{Name: name, Qtype: request.Question[0].Qtype}}
+	response.Answers = []dns.DnsRecord{dns.NewARecord(name, "192.0.2.10", 300)}
+	return response
+}
+
+func TestServer_Lookup_CaseMismatch(t *testing.T) {
+	tcpQueries := fakeNameServer(t, lowercase)
+
+	cfg := config.Default()
+	cfg.Resolver.Timeout = time.Second
+	s := &server{config: cfg, infra: infracache.New()}
+	ns := net.ParseIP("127.0.0.1")
+
+	// Enough letters that the random case is practically never all lower case
+	qname := dns.Name("WWW.Example.COM.case-randomization.test")
+	response, err := s.lookup(qname, querytype.A, ns, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), tcpQueries.Load(), "the response is rejected and the query repeated over TCP")
+	assert.False(t, s.infra.PreservesCase(ns))
+	assert.Equal(t, qname, response.Question[0].Name)
+	assert.Equal(t, []string{qname.String() + ". 300 IN A 192.0.2.10"}, recordStrings(response.Answers))
+
+	// The server is now sent names as they are and its responses are accepted over UDP
+	_, err = s.lookup(qname, querytype.A, ns, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), tcpQueries.Load())
+}
@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+// clientSweepInterval is how often the buckets of clients that went quiet are removed
+const clientSweepInterval = time.Minute
+
+// Clients limits the queries of every client address with a token bucket, holding burst tokens and
+// refilled at rate per second. It is safe for concurrent use.
+type Clients struct {
+	rate    float64
+	burst   float64
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	swept   time.Time
+}
+
+// NewClients returns a limiter of rate queries per second per client, burst defaults to rate
+func NewClients(rate, burst int) *Clients {
+	if burst == 0 {
+		burst = rate
+	}
+
+	return &Clients{rate: float64(rate), burst: float64(burst), buckets: map[string]*bucket{}, swept: now()}
+}
+
+// Allow accounts for a query from ip and reports whether it is within the client's rate
+func (c *Clients) Allow(ip net.IP) bool {
+	client := ip.String()
+	current := now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sweep(current)
+	b, ok := c.buckets[client]
+	if !ok {
+		b = &bucket{balance: c.burst, updated: current}
+		c.buckets[client] = b
+	}
+
+	b.balance += current.Sub(b.updated).Seconds() * c.rate
+	b.updated = current
+	if b.balance > c.burst {
+		b.balance = c.burst
+	}
+
+	if b.balance >= 1 {
+		b.balance--
+		b.limited = 0
+		return true
+	}
+
+	b.limited++
+	if b.limited == 1 {
+		slog.Info("limiting client queries", "client", client)
+	}
+
+	return false
+}
+
+// sweep removes the buckets that were refilled since they were last used
+func (c *Clients) sweep(current time.Time) {
+	if current.Sub(c.swept) < clientSweepInterval {
+		return
+	}
+
+	for client, b := range c.buckets {
+		if b.balance+current.Sub(b.updated).Seconds()*c.rate >= c.burst {
+			delete(c.buckets, client)
+		}
+	}
+	c.swept = current
+}
@@ -0,0 +1,198 @@
+package ratelimit
+
+import (
+	resultcode "dns-client-go/result-code"
+	"log/slog"
+	"net"
+	"sync"
+	"time"
+)
+
+var now = time.Now
+
+// Class is the kind of response a bucket counts. Answers, NXDOMAIN and errors are limited separately,
+// so that a flood of one does not suppress the others.
+type Class int
+
+const (
+	Answer Class = iota
+	NXDomain
+	Error
+)
+
+func (c Class) String() string {
+	switch c {
+	case Answer:
+		return "answer"
+	case NXDomain:
+		return "nxdomain"
+	}
+
+	return "error"
+}
+
+// ClassOf returns the class of a response by its response code
+func ClassOf(rcode resultcode.ResultCode) Class {
+	switch rcode {
+	case resultcode.NOERROR:
+		return Answer
+	case resultcode.NXDOMAIN:
+		return NXDomain
+	}
+
+	return Error
+}
+
+// Action is what to do with a response
+type Action int
+
+const (
+	Allow Action = iota
+	Drop
+	Slip // send the response truncated, so a legitimate client retries over TCP
+)
+
+// Config sets the rates, in responses per second, and how clients are grouped into networks
+type Config struct {
+	ResponsesPerSecond int
+	NXDomainsPerSecond int           // defaults to ResponsesPerSecond
+	ErrorsPerSecond    int           // defaults to ResponsesPerSecond
+	Window             time.Duration // how long a client over the limit stays limited after it stops
+	Slip               int           // every slip-th limited response is truncated instead of dropped, 0 drops all
+	IPv4PrefixLength   int
+	IPv6PrefixLength   int
+	LogOnly            bool // only log the responses that would be limited
+}
+
+// Stats counts the limited responses, in log-only mode the ones that would have been
+type Stats struct {
+	Dropped uint64
+	Slipped uint64
+}
+
+type key struct {
+	network string
+	class   Class
+}
+
+// bucket holds the tokens of a client network and response class. The balance goes negative while
+// the network is over its rate, down to the rate times the window.
+type bucket struct {
+	balance float64
+	updated time.Time
+	limited int // responses limited in a row, for the slip ratio
+}
+
+// Limiter limits the responses sent to client networks, in the style of the response rate limiting
+// of BIND. It is safe for concurrent use.
+type Limiter struct {
+	config  Config
+	mu      sync.Mutex
+	buckets map[key]*bucket
+	swept   time.Time
+	stats   Stats
+}
+
+func New(config Config) *Limiter {
+	if config.NXDomainsPerSecond == 0 {
+		config.NXDomainsPerSecond = config.ResponsesPerSecond
+	}
+	if config.ErrorsPerSecond == 0 {
+		config.ErrorsPerSecond = config.ResponsesPerSecond
+	}
+
+	return &Limiter{config: config, buckets: map[key]*bucket{}, swept: now()}
+}
+
+// Check accounts for a response of class to ip and returns what to do with it
+func (l *Limiter) Check(ip net.IP, class Class) Action {
+	rate := float64(l.rate(class))
+	k := key{network: l.network(ip), class: class}
+	current := now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(current)
+	b, ok := l.buckets[k]
+	if !ok {
+		b = &bucket{balance: rate, updated: current}
+		l.buckets[k] = b
+	}
+
+	b.balance += current.Sub(b.updated).Seconds() * rate
+	b.updated = current
+	if b.balance > rate {
+		b.balance = rate
+	}
+	b.balance--
+	if debt := -rate * l.config.Window.Seconds(); b.balance < debt {
+		b.balance = debt
+	}
+
+	if b.balance >= 0 {
+		b.limited = 0
+		return Allow
+	}
+
+	b.limited++
+	if b.limited == 1 {
+		slog.Info("rate limiting responses", "network", k.network, "class", class.String(), "log_only", l.config.LogOnly)
+	}
+
+	action := Drop
+	if l.config.Slip > 0 && b.limited%l.config.Slip == 0 {
+		action = Slip
+		l.stats.Slipped++
+	} else {
+		l.stats.Dropped++
+	}
+	if l.config.LogOnly {
+		return Allow
+	}
+
+	return action
+}
+
+// Stats returns the number of limited responses
+func (l *Limiter) Stats() Stats {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.stats
+}
+
+func (l *Limiter) rate(class Class) int {
+	switch class {
+	case NXDomain:
+		return l.config.NXDomainsPerSecond
+	case Error:
+		return l.config.ErrorsPerSecond
+	}
+
+	return l.config.ResponsesPerSecond
+}
+
+// network returns the network of ip with the configured prefix length
+func (l *Limiter) network(ip net.IP) string {
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(l.config.IPv4PrefixLength, 32)).String()
+	}
+
+	return ip.Mask(net.CIDRMask(l.config.IPv6PrefixLength, 128)).String()
+}
+
+// sweep removes the buckets that were refilled since they were last used, at most once per window
+func (l *Limiter) sweep(current time.Time) {
+	if current.Sub(l.swept) < l.config.Window {
+		return
+	}
+
+	for k, b := range l.buckets {
+		rate := float64(l.rate(k.class))
+		if b.balance+current.Sub(b.updated).Seconds()*rate >= rate {
+			delete(l.buckets, k)
+		}
+	}
+	l.swept = current
+}
@@ -0,0 +1,78 @@
+package ratelimit
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	resultcode "dns-client-go/result-code"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setClock(t *testing.T, current *time.Time) {
+	now = func() time.Time { return *current }
+	t.Cleanup(func() { now = time.Now })
+}
+
+func checks(l *Limiter, ip string, class Class, n int) []Action {
+	var actions []Action
+	for i := 0; i < n; i++ {
+		actions = append(actions, l.Check(net.ParseIP(ip), class))
+	}
+	return actions
+}
+
+func TestLimiter_Check(t *testing.T) {
+	current := time.Unix(1700000000, 0)
+	setClock(t, &current)
+
+	l := New(Config{ResponsesPerSecond: 3, NXDomainsPerSecond: 1, Window: 5 * time.Second, Slip: 2, IPv4PrefixLength: 24, IPv6PrefixLength: 56})
+
+	assert.Equal(t, []Action{Allow, Allow, Allow, Drop, Slip, Drop, Slip}, checks(l, "192.0.2.1", Answer, 7))
+	assert.Equal(t, []Action{Drop}, checks(l, "192.0.2.200", Answer, 1), "clients of the same network share a bucket")
+	assert.Equal(t, []Action{Allow, Drop}, checks(l, "192.0.2.1", NXDomain, 2), "classes have their own buckets")
+	assert.Equal(t, []Action{Allow}, checks(l, "198.51.100.1", Answer, 1))
+	assert.Equal(t, Stats{Dropped: 4, Slipped: 2}, l.Stats())
+
+	// The debt of a client that stayed over the limit is paid back before it gets answers again
+	current = current.Add(time.Second)
+	assert.Equal(t, []Action{Slip}, checks(l, "192.0.2.1", Answer, 1))
+	current = current.Add(5 * time.Second)
+	assert.Equal(t, []Action{Allow, Allow, Allow, Drop}, checks(l, "192.0.2.1", Answer, 4))
+}
+
+func TestLimiter_LogOnly(t *testing.T) {
+	l := New(Config{ResponsesPerSecond: 1, Window: time.Second, Slip: 0, IPv4PrefixLength: 24, IPv6PrefixLength: 56, LogOnly: true})
+
+	assert.Equal(t, []Action{Allow, Allow, Allow}, checks(l, "2001:db8::1", Error, 3))
+	assert.Equal(t, Stats{Dropped: 2}, l.Stats())
+}
+
+func TestClassOf(t *testing.T) {
+	assert.Equal(t, Answer, ClassOf(resultcode.NOERROR))
+	assert.Equal(t, NXDomain, ClassOf(resultcode.NXDOMAIN))
+	assert.Equal(t, Error, ClassOf(resultcode.REFUSED))
+}
+
+func TestClients_Allow(t *testing.T) {
+	current := time.Unix(1700000000, 0)
+	setClock(t, &current)
+
+	c := NewClients(2, 3)
+	allowed := func(ip string, n int) []bool {
+		var results []bool
+		for i := 0; i < n; i++ {
+			results = append(results, c.Allow(net.ParseIP(ip)))
+		}
+		return results
+	}
+
+	assert.Equal(t, []bool{true, true, true, false, false}, allowed("192.0.2.1", 5))
+	assert.Equal(t, []bool{true}, allowed("192.0.2.2", 1), "every address has its own bucket")
+
+	current = current.Add(time.Second)
+	assert.Equal(t, []bool{true, true, false}, allowed("192.0.2.1", 3))
+	current = current.Add(time.Hour)
+	assert.Equal(t, []bool{true, true, true, false}, allowed("192.0.2.1", 4), "the burst is the most a client saves up")
+}
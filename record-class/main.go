@@ -0,0 +1,9 @@
+package recordclass
+
+type RecordClass uint16
+
+const (
+	IN   RecordClass = 1
+	NONE RecordClass = 254
+	ANY  RecordClass = 255
+)
@@ -1,5 +1,7 @@
 package resultcode
 
+import "strconv"
+
 type ResultCode uint
 
 const (
@@ -9,4 +11,42 @@ const (
 	NXDOMAIN
 	NOTIMP
 	REFUSED
+	YXDOMAIN
+	YXRRSET
+	NXRRSET
+	NOTAUTH
+	NOTZONE
+)
+
+// Extended codes that do not fit the 4 bit header field, they are only carried in the error field of a TSIG record
+const (
+	BADSIG  ResultCode = 16
+	BADKEY  ResultCode = 17
+	BADTIME ResultCode = 18
 )
+
+var names = map[ResultCode]string{
+	NOERROR:  "NOERROR",
+	FORMERR:  "FORMERR",
+	SERVFAIL: "SERVFAIL",
+	NXDOMAIN: "NXDOMAIN",
+	NOTIMP:   "NOTIMP",
+	REFUSED:  "REFUSED",
+	YXDOMAIN: "YXDOMAIN",
+	YXRRSET:  "YXRRSET",
+	NXRRSET:  "NXRRSET",
+	NOTAUTH:  "NOTAUTH",
+	NOTZONE:  "NOTZONE",
+	BADSIG:   "BADSIG",
+	BADKEY:   "BADKEY",
+	BADTIME:  "BADTIME",
+}
+
+// String returns the mnemonic of the code, or RCODEnnn for codes without one
+func (rc ResultCode) String() string {
+	if name, ok := names[rc]; ok {
+		return name
+	}
+
+	return "RCODE" + strconv.Itoa(int(rc))
+}
@@ -0,0 +1,323 @@
+package secondary
+
+import (
+	"context"
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+	resultcode "dns-client-go/result-code"
+	"dns-client-go/transport"
+	"dns-client-go/tsig"
+	"dns-client-go/zone"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// defaultRetry is used while no SOA is known, e.g. before the first transfer succeeded
+const defaultRetry = time.Minute
+
+type Config struct {
+	Zone      dns.Name
+	Primaries []string  // host:port of the servers the zone is transferred from
+	Key       *tsig.Key // signs the queries and transfers, and is required on NOTIFY messages when set
+}
+
+// Secondary keeps a copy of a zone in sync with its primaries. It follows the SOA timers: the
+// serial is checked every refresh interval, failed checks are repeated after retry and once
+// expire passes without a successful check the zone is withdrawn from the store.
+type Secondary struct {
+	config  Config
+	store   *zone.Store
+	notify  chan struct{}
+	timeout time.Duration
+}
+
+func New(config Config, store *zone.Store) *Secondary {
+	config.Zone = config.Zone.Canonical()
+	return &Secondary{
+		config:  config,
+		store:   store,
+		notify:  make(chan struct{}, 1),
+		timeout: transport.DefaultTimeout,
+	}
+}
+
+func (s *Secondary) Zone() dns.Name {
+	return s.config.Zone
+}
+
+// FromPrimary reports whether ip is one of the configured primaries, the only senders a NOTIFY is accepted from
+func (s *Secondary) FromPrimary(ip net.IP) bool {
+	for _, primary := range s.config.Primaries {
+		host, _, err := net.SplitHostPort(primary)
+		if err == nil && net.ParseIP(host).Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AcceptsNotify reports whether a NOTIFY from ip, signed with key or unsigned when key is nil, may trigger a
+// refresh. When the zone has a key a valid signature is required, otherwise the sender must be a primary.
+func (s *Secondary) AcceptsNotify(ip net.IP, key *tsig.Key) bool {
+	if s.config.Key != nil {
+		return key != nil && key.Name == s.config.Key.Name
+	}
+
+	return s.FromPrimary(ip)
+}
+
+// Notify schedules an immediate refresh, e.g. after the primary sent a NOTIFY for the zone
+func (s *Secondary) Notify() {
+	select {
+	case s.notify <- struct{}{}:
+	default: // a refresh is already pending
+	}
+}
+
+// Run refreshes the zone until the context is cancelled. A copy of the zone already in the store, e.g.
+// kept over a configuration reload, counts as refreshed when Run starts, so it lasts until expire.
+func (s *Secondary) Run(ctx context.Context) {
+	var lastSuccess time.Time
+	var wait time.Duration
+	if s.store.Get(s.config.Zone) != nil {
+		lastSuccess = time.Now()
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		case <-s.notify:
+		}
+
+		err := s.Refresh()
+		current := s.store.Get(s.config.Zone)
+		if err == nil {
+			lastSuccess = time.Now()
+			wait = seconds(current.SOA().Refresh())
+			continue
+		}
+
+		slog.Warn("zone refresh failed", "zone", s.config.Zone, "error", err)
+		if current == nil {
+			wait = defaultRetry
+			continue
+		}
+
+		wait = seconds(current.SOA().Retry())
+		if time.Since(lastSuccess) > seconds(current.SOA().Expire()) {
+			slog.Error("zone expired, no longer answering authoritatively", "zone", s.config.Zone)
+			s.store.Remove(s.config.Zone)
+		}
+	}
+}
+
+// Refresh checks the primaries in order and transfers the zone from the first one that answers
+func (s *Secondary) Refresh() error {
+	if len(s.config.Primaries) == 0 {
+		return errors.New("no primaries configured")
+	}
+
+	current := s.store.Get(s.config.Zone)
+	var lastErr error
+	for _, primary := range s.config.Primaries {
+		lastErr = s.refreshFrom(primary, current)
+		if lastErr == nil {
+			return nil
+		}
+	}
+
+	return lastErr
+}
+
+func (s *Secondary) refreshFrom(primary string, current *zone.Zone) error {
+	if current == nil {
+		return s.transfer(primary)
+	}
+
+	serial, err := s.primarySerial(primary)
+	if err != nil {
+		return err
+	}
+
+	if !zone.SerialNewer(serial, current.Serial()) {
+		return nil
+	}
+
+	updated, err := s.incrementalTransfer(primary, current)
+	if err != nil {
+		slog.Warn("IXFR failed, falling back to AXFR", "zone", s.config.Zone, "primary", primary, "error", err)
+		return s.transfer(primary)
+	}
+
+	s.store.Replace(updated)
+	return nil
+}
+
+// primarySerial asks the primary for the current SOA, repeating the query over TCP when the UDP answer was truncated
+func (s *Secondary) primarySerial(primary string) (uint32, error) {
+	query := transport.NewQuery(s.config.Zone, querytype.SOA)
+	response, err := transport.Exchange(query, primary, s.timeout, s.config.Key)
+	if err == nil && response.Header.TruncatedMessage {
+		response, err = transport.ExchangeTCP(query, primary, s.timeout, s.config.Key)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if response.Header.Rescode != resultcode.NOERROR {
+		return 0, fmt.Errorf("SOA query answered with rcode %v", response.Header.Rescode)
+	}
+
+	soa := response.GetSOA()
+	if soa == nil || len(response.Answers) == 0 {
+		return 0, errors.New("SOA query returned no SOA record")
+	}
+
+	return soa.Serial(), nil
+}
+
+// transfer replaces the zone with a full copy fetched by AXFR
+func (s *Secondary) transfer(primary string) error {
+	query := transport.NewQuery(s.config.Zone, querytype.AXFR)
+	records, err := s.collect(query, primary, func(records []dns.DnsRecord) bool {
+		return len(records) > 1 && records[len(records)-1].SOA != nil
+	})
+	if err != nil {
+		return err
+	}
+
+	updated, err := s.build(records)
+	if err != nil {
+		return err
+	}
+
+	s.store.Replace(updated)
+	return nil
+}
+
+// incrementalTransfer fetches the changes since the current serial by IXFR and applies them to a copy of the zone.
+// A primary without the history may answer with the whole zone instead, which is handled like an AXFR.
+func (s *Secondary) incrementalTransfer(primary string, current *zone.Zone) (*zone.Zone, error) {
+	query := transport.NewQuery(s.config.Zone, querytype.IXFR)
+	query.Authorities = current.RRset(s.config.Zone, querytype.SOA)
+
+	records, err := s.collect(query, primary, func(records []dns.DnsRecord) bool {
+		if len(records) == 1 {
+			return !zone.SerialNewer(records[0].SOA.Serial(), current.Serial()) // a single SOA tells us we are up to date
+		}
+
+		last := records[len(records)-1]
+		if last.SOA == nil || last.SOA.Serial() != records[0].SOA.Serial() {
+			return false
+		}
+
+		if records[1].SOA == nil {
+			return true // AXFR style response
+		}
+
+		count := 0
+		for _, record := range records {
+			if record.SOA != nil && record.SOA.Serial() == last.SOA.Serial() {
+				count++
+			}
+		}
+		return count >= 3
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(records) == 1 {
+		return current, nil // the primary has nothing newer than our serial
+	}
+
+	if records[1].SOA == nil {
+		return s.build(records)
+	}
+
+	return s.apply(current, records)
+}
+
+// collect reads the transfer stream until done reports that the records form a complete response
+func (s *Secondary) collect(query *dns.DnsPacket, primary string, done func([]dns.DnsRecord) bool) ([]dns.DnsRecord, error) {
+	var records []dns.DnsRecord
+	err := transport.Transfer(query, primary, s.timeout, s.config.Key, func(response *dns.DnsPacket) (bool, error) {
+		if response.Header.Rescode != resultcode.NOERROR {
+			return true, fmt.Errorf("transfer refused with rcode %v", response.Header.Rescode)
+		}
+
+		if len(records) == 0 && (len(response.Answers) == 0 || response.Answers[0].SOA == nil) {
+			return true, errors.New("transfer does not start with an SOA record")
+		}
+
+		records = append(records, response.Answers...)
+		return done(records), nil
+	})
+
+	return records, err
+}
+
+// build creates a zone from the records of an AXFR, which are framed by the SOA record
+func (s *Secondary) build(records []dns.DnsRecord) (*zone.Zone, error) {
+	updated := zone.New(s.config.Zone)
+	for _, record := range records[:len(records)-1] {
+		if !updated.Contains(record.Domain()) {
+			continue // never accept data outside the zone
+		}
+		updated.Add(record)
+	}
+
+	if updated.SOA() == nil {
+		return nil, errors.New("transferred zone has no SOA record")
+	}
+
+	return updated, nil
+}
+
+// apply replays the difference sequences of an incremental transfer on a copy of the zone. Each sequence
+// starts with the old SOA followed by the deleted records, then the new SOA followed by the added records.
+func (s *Secondary) apply(current *zone.Zone, records []dns.DnsRecord) (*zone.Zone, error) {
+	if records[1].SOA.Serial() != current.Serial() {
+		return nil, fmt.Errorf("IXFR starts at serial %v, have %v", records[1].SOA.Serial(), current.Serial())
+	}
+
+	updated := current.Clone()
+	deleting := false
+	for _, record := range records[1 : len(records)-1] {
+		if record.SOA != nil {
+			deleting = !deleting
+		}
+
+		if !updated.Contains(record.Domain()) {
+			continue
+		}
+
+		if deleting {
+			updated.Remove(record)
+			continue
+		}
+
+		if record.SOA != nil {
+			for _, soa := range updated.RRset(s.config.Zone, querytype.SOA) {
+				updated.Remove(soa)
+			}
+		}
+		updated.Add(record)
+	}
+
+	if updated.Serial() != records[0].SOA.Serial() {
+		return nil, errors.New("IXFR did not end at the announced serial")
+	}
+
+	return updated, nil
+}
+
+func seconds(value uint32) time.Duration {
+	return time.Duration(value) * time.Second
+}
@@ -0,0 +1,140 @@
+package secondary
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+	"dns-client-go/transport"
+	"dns-client-go/zone"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func soa(serial uint32) dns.DnsRecord {
+	return dns.NewSOARecord("example.com", "ns1.example.com", "admin.example.com", serial, 3600, 600, 86400, 300, 3600)
+}
+
+// fakePrimary answers every transfer request with the given records, one record per message
+func fakePrimary(t *testing.T, records []dns.DnsRecord) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			buffer, err := transport.ReadMessage(conn)
+			if err != nil {
+				conn.Close()
+				continue
+			}
+			request := dns.NewPacket().FromBuffer(buffer)
+
+			for _, record := range records {
+				response := dns.NewPacket()
+				response.Header.ID = request.Header.ID
+				response.Header.Response = true
+				response.Answers = []dns.DnsRecord{record}
+				data, _ := transport.Serialize(response)
+				transport.WriteMessage(conn, data)
+			}
+			conn.Close()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestSecondary_Transfer(t *testing.T) {
+	primary := fakePrimary(t, []dns.DnsRecord{
+		soa(1),
+		dns.NewNSRecord("example.com", "ns1.example.com", 3600),
+		dns.NewARecord("www.example.com", "192.0.2.10", 300),
+		dns.NewARecord("www.attacker.net", "192.0.2.66", 300),
+		soa(1),
+	})
+
+	store := zone.NewStore()
+	s := New(Config{Zone: "example.com", Primaries: []string{primary}}, store)
+
+	assert.NoError(t, s.transfer(primary))
+
+	transferred := store.Get("example.com")
+	assert.NotNil(t, transferred)
+	assert.Equal(t, uint32(1), transferred.Serial())
+	assert.Len(t, transferred.RRset("www.example.com", querytype.A), 1)
+	assert.Len(t, transferred.Records(), 3, "records outside the zone are dropped")
+}
+
+func TestSecondary_IncrementalTransfer(t *testing.T) {
+	current := zone.New("example.com")
+	current.Add(soa(1))
+	current.Add(dns.NewARecord("www.example.com", "192.0.2.10", 300))
+
+	primary := fakePrimary(t, []dns.DnsRecord{
+		soa(3),
+		soa(1),
+		dns.NewARecord("www.example.com", "192.0.2.10", 300),
+		soa(2),
+		dns.NewARecord("www.example.com", "192.0.2.11", 300),
+		soa(2),
+		soa(3),
+		dns.NewARecord("mail.example.com", "192.0.2.25", 300),
+		soa(3),
+	})
+
+	s := New(Config{Zone: "example.com", Primaries: []string{primary}}, zone.NewStore())
+
+	updated, err := s.incrementalTransfer(primary, current)
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(3), updated.Serial())
+	assert.Equal(t, []dns.DnsRecord{dns.NewARecord("www.example.com", "192.0.2.11", 300)}, updated.RRset("www.example.com", querytype.A))
+	assert.Len(t, updated.RRset("mail.example.com", querytype.A), 1)
+	assert.Equal(t, uint32(1), current.Serial(), "the current zone is left untouched")
+}
+
+func TestSecondary_IncrementalTransferUpToDate(t *testing.T) {
+	current := zone.New("example.com")
+	current.Add(soa(5))
+
+	primary := fakePrimary(t, []dns.DnsRecord{soa(5)})
+	s := New(Config{Zone: "example.com", Primaries: []string{primary}}, zone.NewStore())
+
+	updated, err := s.incrementalTransfer(primary, current)
+	assert.NoError(t, err)
+	assert.Same(t, current, updated)
+}
+
+func TestSecondary_RunAfterReloadWithPrimaryDown(t *testing.T) {
+	// Nothing listens on the primary's port any more
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	primary := listener.Addr().String()
+	listener.Close()
+
+	// The store, and the zone in it, are kept when a reload replaces the secondary
+	store := zone.NewStore()
+	current := zone.New("example.com")
+	current.Add(soa(1))
+	store.Replace(current)
+
+	s := New(Config{Zone: "example.com", Primaries: []string{primary}}, store)
+	s.timeout = 100 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+	s.Run(ctx)
+
+	assert.Same(t, current, store.Get("example.com"), "the zone is kept until expire passes")
+}
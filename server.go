@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"dns-client-go/cache"
+	"dns-client-go/config"
+	"dns-client-go/dns"
+	"dns-client-go/filter"
+	infracache "dns-client-go/infra-cache"
+	"dns-client-go/primary"
+	ratelimit "dns-client-go/rate-limit"
+	"dns-client-go/secondary"
+	singleflight "dns-client-go/single-flight"
+	"dns-client-go/static"
+	"dns-client-go/tsig"
+	"dns-client-go/update"
+	"dns-client-go/zone"
+	"fmt"
+	"net"
+	"reflect"
+	"sync/atomic"
+)
+
+// newServer builds the server from a validated configuration, loading the keys, zones, lists and
+// static records it refers to. When it replaces a previous server on reload, it takes over its cache,
+// its zone store, its filtering pause and the zones whose settings did not change, which are only reread.
+func newServer(cfg *config.Config, previous *server) (*server, error) {
+	s := &server{
+		config:         cfg,
+		zones:          zone.NewStore(),
+		primaries:      map[dns.Name]*primary.Primary{},
+		secondaries:    map[dns.Name]*secondary.Secondary{},
+		keys:           tsig.KeyStore{},
+		cache:          cache.New(cfg.Cache.Size, cfg.Cache.MinTTL, cfg.Cache.MaxTTL, cfg.Cache.NegativeTTL),
+		refuse:         parseNetworks(cfg.ACL.Refuse),
+		allowQuery:     parseNetworks(cfg.ACL.AllowQuery),
+		allowRecursion: parseNetworks(cfg.ACL.AllowRecursion),
+		pause:          &filter.Pause{},
+		infra:          infracache.New(),
+		inflight:       &atomic.Int64{},
+		flights:        &singleflight.Group[flightKey, flight]{Wait: resolutionQueries * cfg.Resolver.Timeout},
+		zoneRuns:       map[dns.Name]context.CancelFunc{},
+	}
+	if previous != nil {
+		s.zones = previous.zones
+		s.cache = previous.cache
+		s.infra = previous.infra
+		s.inflight = previous.inflight
+		s.pause = previous.pause
+	}
+
+	for _, hint := range cfg.Resolver.RootHints {
+		s.rootHints = append(s.rootHints, net.ParseIP(hint))
+	}
+
+	if cfg.TSIGKeys != "" {
+		keys, err := tsig.LoadKeys(cfg.TSIGKeys)
+		if err != nil {
+			return nil, err
+		}
+		s.keys = keys
+	}
+
+	records, err := static.New(cfg.Static.Records, cfg.Static.Hosts, cfg.Static.HostsTTL)
+	if err != nil {
+		return nil, err
+	}
+	s.static = records
+
+	var upstreams []string
+	if cfg.Resolver.Mode == config.ModeForward {
+		upstreams = cfg.Resolver.Upstreams
+	}
+	group, err := newGroup(config.Group{Name: "default", Upstreams: upstreams, Policy: cfg.Filtering.Policy})
+	if err != nil {
+		return nil, err
+	}
+	s.groups = &filter.Groups{Default: group}
+
+	for _, groupConfig := range cfg.Filtering.Groups {
+		group, err := newGroup(groupConfig)
+		if err != nil {
+			return nil, err
+		}
+		s.groups.Groups = append(s.groups.Groups, group)
+	}
+
+	for _, zoneConfig := range cfg.Zones.Primary {
+		primaryConfig := primary.Config{
+			Zone:        dns.Name(zoneConfig.Name),
+			File:        zoneConfig.File,
+			Secondaries: withDefaultPorts(zoneConfig.AlsoNotify),
+			Transfers:   parseNetworks(cfg.ACL.AllowTransfer),
+			UpdatePolicy: update.Policy{
+				Clients: parseNetworks(zoneConfig.AllowUpdate),
+				Keys:    zoneConfig.UpdateKeys,
+			},
+		}
+		for _, keyName := range zoneConfig.UpdateKeys {
+			if s.keys.Get(keyName) == nil {
+				return nil, fmt.Errorf("zone %v accepts updates signed with unknown TSIG key %v", zoneConfig.Name, keyName)
+			}
+		}
+		if primaryConfig.Key, err = s.zoneKey(zoneConfig.Name, zoneConfig.Key); err != nil {
+			return nil, err
+		}
+
+		name := dns.Name(zoneConfig.Name).Canonical()
+		if p, ok := previous.unchangedPrimary(s, zoneConfig); ok {
+			if err := p.Load(); err != nil {
+				return nil, err
+			}
+			s.primaries[name] = p
+			s.zoneRuns[name] = previous.zoneRuns[name]
+			continue
+		}
+
+		p := primary.New(primaryConfig, s.zones)
+		if err := p.Load(); err != nil {
+			return nil, err
+		}
+		s.primaries[p.Zone()] = p
+	}
+
+	for _, zoneConfig := range cfg.Zones.Secondary {
+		secondaryConfig := secondary.Config{Zone: dns.Name(zoneConfig.Name), Primaries: withDefaultPorts(zoneConfig.Primaries)}
+		if secondaryConfig.Key, err = s.zoneKey(zoneConfig.Name, zoneConfig.Key); err != nil {
+			return nil, err
+		}
+
+		name := dns.Name(zoneConfig.Name).Canonical()
+		if sec, ok := previous.unchangedSecondary(s, zoneConfig); ok {
+			sec.Notify()
+			s.secondaries[name] = sec
+			s.zoneRuns[name] = previous.zoneRuns[name]
+			continue
+		}
+
+		sec := secondary.New(secondaryConfig, s.zones)
+		s.secondaries[sec.Zone()] = sec
+	}
+
+	if s.queryLog, err = openQueryLog(cfg.Logging.QueryLog, previous); err != nil {
+		return nil, err
+	}
+	if s.dnstap, err = openDnstap(cfg.Dnstap, previous); err != nil {
+		return nil, err
+	}
+	s.rateLimiter = newRateLimiter(cfg.RateLimit, previous)
+	s.clientLimiter = newClientLimiter(cfg.Limits, previous)
+	s.handler = s.chain()
+
+	return s, nil
+}
+
+// zoneKey looks up the TSIG key configured for a zone, nil when the zone has none
+func (s *server) zoneKey(zoneName string, keyName string) (*tsig.Key, error) {
+	if keyName == "" {
+		return nil, nil
+	}
+
+	key := s.keys.Get(keyName)
+	if key == nil {
+		return nil, fmt.Errorf("zone %v uses unknown TSIG key %v", zoneName, keyName)
+	}
+
+	return key, nil
+}
+
+// unchangedPrimary returns the primary of the previous server when next configures the zone with the
+// same settings and TSIG keys
+func (s *server) unchangedPrimary(next *server, zoneConfig config.PrimaryZone) (*primary.Primary, bool) {
+	if s == nil {
+		return nil, false
+	}
+
+	for _, previous := range s.config.Zones.Primary {
+		if !dns.Name(previous.Name).Equal(dns.Name(zoneConfig.Name)) {
+			continue
+		}
+		if !reflect.DeepEqual(previous, zoneConfig) || !s.sameKeys(next, append([]string{zoneConfig.Key}, zoneConfig.UpdateKeys...)) ||
+			!reflect.DeepEqual(s.config.ACL.AllowTransfer, next.config.ACL.AllowTransfer) {
+			return nil, false
+		}
+
+		p, ok := s.primaries[dns.Name(zoneConfig.Name).Canonical()]
+		return p, ok
+	}
+
+	return nil, false
+}
+
+// unchangedSecondary returns the secondary of the previous server when next configures the zone with
+// the same settings and TSIG key
+func (s *server) unchangedSecondary(next *server, zoneConfig config.SecondaryZone) (*secondary.Secondary, bool) {
+	if s == nil {
+		return nil, false
+	}
+
+	for _, previous := range s.config.Zones.Secondary {
+		if !dns.Name(previous.Name).Equal(dns.Name(zoneConfig.Name)) {
+			continue
+		}
+		if !reflect.DeepEqual(previous, zoneConfig) || !s.sameKeys(next, []string{zoneConfig.Key}) {
+			return nil, false
+		}
+
+		sec, ok := s.secondaries[dns.Name(zoneConfig.Name).Canonical()]
+		return sec, ok
+	}
+
+	return nil, false
+}
+
+// sameKeys reports whether the keys with the given names are the same in both servers
+func (s *server) sameKeys(next *server, names []string) bool {
+	for _, name := range names {
+		if name != "" && !reflect.DeepEqual(s.keys.Get(name), next.keys.Get(name)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// start runs the reloading of lists and static records until the server is stopped, and the
+// maintenance of the zones it did not take over from a previous server until the context is cancelled
+func (s *server) start(ctx context.Context) {
+	var serverCtx context.Context
+	serverCtx, s.cancel = context.WithCancel(ctx)
+
+	go s.static.Run(serverCtx)
+
+	for _, group := range s.groups.All() {
+		go group.Blocklists.Run(serverCtx)
+		go group.Allowlists.Run(serverCtx)
+	}
+
+	for name, p := range s.primaries {
+		if _, running := s.zoneRuns[name]; running {
+			continue
+		}
+
+		var zoneCtx context.Context
+		zoneCtx, s.zoneRuns[name] = context.WithCancel(ctx)
+		p.NotifySecondaries()
+		go p.Run(zoneCtx)
+	}
+
+	for name, sec := range s.secondaries {
+		if _, running := s.zoneRuns[name]; running {
+			continue
+		}
+
+		var zoneCtx context.Context
+		zoneCtx, s.zoneRuns[name] = context.WithCancel(ctx)
+		go sec.Run(zoneCtx)
+	}
+}
+
+// stop ends the background work of a server replaced by next. Zones next took over keep running,
+// zones it no longer serves are withdrawn.
+func (s *server) stop(next *server) {
+	s.cancel()
+	if s.queryLog != nil && s.queryLog != next.queryLog {
+		s.queryLog.Close()
+	}
+	if s.dnstap != nil && s.dnstap != next.dnstap {
+		s.dnstap.Close()
+	}
+
+	for name, cancel := range s.zoneRuns {
+		if next.primaries[name] != nil && next.primaries[name] == s.primaries[name] {
+			continue
+		}
+		if next.secondaries[name] != nil && next.secondaries[name] == s.secondaries[name] {
+			continue
+		}
+
+		cancel()
+		if next.primaries[name] == nil && next.secondaries[name] == nil {
+			s.zones.Remove(name)
+		}
+	}
+}
+
+// listen opens the UDP and TCP listeners of the configuration
+func (s *server) listen() ([]*net.UDPConn, []*net.TCPListener, error) {
+	var conns []*net.UDPConn
+	var listeners []*net.TCPListener
+	closeAll := func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		for _, listener := range listeners {
+			listener.Close()
+		}
+	}
+
+	for _, address := range s.config.Listen.UDP {
+		addr, err := net.ResolveUDPAddr("udp", address)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		conn, err := net.ListenUDP("udp", addr)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		conns = append(conns, conn)
+	}
+
+	for _, address := range s.config.Listen.TCP {
+		addr, err := net.ResolveTCPAddr("tcp", address)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		listener, err := net.ListenTCP("tcp", addr)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+
+	return conns, listeners, nil
+}
+
+// parseNetworks parses the CIDR networks of a validated configuration
+func parseNetworks(cidrs []string) []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil {
+			networks = append(networks, network)
+		}
+	}
+
+	return networks
+}
+
+// newRateLimiter returns the response rate limiter of a configuration, nil when rate limiting is disabled.
+// The limiter of the previous server is taken over with its buckets when its settings did not change.
+func newRateLimiter(settings config.RateLimit, previous *server) *ratelimit.Limiter {
+	if previous != nil && previous.config.RateLimit == settings {
+		return previous.rateLimiter
+	}
+	if settings.ResponsesPerSecond == 0 {
+		return nil
+	}
+
+	return ratelimit.New(ratelimit.Config{
+		ResponsesPerSecond: settings.ResponsesPerSecond,
+		NXDomainsPerSecond: settings.NXDomainsPerSecond,
+		ErrorsPerSecond:    settings.ErrorsPerSecond,
+		Window:             settings.Window,
+		Slip:               settings.Slip,
+		IPv4PrefixLength:   settings.IPv4PrefixLength,
+		IPv6PrefixLength:   settings.IPv6PrefixLength,
+		LogOnly:            settings.LogOnly,
+	})
+}
+
+// newClientLimiter returns the limiter of client queries of a configuration, nil when they are not limited.
+// The limiter of the previous server is taken over with its buckets when its rates did not change.
+func newClientLimiter(settings config.Limits, previous *server) *ratelimit.Clients {
+	if previous != nil && previous.config.Limits.ClientQueriesPerSecond == settings.ClientQueriesPerSecond &&
+		previous.config.Limits.ClientBurst == settings.ClientBurst {
+		return previous.clientLimiter
+	}
+	if settings.ClientQueriesPerSecond == 0 {
+		return nil
+	}
+
+	return ratelimit.NewClients(settings.ClientQueriesPerSecond, settings.ClientBurst)
+}
@@ -0,0 +1,114 @@
+package singleflight
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrAbandoned is returned to the callers that joined a call whose function panicked
+	ErrAbandoned = errors.New("the shared call did not complete")
+	// ErrCycle is returned by DoWithin when the call would wait for itself
+	ErrCycle = errors.New("the shared call waits for itself")
+)
+
+type call[V any] struct {
+	done     chan struct{}
+	value    V
+	err      error
+	waitsFor *call[V] // the call the function of this one waits for
+}
+
+// Group coalesces the concurrent calls with the same key, so that the function runs once and every
+// caller gets its result. The zero value is ready to use.
+type Group[K comparable, V any] struct {
+	// Wait is how long a caller waits for the call it joined before running the function itself, which
+	// keeps calls that depend on each other in ways DoWithin cannot see from waiting forever. 0 waits
+	// until the call completes.
+	Wait time.Duration
+
+	mu    sync.Mutex
+	calls map[K]*call[V]
+}
+
+// Do runs fn for key unless a call for key is in progress, in which case it waits for its result.
+// shared reports whether the result came from another caller's call.
+func (g *Group[K, V]) Do(key K, fn func() (V, error)) (value V, err error, shared bool) {
+	return g.do(key, fn, nil)
+}
+
+// DoWithin is Do for a call made by the function of the call for parent. Joining a call that waits for
+// parent, directly or through other calls, would wait until Wait passes: ErrCycle is returned at once.
+func (g *Group[K, V]) DoWithin(parent K, key K, fn func() (V, error)) (value V, err error, shared bool) {
+	return g.do(key, fn, &parent)
+}
+
+func (g *Group[K, V]) do(key K, fn func() (V, error), parent *K) (value V, err error, shared bool) {
+	g.mu.Lock()
+	if c, ok := g.calls[key]; ok {
+		var waiter *call[V]
+		if parent != nil {
+			waiter = g.calls[*parent]
+		}
+		if waiter != nil {
+			for next := c; next != nil; next = next.waitsFor {
+				if next == waiter {
+					g.mu.Unlock()
+					return value, ErrCycle, false
+				}
+			}
+			waiter.waitsFor = c
+		}
+		g.mu.Unlock()
+
+		joined := g.join(c)
+		if waiter != nil {
+			g.mu.Lock()
+			waiter.waitsFor = nil
+			g.mu.Unlock()
+		}
+		if joined {
+			return c.value, c.err, true
+		}
+		value, err = fn()
+		return value, err, false
+	}
+
+	c := &call[V]{done: make(chan struct{})}
+	if g.calls == nil {
+		g.calls = map[K]*call[V]{}
+	}
+	g.calls[key] = c
+	g.mu.Unlock()
+
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(c.done)
+	}()
+
+	c.err = ErrAbandoned
+	c.value, c.err = fn()
+
+	return c.value, c.err, false
+}
+
+// join waits for a call to complete and reports whether it did within the wait
+func (g *Group[K, V]) join(c *call[V]) bool {
+	if g.Wait == 0 {
+		<-c.done
+		return true
+	}
+
+	timer := time.NewTimer(g.Wait)
+	defer timer.Stop()
+
+	select {
+	case <-c.done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
@@ -0,0 +1,136 @@
+package singleflight
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGroup_Do(t *testing.T) {
+	var g Group[string, int]
+	var calls atomic.Int32
+	release := make(chan struct{})
+
+	var wg sync.WaitGroup
+	results := make([]int, 10)
+	shared := make([]bool, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _, shared[i] = g.Do("example.com", func() (int, error) {
+				calls.Add(1)
+				<-release
+				return 42, nil
+			})
+		}(i)
+	}
+
+	// Give every caller the time to join the first call
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), calls.Load())
+	for i := range results {
+		assert.Equal(t, 42, results[i])
+	}
+	assert.Contains(t, shared, true)
+	assert.Contains(t, shared, false)
+
+	value, err, wasShared := g.Do("example.com", func() (int, error) { return 0, errors.New("failed") })
+	assert.Equal(t, 0, value)
+	assert.EqualError(t, err, "failed")
+	assert.False(t, wasShared, "completed calls are not shared")
+}
+
+func TestGroup_Wait(t *testing.T) {
+	g := Group[string, int]{Wait: 10 * time.Millisecond}
+	release := make(chan struct{})
+	defer close(release)
+
+	go g.Do("example.com", func() (int, error) {
+		<-release
+		return 1, nil
+	})
+	for {
+		g.mu.Lock()
+		started := len(g.calls) == 1
+		g.mu.Unlock()
+		if started {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	value, _, shared := g.Do("example.com", func() (int, error) { return 2, nil })
+	assert.Equal(t, 2, value, "a caller that waited too long runs the function itself")
+	assert.False(t, shared)
+}
+
+func TestGroup_Panic(t *testing.T) {
+	var g Group[string, int]
+	started := make(chan struct{})
+	release := make(chan struct{})
+
+	go func() {
+		defer func() { recover() }()
+		g.Do("example.com", func() (int, error) {
+			close(started)
+			<-release
+			panic("lookup failed")
+		})
+	}()
+	<-started
+
+	errs := make(chan error)
+	go func() {
+		_, err, _ := g.Do("example.com", func() (int, error) { return 0, nil })
+		errs <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+
+	assert.ErrorIs(t, <-errs, ErrAbandoned)
+}
+
+func TestGroup_DoWithinCycle(t *testing.T) {
+	g := Group[string, int]{Wait: time.Hour}
+	var started sync.WaitGroup
+	started.Add(2)
+
+	// The calls for a.example and b.example each need the other's result, and do without it
+	type result struct {
+		value  int
+		err    error
+		shared bool
+	}
+	results := make(chan result, 2)
+	for _, keys := range [][2]string{{"a.example", "b.example"}, {"b.example", "a.example"}} {
+		go func(key string, dependency string) {
+			g.Do(key, func() (int, error) {
+				started.Done()
+				started.Wait()
+				value, err, shared := g.DoWithin(key, dependency, func() (int, error) { return 2, nil })
+				results <- result{value, err, shared}
+				return 1, nil
+			})
+		}(keys[0], keys[1])
+	}
+
+	var received []result
+	for len(received) < 2 {
+		select {
+		case r := <-results:
+			received = append(received, r)
+		case <-time.After(5 * time.Second):
+			t.Fatal("the calls wait for each other")
+		}
+	}
+	assert.ErrorIs(t, received[0].err, ErrCycle, "the call that closes the cycle fails at once")
+	assert.Equal(t, result{value: 1, shared: true}, received[1], "the other joins it")
+}
@@ -0,0 +1,80 @@
+package static
+
+import (
+	"bufio"
+	"dns-client-go/dns"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+)
+
+// ParseHosts reads a file in /etc/hosts format. Every name gets an A or AAAA record for the address and
+// the address gets a PTR record for the first name of its first line. Unicode names are converted to
+// A-labels, which queries ask for.
+func ParseHosts(reader io.Reader, ttl uint32) ([]dns.DnsRecord, error) {
+	var records []dns.DnsRecord
+	reverse := map[dns.Name]bool{}
+
+	scanner := bufio.NewScanner(reader)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if comment := strings.IndexByte(line, '#'); comment >= 0 {
+			line = line[:comment]
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) == 1 {
+			return nil, fmt.Errorf("line %d: address %v without a name", lineNumber, fields[0])
+		}
+
+		ip := net.ParseIP(fields[0])
+		if ip == nil {
+			return nil, fmt.Errorf("line %d: invalid address %q", lineNumber, fields[0])
+		}
+
+		names := make([]dns.Name, len(fields)-1)
+		for i, name := range fields[1:] {
+			ascii, err := dns.ToASCII(strings.TrimSuffix(name, "."))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+			}
+			names[i] = dns.Name(ascii)
+		}
+
+		for _, name := range names {
+			if ip.To4() != nil {
+				records = append(records, dns.NewARecord(name, ip.To4().String(), ttl))
+			} else {
+				records = append(records, dns.NewAAAARecord(name, ip.String(), ttl))
+			}
+		}
+
+		if ptr := ReverseName(ip); !reverse[ptr] {
+			reverse[ptr] = true
+			records = append(records, dns.NewPTRRecord(ptr, names[0].String(), ttl))
+		}
+	}
+
+	return records, scanner.Err()
+}
+
+// ReverseName returns the name under in-addr.arpa or ip6.arpa that PTR queries for ip ask for
+func ReverseName(ip net.IP) dns.Name {
+	if ip4 := ip.To4(); ip4 != nil {
+		return dns.Name(fmt.Sprintf("%d.%d.%d.%d.in-addr.arpa", ip4[3], ip4[2], ip4[1], ip4[0]))
+	}
+
+	ip16 := ip.To16()
+	nibbles := make([]string, 0, 32)
+	for i := len(ip16) - 1; i >= 0; i-- {
+		nibbles = append(nibbles, fmt.Sprintf("%x", ip16[i]&0xf), fmt.Sprintf("%x", ip16[i]>>4))
+	}
+
+	return dns.Name(strings.Join(nibbles, ".") + ".ip6.arpa")
+}
@@ -0,0 +1,173 @@
+package static
+
+import (
+	"context"
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+	"dns-client-go/zone"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// DefaultTTL is used for hosts file entries
+	DefaultTTL = 300
+	// pollInterval is how often the hosts file is checked for changes
+	pollInterval = 5 * time.Second
+	// maxCNAMEChain bounds how many static CNAMEs are followed for a single answer
+	maxCNAMEChain = 8
+)
+
+// supportedTypes are the types that can be configured as static records
+var supportedTypes = map[querytype.QueryType]bool{
+	querytype.A:     true,
+	querytype.AAAA:  true,
+	querytype.CNAME: true,
+	querytype.TXT:   true,
+	querytype.PTR:   true,
+}
+
+// Records answers names pinned in the configuration or in a hosts file. Configured records take
+// precedence: a name that is configured is not looked up in the hosts file.
+type Records struct {
+	configured map[dns.Name][]dns.DnsRecord
+	hostsFile  string
+	ttl        uint32
+	records    atomic.Pointer[map[dns.Name][]dns.DnsRecord]
+	mu         sync.Mutex // serializes reloads
+	modified   time.Time
+}
+
+// New builds the static records from configured records in presentation format, e.g.
+// "db.staging 300 A 10.0.0.5", and an optional hosts file whose entries get the given TTL.
+// Configured records without a TTL get the master file default of an hour.
+func New(entries []string, hostsFile string, ttl uint32) (*Records, error) {
+	r := &Records{configured: map[dns.Name][]dns.DnsRecord{}, hostsFile: hostsFile, ttl: ttl}
+
+	for _, entry := range entries {
+		record, err := zone.ParseRecord(entry)
+		if err != nil {
+			return nil, err
+		}
+		if !supportedTypes[record.Type()] {
+			return nil, fmt.Errorf("static record %q has unsupported type %v", entry, record.Type())
+		}
+
+		owner := record.Domain().Canonical()
+		r.configured[owner] = append(r.configured[owner], *record)
+	}
+
+	r.records.Store(&r.configured)
+	return r, r.Load()
+}
+
+// Load reads the hosts file and installs its records next to the configured ones
+func (r *Records) Load() error {
+	if r.hostsFile == "" {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	file, err := os.Open(r.hostsFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	hosts, err := ParseHosts(file, r.ttl)
+	if err != nil {
+		return fmt.Errorf("%s: %w", r.hostsFile, err)
+	}
+
+	records := map[dns.Name][]dns.DnsRecord{}
+	for _, record := range hosts {
+		owner := record.Domain().Canonical()
+		if _, ok := r.configured[owner]; !ok {
+			records[owner] = append(records[owner], record)
+		}
+	}
+	for owner, configured := range r.configured {
+		records[owner] = configured
+	}
+
+	r.modified = info.ModTime()
+	r.records.Store(&records)
+	return nil
+}
+
+// Run reloads the hosts file whenever its modification time changes, until the context is cancelled
+func (r *Records) Run(ctx context.Context) {
+	if r.hostsFile == "" {
+		return
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		r.mu.Lock()
+		modified := r.modified
+		r.mu.Unlock()
+
+		info, err := os.Stat(r.hostsFile)
+		if err != nil || info.ModTime().Equal(modified) {
+			continue
+		}
+
+		if err := r.Load(); err != nil {
+			slog.Error("failed to reload hosts file", "error", err)
+		}
+	}
+}
+
+// Lookup returns the static answer for qname, following CNAMEs between static names. It reports false
+// when qname has no static records. An empty answer means the name exists without records of the type.
+func (r *Records) Lookup(qname dns.Name, qtype querytype.QueryType) ([]dns.DnsRecord, bool) {
+	records := *r.records.Load()
+	if _, ok := records[qname.Canonical()]; !ok {
+		return nil, false
+	}
+
+	var answers []dns.DnsRecord
+	for i := 0; i < maxCNAMEChain; i++ {
+		var rrset []dns.DnsRecord
+		var cname *dns.DnsRecord
+		for _, record := range records[qname.Canonical()] {
+			if record.Type() == qtype {
+				rrset = append(rrset, record)
+			} else if record.CNAME != nil {
+				found := record
+				cname = &found
+			}
+		}
+
+		if len(rrset) > 0 {
+			return append(answers, rrset...), true
+		}
+		if cname == nil {
+			return answers, true // a CNAME leaving the static names is resolved by the caller
+		}
+
+		answers = append(answers, *cname)
+		qname = dns.Name(cname.CNAME.Host())
+	}
+
+	return answers, true
+}
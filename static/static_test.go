@@ -0,0 +1,122 @@
+package static
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseHosts(t *testing.T) {
+	content := `# comment
+10.0.0.5   db.staging db   # alias
+10.0.0.5   other.staging
+2001:db8::5 db.staging
+`
+	records, err := ParseHosts(strings.NewReader(content), 120)
+	assert.NoError(t, err)
+
+	var lines []string
+	for _, record := range records {
+		lines = append(lines, record.String())
+	}
+	assert.Equal(t, []string{
+		"db.staging. 120 IN A 10.0.0.5",
+		"db. 120 IN A 10.0.0.5",
+		"5.0.0.10.in-addr.arpa. 120 IN PTR db.staging.",
+		"other.staging. 120 IN A 10.0.0.5",
+		"db.staging. 120 IN AAAA 2001:db8::5",
+		"5.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa. 120 IN PTR db.staging.",
+	}, lines)
+
+	_, err = ParseHosts(strings.NewReader("10.0.0.300 broken\n"), 120)
+	assert.Error(t, err)
+	_, err = ParseHosts(strings.NewReader("10.0.0.5 -bücher.staging\n"), 120)
+	assert.Error(t, err)
+}
+
+func TestReverseName(t *testing.T) {
+	assert.Equal(t, dns.Name("1.2.0.192.in-addr.arpa"), ReverseName(net.ParseIP("192.0.2.1")))
+	assert.Equal(t, dns.Name("1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa"), ReverseName(net.ParseIP("2001:db8::1")))
+}
+
+func TestRecords_Lookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	assert.NoError(t, os.WriteFile(path, []byte("10.0.0.5 db.staging\n10.0.0.6 cache.staging\n"), 0o644))
+
+	records, err := New([]string{
+		"db.staging 60 A 10.0.0.50",
+		"app.staging CNAME db.staging",
+		"web.staging CNAME www.example.com",
+		"app.staging 60 TXT \"owner=platform\"",
+	}, path, DefaultTTL)
+	assert.NoError(t, err)
+
+	// Configured records take precedence over the hosts file
+	answers, ok := records.Lookup("DB.staging.", querytype.A)
+	assert.True(t, ok)
+	assert.Len(t, answers, 1)
+	assert.Equal(t, "db.staging. 60 IN A 10.0.0.50", answers[0].String())
+
+	answers, ok = records.Lookup("app.staging", querytype.A)
+	assert.True(t, ok)
+	assert.Len(t, answers, 2)
+	assert.Equal(t, querytype.CNAME, answers[0].Type())
+	assert.Equal(t, "10.0.0.50", strings.Fields(answers[1].String())[4])
+
+	answers, ok = records.Lookup("web.staging", querytype.A)
+	assert.True(t, ok)
+	assert.Len(t, answers, 1, "the CNAME target is resolved by the caller")
+
+	answers, ok = records.Lookup("cache.staging", querytype.AAAA)
+	assert.True(t, ok)
+	assert.Empty(t, answers)
+
+	answers, ok = records.Lookup("6.0.0.10.in-addr.arpa", querytype.PTR)
+	assert.True(t, ok)
+	assert.Equal(t, "cache.staging", answers[0].PTR.Host())
+
+	_, ok = records.Lookup("unknown.staging", querytype.A)
+	assert.False(t, ok)
+
+	// The hosts file is reread
+	assert.NoError(t, os.WriteFile(path, []byte("10.0.0.7 queue.staging\n"), 0o644))
+	assert.NoError(t, records.Load())
+	_, ok = records.Lookup("cache.staging", querytype.A)
+	assert.False(t, ok)
+	_, ok = records.Lookup("queue.staging", querytype.A)
+	assert.True(t, ok)
+
+	_, err = New([]string{"mail.staging MX 10 mx.staging"}, "", DefaultTTL)
+	assert.Error(t, err)
+}
+
+func TestRecords_Lookup_Unicode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts")
+	assert.NoError(t, os.WriteFile(path, []byte("10.0.0.6 münchen.staging\n"), 0o644))
+
+	records, err := New([]string{"bücher.staging A 10.0.0.5"}, path, DefaultTTL)
+	assert.NoError(t, err)
+
+	// Queries carry the A-labels
+	answers, ok := records.Lookup("xn--bcher-kva.staging", querytype.A)
+	assert.True(t, ok)
+	assert.Equal(t, "xn--bcher-kva.staging. 3600 IN A 10.0.0.5", answers[0].String())
+
+	answers, ok = records.Lookup("XN--MNCHEN-3YA.staging", querytype.A)
+	assert.True(t, ok)
+	assert.Len(t, answers, 1)
+
+	answers, ok = records.Lookup("6.0.0.10.in-addr.arpa", querytype.PTR)
+	assert.True(t, ok)
+	assert.Equal(t, "xn--mnchen-3ya.staging", answers[0].PTR.Host())
+
+	_, err = New([]string{"-bü.staging A 10.0.0.5"}, "", DefaultTTL)
+	assert.Error(t, err)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"context"
+	"dns-client-go/dns"
+	"dns-client-go/handler"
+	packetbuffer "dns-client-go/packetbuffer"
+	"dns-client-go/primary"
+	querytype "dns-client-go/query-type"
+	resultcode "dns-client-go/result-code"
+	"dns-client-go/transport"
+	"dns-client-go/tsig"
+	"dns-client-go/zone"
+	"log/slog"
+	"net"
+	"time"
+)
+
+// transferMessageSize keeps zone transfer messages well below the TCP maximum
+const transferMessageSize = 16 * 1024
+
+// handleTCPMessage answers a request read from a TCP connection. It reports whether the connection is
+// done, which is the case after a zone transfer and after a request with a bad signature.
+func (s *server) handleTCPMessage(conn *net.TCPConn, requestBuffer *packetbuffer.PacketBuffer) (bool, error) {
+	x := &exchange{
+		s:        s,
+		message:  requestBuffer.Buffer,
+		received: time.Now(),
+		local:    conn.LocalAddr(),
+		remote:   conn.RemoteAddr(),
+		conn:     conn,
+		send: func(data []byte) error {
+			return transport.WriteMessage(conn, data)
+		},
+	}
+	err := s.serve(x)
+
+	return x.closing || err != nil, err
+}
+
+// transfer streams zone transfers requested over TCP, they do not fit the single response of the chain
+func (s *server) transfer(next handler.Handler) handler.Handler {
+	return handler.HandlerFunc(func(ctx context.Context, w handler.ResponseWriter, request *dns.DnsPacket) {
+		x := exchangeFrom(ctx)
+		if x.conn == nil || len(request.Question) == 0 || !isTransfer(request.Question[0].Qtype) {
+			next.ServeDNS(ctx, w, request)
+			return
+		}
+
+		x.closing = true
+		x.err = s.serveTransfer(x.conn, request, handler.ClientIP(w), x.signature, x.key)
+	})
+}
+
+func isTransfer(qtype querytype.QueryType) bool {
+	return qtype == querytype.AXFR || qtype == querytype.IXFR
+}
+
+// serveTransfer streams a zone to a secondary. IXFR requests are answered from the update history and
+// fall back to the full zone, which RFC 1995 permits when the history does not reach the client's serial.
+// Transfers requested with a TSIG key are signed message by message.
+func (s *server) serveTransfer(conn net.Conn, request *dns.DnsPacket, src net.IP, signature *tsig.Signature, key *tsig.Key) error {
+	question := request.Question[0]
+	p, ok := s.primaries[question.Name.Canonical()]
+	current := s.zones.Get(question.Name)
+
+	newResponse := func() *dns.DnsPacket {
+		response := dns.NewPacket()
+		response.Header.ID = request.Header.ID
+		response.Header.Response = true
+		response.Header.AuthoritativeAnswer = true
+		response.Question = append(response.Question, question)
+		return response
+	}
+
+	var stream *tsig.Stream
+	if key != nil {
+		stream = tsig.NewStream(key, signature.MAC)
+	}
+
+	send := func(response *dns.DnsPacket) error {
+		data, err := transport.Serialize(response)
+		if err != nil {
+			return err
+		}
+		if stream != nil {
+			data = stream.Sign(data)
+		}
+		return transport.WriteMessage(conn, data)
+	}
+
+	switch {
+	case !ok || current == nil:
+		response := newResponse()
+		response.Header.Rescode = resultcode.NOTAUTH
+		return send(response)
+	case !p.AllowTransfer(src, key):
+		slog.Warn("refusing zone transfer", "zone", question.Name, "client", src)
+		response := newResponse()
+		response.Header.Rescode = resultcode.REFUSED
+		return send(response)
+	}
+
+	records, ok := incrementalRecords(p, request, current)
+	if !ok {
+		records = append(current.Records(), current.RRset(current.Origin, querytype.SOA)...)
+	}
+
+	response := newResponse()
+	size := 0
+	for _, record := range records {
+		recordSize := recordLength(record)
+		if size+recordSize > transferMessageSize && len(response.Answers) > 0 {
+			if err := send(response); err != nil {
+				return err
+			}
+			response = newResponse()
+			size = 0
+		}
+
+		response.Answers = append(response.Answers, record)
+		size += recordSize
+	}
+
+	return send(response)
+}
+
+// incrementalRecords builds the answer of an IXFR (RFC 1995): the current SOA followed by one sequence of
+// deleted and added records per change since the client's serial, closed by the current SOA again
+func incrementalRecords(p *primary.Primary, request *dns.DnsPacket, current *zone.Zone) ([]dns.DnsRecord, bool) {
+	clientSOA := request.GetSOA()
+	if request.Question[0].Qtype != querytype.IXFR || clientSOA == nil {
+		return nil, false
+	}
+
+	soa := current.RRset(current.Origin, querytype.SOA)
+	if !zone.SerialNewer(current.Serial(), clientSOA.Serial()) {
+		return soa, true // the client is up to date
+	}
+
+	diffs, ok := p.Diffs(clientSOA.Serial(), current.Serial())
+	if !ok {
+		return nil, false
+	}
+
+	records := append([]dns.DnsRecord(nil), soa...)
+	for _, diff := range diffs {
+		records = append(records, diff.From)
+		records = append(records, diff.Deleted...)
+		records = append(records, diff.To)
+		records = append(records, diff.Added...)
+	}
+
+	return append(records, soa...), true
+}
+
+func recordLength(record dns.DnsRecord) int {
+	buffer := packetbuffer.NewPacketBufferWithSize(packetbuffer.MaxMessageSize)
+	length, _ := record.Write(&buffer)
+	return int(length)
+}
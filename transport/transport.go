@@ -0,0 +1,192 @@
+package transport
+
+import (
+	"crypto/rand"
+	"dns-client-go/dns"
+	packetbuffer "dns-client-go/packetbuffer"
+	querytype "dns-client-go/query-type"
+	"dns-client-go/tsig"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+)
+
+const DefaultTimeout = 5 * time.Second
+
+var errIDMismatch = errors.New("response ID does not match the query")
+
+// NewQuery builds a single question query with a random transaction ID. Unicode labels of qname are sent
+// as A-labels, a name that is not a valid internationalized name is sent as given.
+func NewQuery(qname dns.Name, qtype querytype.QueryType) *dns.DnsPacket {
+	if ascii, err := dns.ToASCII(qname.String()); err == nil {
+		qname = dns.Name(ascii)
+	}
+
+	packet := dns.NewPacket()
+	packet.Header.ID = RandomID()
+	packet.Question = append(packet.Question, *dns.NewQuestion(qname, qtype))
+
+	return packet
+}
+
+// RandomID returns an unpredictable transaction ID, which makes spoofed responses harder to forge
+func RandomID() uint16 {
+	var id [2]byte
+	rand.Read(id[:])
+	return binary.BigEndian.Uint16(id[:])
+}
+
+// Serialize writes the packet into a buffer large enough for any DNS message
+// and returns the used part of it.
+func Serialize(packet *dns.DnsPacket) ([]byte, error) {
+	buffer := packetbuffer.NewPacketBufferWithSize(packetbuffer.MaxMessageSize)
+	packet.Write(&buffer)
+
+	return buffer.GetRange(0, buffer.Pos())
+}
+
+// Exchange sends the query to server (host:port) over UDP and waits for the response
+// carrying the same transaction ID. With a key the query is signed and the response must be
+// signed by the server as well.
+func Exchange(query *dns.DnsPacket, server string, timeout time.Duration, key *tsig.Key) (*dns.DnsPacket, error) {
+	conn, err := net.DialTimeout("udp", server, timeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	data, err := Serialize(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var stream *tsig.Stream
+	if key != nil {
+		var mac []byte
+		data, mac = key.Sign(data, nil)
+		stream = tsig.NewStream(key, mac)
+	}
+
+	if _, err := conn.Write(data); err != nil {
+		return nil, err
+	}
+
+	received := make([]byte, packetbuffer.MaxMessageSize)
+	n, err := conn.Read(received)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := packetbuffer.NewPacketBufferFrom(received[:n])
+	response := dns.NewPacket().FromBuffer(&buffer)
+	if response.Header.ID != query.Header.ID {
+		return nil, errIDMismatch
+	}
+
+	if stream != nil {
+		if err := stream.Verify(received[:n]); err != nil {
+			return nil, fmt.Errorf("response from %v failed TSIG verification: %w", server, err)
+		}
+	}
+
+	return response, nil
+}
+
+// ExchangeTCP sends the query to server (host:port) over TCP and reads a single response
+func ExchangeTCP(query *dns.DnsPacket, server string, timeout time.Duration, key *tsig.Key) (*dns.DnsPacket, error) {
+	var response *dns.DnsPacket
+	err := Transfer(query, server, timeout, key, func(packet *dns.DnsPacket) (bool, error) {
+		response = packet
+		return true, nil
+	})
+
+	return response, err
+}
+
+// Transfer sends the query over TCP and hands every message of the response stream to handle
+// until it reports that the stream is complete. It is used for AXFR and IXFR, where a single
+// response spans many messages. With a key the query is signed and every message of the stream
+// must carry a valid signature.
+func Transfer(query *dns.DnsPacket, server string, timeout time.Duration, key *tsig.Key, handle func(*dns.DnsPacket) (bool, error)) error {
+	conn, err := net.DialTimeout("tcp", server, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	data, err := Serialize(query)
+	if err != nil {
+		return err
+	}
+
+	var stream *tsig.Stream
+	if key != nil {
+		var mac []byte
+		data, mac = key.Sign(data, nil)
+		stream = tsig.NewStream(key, mac)
+	}
+
+	conn.SetDeadline(time.Now().Add(timeout))
+	if err := WriteMessage(conn, data); err != nil {
+		return err
+	}
+
+	for {
+		// Every message of the stream gets its own deadline so large zones are not cut off
+		conn.SetDeadline(time.Now().Add(timeout))
+		buffer, err := ReadMessage(conn)
+		if err != nil {
+			return err
+		}
+
+		response := dns.NewPacket().FromBuffer(buffer)
+		if response.Header.ID != query.Header.ID {
+			return errIDMismatch
+		}
+
+		if stream != nil {
+			if err := stream.Verify(buffer.Buffer); err != nil {
+				return fmt.Errorf("response from %v failed TSIG verification: %w", server, err)
+			}
+		}
+
+		done, err := handle(response)
+		if err != nil || done {
+			return err
+		}
+	}
+}
+
+// ReadMessage reads one message prefixed with its two byte length from a stream connection
+func ReadMessage(conn io.Reader) (*packetbuffer.PacketBuffer, error) {
+	var length uint16
+	if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+
+	data := make([]byte, length)
+	if _, err := io.ReadFull(conn, data); err != nil {
+		return nil, fmt.Errorf("failed to read message of %d bytes: %w", length, err)
+	}
+
+	buffer := packetbuffer.NewPacketBufferFrom(data)
+	return &buffer, nil
+}
+
+// WriteMessage writes data prefixed with its two byte length to a stream connection
+func WriteMessage(conn io.Writer, data []byte) error {
+	if len(data) > packetbuffer.MaxMessageSize {
+		return fmt.Errorf("message of %d bytes exceeds the maximum size", len(data))
+	}
+
+	message := make([]byte, 2+len(data))
+	binary.BigEndian.PutUint16(message, uint16(len(data)))
+	copy(message[2:], data)
+
+	_, err := conn.Write(message)
+	return err
+}
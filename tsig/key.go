@@ -0,0 +1,94 @@
+package tsig
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"dns-client-go/dns"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"os"
+	"strings"
+)
+
+const (
+	HmacSHA256 = "hmac-sha256"
+	HmacSHA512 = "hmac-sha512"
+)
+
+// Key is a shared secret used to sign messages, identified by its name on both ends
+type Key struct {
+	Name      string
+	Algorithm string
+	Secret    []byte
+}
+
+// KeyStore holds the known keys by their canonical name
+type KeyStore map[dns.Name]*Key
+
+func NewKey(name string, algorithm string, secret string) (*Key, error) {
+	algorithm = strings.ToLower(strings.TrimSuffix(algorithm, "."))
+	if algorithm != HmacSHA256 && algorithm != HmacSHA512 {
+		return nil, fmt.Errorf("unsupported TSIG algorithm %q", algorithm)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return nil, fmt.Errorf("invalid secret for key %v: %w", name, err)
+	}
+
+	return &Key{Name: canonical(name), Algorithm: algorithm, Secret: decoded}, nil
+}
+
+// LoadKeys reads a key file with one "name algorithm base64-secret" entry per line. Empty lines and
+// lines starting with # are skipped.
+func LoadKeys(path string) (KeyStore, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	keys := KeyStore{}
+	lineNumber := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s line %d: expected name, algorithm and secret", path, lineNumber)
+		}
+
+		key, err := NewKey(fields[0], fields[1], fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("%s line %d: %w", path, lineNumber, err)
+		}
+		keys[dns.Name(key.Name)] = key
+	}
+
+	return keys, scanner.Err()
+}
+
+// Get returns the key with the given name, or nil when it is unknown
+func (ks KeyStore) Get(name string) *Key {
+	return ks[dns.Name(name).Canonical()]
+}
+
+func (k *Key) newHash() hash.Hash {
+	if k.Algorithm == HmacSHA512 {
+		return hmac.New(sha512.New, k.Secret)
+	}
+
+	return hmac.New(sha256.New, k.Secret)
+}
+
+func canonical(name string) string {
+	return dns.Name(name).Canonical().String()
+}
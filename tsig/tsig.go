@@ -0,0 +1,345 @@
+package tsig
+
+import (
+	"crypto/hmac"
+	"dns-client-go/dns"
+	packetbuffer "dns-client-go/packetbuffer"
+	querytype "dns-client-go/query-type"
+	recordclass "dns-client-go/record-class"
+	resultcode "dns-client-go/result-code"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultFudge is the allowed difference in seconds between the signer's and the verifier's clock
+const DefaultFudge = 300
+
+// now is replaced in tests
+var now = time.Now
+
+// Error carries the TSIG error code (BADSIG, BADKEY or BADTIME) of a failed verification
+type Error struct {
+	Code resultcode.ResultCode
+}
+
+func (e *Error) Error() string {
+	switch e.Code {
+	case resultcode.BADSIG:
+		return "TSIG signature does not match"
+	case resultcode.BADKEY:
+		return "TSIG key is unknown"
+	case resultcode.BADTIME:
+		return "TSIG time is outside the allowed fudge"
+	}
+
+	return fmt.Sprintf("TSIG error %d", e.Code)
+}
+
+var (
+	ErrBadSig   = &Error{Code: resultcode.BADSIG}
+	ErrBadKey   = &Error{Code: resultcode.BADKEY}
+	ErrBadTime  = &Error{Code: resultcode.BADTIME}
+	ErrUnsigned = errors.New("message is not signed")
+)
+
+// Signature is the content of a TSIG record (RFC 8945 section 4.2)
+type Signature struct {
+	KeyName    string
+	Algorithm  string
+	TimeSigned uint64 // seconds since the epoch, 48 bits on the wire
+	Fudge      uint16
+	MAC        []byte
+	OriginalID uint16
+	Error      resultcode.ResultCode
+	Other      []byte
+}
+
+// Parse looks for a TSIG record at the end of the additional section. It returns the signature and the message
+// as it was before signing, i.e. without the TSIG record and with the original ID. An unsigned message yields nil.
+func Parse(message []byte) (*Signature, []byte, error) {
+	buffer := packetbuffer.NewPacketBufferFrom(message)
+	header := dns.DnsHeader{}
+	header.Read(&buffer)
+	if len(message) < 12 || header.ResourceEntries == 0 {
+		return nil, message, nil
+	}
+
+	for i := 0; i < int(header.Questions); i++ {
+		question := dns.DnsQuestion{}
+		question.Read(&buffer)
+	}
+
+	records := int(header.Answers) + int(header.AuthoritiveEntries) + int(header.ResourceEntries) - 1
+	for i := 0; i < records; i++ {
+		record := dns.DnsRecord{}
+		record.Read(&buffer)
+	}
+
+	start := buffer.Pos()
+	keyName, err := buffer.ReadQname()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rrtype, _ := buffer.Read_u16()
+	class, _ := buffer.Read_u16()
+	if querytype.QueryType(rrtype) != querytype.TSIG {
+		return nil, message, nil
+	}
+	if recordclass.RecordClass(class) != recordclass.ANY {
+		return nil, nil, errors.New("TSIG record must use class ANY")
+	}
+	buffer.Step(4) // TTL
+	buffer.Step(2) // data length
+
+	signature := &Signature{KeyName: canonical(keyName)}
+	algorithm, err := buffer.ReadQname()
+	if err != nil {
+		return nil, nil, err
+	}
+	signature.Algorithm = canonical(algorithm)
+
+	timeHigh, _ := buffer.Read_u16()
+	timeLow, _ := buffer.Read_u32()
+	signature.TimeSigned = uint64(timeHigh)<<32 | uint64(timeLow)
+	signature.Fudge, _ = buffer.Read_u16()
+	macSize, _ := buffer.Read_u16()
+	if signature.MAC, err = readBytes(&buffer, macSize); err != nil {
+		return nil, nil, err
+	}
+	signature.OriginalID, _ = buffer.Read_u16()
+	tsigError, _ := buffer.Read_u16()
+	signature.Error = resultcode.ResultCode(tsigError)
+	otherSize, err := buffer.Read_u16()
+	if err != nil {
+		return nil, nil, err
+	}
+	if signature.Other, err = readBytes(&buffer, otherSize); err != nil {
+		return nil, nil, err
+	}
+
+	unsigned := append([]byte(nil), message[:start]...)
+	binary.BigEndian.PutUint16(unsigned[0:], signature.OriginalID)
+	binary.BigEndian.PutUint16(unsigned[10:], header.ResourceEntries-1)
+
+	return signature, unsigned, nil
+}
+
+// Sign appends a TSIG record to the serialized message. requestMAC is the MAC of the request when signing a
+// response, nil otherwise. It returns the signed message and its MAC, which later messages refer to.
+func (k *Key) Sign(message []byte, requestMAC []byte) ([]byte, []byte) {
+	signature := k.newSignature(message)
+	signature.MAC = k.mac(requestMAC, message, signature, false)
+
+	return appendSignature(message, signature), signature.MAC
+}
+
+// Verify checks the TSIG record of a message signed with this key. requestMAC is the MAC of the request
+// when verifying a response. On success the signature is returned so its MAC can be used in a reply.
+func (k *Key) Verify(message []byte, requestMAC []byte) (*Signature, error) {
+	return k.verify(message, requestMAC, false)
+}
+
+// Verify checks the TSIG record of a request against the key it names
+func (ks KeyStore) Verify(message []byte) (*Signature, *Key, error) {
+	signature, _, err := Parse(message)
+	if err != nil {
+		return nil, nil, err
+	}
+	if signature == nil {
+		return nil, nil, ErrUnsigned
+	}
+
+	key := ks.Get(signature.KeyName)
+	if key == nil || key.Algorithm != signature.Algorithm {
+		return signature, nil, ErrBadKey
+	}
+
+	signature, err = key.Verify(message, nil)
+	return signature, key, err
+}
+
+// ErrorResponse signs a response to a request whose verification failed (RFC 8945 section 5.3.2). For BADTIME the
+// response is signed and carries the server time, for BADSIG and BADKEY the TSIG record holds no MAC.
+func ErrorResponse(message []byte, request *Signature, key *Key, code resultcode.ResultCode) []byte {
+	if code == resultcode.BADTIME && key != nil {
+		signature := key.newSignature(message)
+		signature.Error = code
+		signature.Other = make([]byte, 6)
+		putTime(signature.Other, uint64(now().Unix()))
+		signature.MAC = key.mac(request.MAC, message, signature, false)
+		return appendSignature(message, signature)
+	}
+
+	signature := &Signature{
+		KeyName:    request.KeyName,
+		Algorithm:  request.Algorithm,
+		TimeSigned: uint64(now().Unix()),
+		Fudge:      DefaultFudge,
+		OriginalID: binary.BigEndian.Uint16(message),
+		Error:      code,
+	}
+
+	return appendSignature(message, signature)
+}
+
+// Stream signs or verifies the messages of a multi-message response such as a zone transfer. The first
+// message is handled like a single response, every following one covers the previous MAC and only the timers
+// (RFC 8945 section 5.3.1).
+type Stream struct {
+	key   *Key
+	mac   []byte
+	first bool
+}
+
+func NewStream(key *Key, requestMAC []byte) *Stream {
+	return &Stream{key: key, mac: requestMAC, first: true}
+}
+
+func (s *Stream) Sign(message []byte) []byte {
+	signature := s.key.newSignature(message)
+	signature.MAC = s.key.mac(s.mac, message, signature, !s.first)
+	s.mac, s.first = signature.MAC, false
+
+	return appendSignature(message, signature)
+}
+
+func (s *Stream) Verify(message []byte) error {
+	signature, err := s.key.verify(message, s.mac, !s.first)
+	if err != nil {
+		return err
+	}
+
+	s.mac, s.first = signature.MAC, false
+	return nil
+}
+
+func (k *Key) verify(message []byte, requestMAC []byte, timersOnly bool) (*Signature, error) {
+	signature, unsigned, err := Parse(message)
+	if err != nil {
+		return nil, err
+	}
+	if signature == nil {
+		return nil, ErrUnsigned
+	}
+
+	if signature.KeyName != k.Name || signature.Algorithm != k.Algorithm {
+		return signature, ErrBadKey
+	}
+
+	// A peer that could not verify our message answers with its error and, except for BADTIME, without a MAC
+	if signature.Error != resultcode.NOERROR && signature.Error != resultcode.BADTIME {
+		return signature, &Error{Code: signature.Error}
+	}
+
+	expected := k.mac(requestMAC, unsigned, signature, timersOnly)
+	if !hmac.Equal(expected, signature.MAC) {
+		return signature, ErrBadSig
+	}
+
+	if signature.Error == resultcode.BADTIME {
+		return signature, ErrBadTime
+	}
+
+	current := uint64(now().Unix())
+	if current > signature.TimeSigned+uint64(signature.Fudge) || signature.TimeSigned > current+uint64(signature.Fudge) {
+		return signature, ErrBadTime
+	}
+
+	return signature, nil
+}
+
+func (k *Key) newSignature(message []byte) *Signature {
+	return &Signature{
+		KeyName:    k.Name,
+		Algorithm:  k.Algorithm,
+		TimeSigned: uint64(now().Unix()),
+		Fudge:      DefaultFudge,
+		OriginalID: binary.BigEndian.Uint16(message),
+	}
+}
+
+// mac computes the digest over the prior MAC, the unsigned message and the TSIG variables (RFC 8945 section 4.3)
+func (k *Key) mac(priorMAC []byte, message []byte, signature *Signature, timersOnly bool) []byte {
+	h := k.newHash()
+
+	if priorMAC != nil {
+		binary.Write(h, binary.BigEndian, uint16(len(priorMAC)))
+		h.Write(priorMAC)
+	}
+	h.Write(message)
+
+	timers := make([]byte, 8)
+	putTime(timers, signature.TimeSigned)
+	binary.BigEndian.PutUint16(timers[6:], signature.Fudge)
+
+	if timersOnly {
+		h.Write(timers)
+		return h.Sum(nil)
+	}
+
+	h.Write(wireName(signature.KeyName))
+	binary.Write(h, binary.BigEndian, uint16(recordclass.ANY))
+	binary.Write(h, binary.BigEndian, uint32(0)) // TTL
+	h.Write(wireName(signature.Algorithm))
+	h.Write(timers)
+	binary.Write(h, binary.BigEndian, uint16(signature.Error))
+	binary.Write(h, binary.BigEndian, uint16(len(signature.Other)))
+	h.Write(signature.Other)
+
+	return h.Sum(nil)
+}
+
+// appendSignature adds the TSIG record to the additional section of the message
+func appendSignature(message []byte, signature *Signature) []byte {
+	buffer := packetbuffer.NewPacketBufferWithSize(packetbuffer.MaxMessageSize)
+	buffer.WriteBytes(wireName(signature.KeyName))
+	buffer.Write_uint16(uint16(querytype.TSIG))
+	buffer.Write_uint16(uint16(recordclass.ANY))
+	buffer.Write_uint32(0) // TTL
+
+	lengthPos := buffer.Pos()
+	buffer.Write_uint16(0) // Placeholder for the data length
+	buffer.WriteBytes(wireName(signature.Algorithm))
+	buffer.Write_uint16(uint16(signature.TimeSigned >> 32))
+	buffer.Write_uint32(uint32(signature.TimeSigned))
+	buffer.Write_uint16(signature.Fudge)
+	buffer.Write_uint16(uint16(len(signature.MAC)))
+	buffer.WriteBytes(signature.MAC)
+	buffer.Write_uint16(signature.OriginalID)
+	buffer.Write_uint16(uint16(signature.Error))
+	buffer.Write_uint16(uint16(len(signature.Other)))
+	buffer.WriteBytes(signature.Other)
+	buffer.SetValue_u16(lengthPos, uint16(buffer.Pos()-(lengthPos+2)))
+
+	record, _ := buffer.GetRange(0, buffer.Pos())
+	signed := append(append([]byte(nil), message...), record...)
+	binary.BigEndian.PutUint16(signed[10:], binary.BigEndian.Uint16(signed[10:])+1)
+
+	return signed
+}
+
+// wireName encodes a name in the canonical (lower case, uncompressed) wire format
+func wireName(name string) []byte {
+	buffer := packetbuffer.NewPacketBuffer()
+	buffer.WriteQname(canonical(name))
+	data, _ := buffer.GetRange(0, buffer.Pos())
+	return data
+}
+
+func putTime(data []byte, seconds uint64) {
+	binary.BigEndian.PutUint16(data, uint16(seconds>>32))
+	binary.BigEndian.PutUint32(data[2:], uint32(seconds))
+}
+
+func readBytes(buffer *packetbuffer.PacketBuffer, size uint16) ([]byte, error) {
+	data, err := buffer.GetRange(buffer.Pos(), uint(size))
+	if err != nil {
+		return nil, err
+	}
+	buffer.Step(uint(size))
+
+	return append([]byte(nil), data...), nil
+}
@@ -0,0 +1,163 @@
+package tsig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dns-client-go/dns"
+	packetbuffer "dns-client-go/packetbuffer"
+	querytype "dns-client-go/query-type"
+	resultcode "dns-client-go/result-code"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSecret = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+func serialize(t *testing.T, packet *dns.DnsPacket) []byte {
+	buffer := packetbuffer.NewPacketBufferWithSize(packetbuffer.MaxMessageSize)
+	packet.Write(&buffer)
+	data, err := buffer.GetRange(0, buffer.Pos())
+	assert.NoError(t, err)
+	return data
+}
+
+func newQuery(t *testing.T) []byte {
+	packet := dns.NewPacket()
+	packet.Header.ID = 4242
+	packet.Question = append(packet.Question, *dns.NewQuestion("example.com", querytype.AXFR))
+	return serialize(t, packet)
+}
+
+func newResponse(t *testing.T, address string) []byte {
+	packet := dns.NewPacket()
+	packet.Header.ID = 4242
+	packet.Header.Response = true
+	packet.Question = append(packet.Question, *dns.NewQuestion("example.com", querytype.AXFR))
+	packet.Answers = append(packet.Answers, dns.NewARecord("www.example.com", address, 300))
+	return serialize(t, packet)
+}
+
+func TestKey_SignVerify(t *testing.T) {
+	for _, algorithm := range []string{HmacSHA256, HmacSHA512} {
+		key, err := NewKey("transfer.", algorithm, testSecret)
+		assert.NoError(t, err)
+
+		signed, mac := key.Sign(newQuery(t), nil)
+		signature, err := key.Verify(signed, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, mac, signature.MAC)
+		assert.Equal(t, "transfer", signature.KeyName)
+		assert.Equal(t, uint16(4242), signature.OriginalID)
+
+		// The signed message still parses, with the TSIG record at the end of the additional section
+		buffer := packetbuffer.NewPacketBufferFrom(signed)
+		packet := dns.NewPacket().FromBuffer(&buffer)
+		assert.Len(t, packet.Resources, 1)
+		assert.Equal(t, querytype.TSIG, packet.Resources[0].Type())
+
+		response, _ := key.Sign(newResponse(t, "192.0.2.1"), mac)
+		_, err = key.Verify(response, mac)
+		assert.NoError(t, err)
+
+		// A response is bound to its request
+		_, err = key.Verify(response, nil)
+		assert.Equal(t, ErrBadSig, err)
+	}
+}
+
+func TestKey_VerifyErrors(t *testing.T) {
+	key, _ := NewKey("transfer", HmacSHA256, testSecret)
+	other, _ := NewKey("transfer", HmacSHA256, "b3RoZXIgc2VjcmV0")
+	signed, _ := key.Sign(newQuery(t), nil)
+
+	_, err := other.Verify(signed, nil)
+	assert.Equal(t, ErrBadSig, err)
+
+	tampered := append([]byte(nil), signed...)
+	tampered[3] ^= 0x01 // flip a header flag
+	_, err = key.Verify(tampered, nil)
+	assert.Equal(t, ErrBadSig, err)
+
+	_, err = key.Verify(newQuery(t), nil)
+	assert.Equal(t, ErrUnsigned, err)
+
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Now().Add(10 * time.Minute) }
+	_, err = key.Verify(signed, nil)
+	assert.Equal(t, ErrBadTime, err)
+}
+
+func TestKeyStore_Verify(t *testing.T) {
+	key, _ := NewKey("transfer", HmacSHA256, testSecret)
+	unknown, _ := NewKey("unknown", HmacSHA256, testSecret)
+	keys := KeyStore{dns.Name(key.Name): key}
+
+	signed, _ := key.Sign(newQuery(t), nil)
+	signature, found, err := keys.Verify(signed)
+	assert.NoError(t, err)
+	assert.Equal(t, key, found)
+
+	signed, _ = unknown.Sign(newQuery(t), nil)
+	signature, found, err = keys.Verify(signed)
+	assert.Equal(t, ErrBadKey, err)
+	assert.Nil(t, found)
+
+	// BADKEY is answered without a MAC, which the client reports as the server's error
+	response := ErrorResponse(newResponse(t, "192.0.2.1"), signature, nil, resultcode.BADKEY)
+	_, err = unknown.Verify(response, signature.MAC)
+	assert.Equal(t, ErrBadKey, err)
+}
+
+func TestErrorResponse_BadTime(t *testing.T) {
+	key, _ := NewKey("transfer", HmacSHA256, testSecret)
+	signed, mac := key.Sign(newQuery(t), nil)
+	signature, _, err := Parse(signed)
+	assert.NoError(t, err)
+
+	// The BADTIME response is signed, so the client can trust the server time it carries
+	response := ErrorResponse(newResponse(t, "192.0.2.1"), signature, key, resultcode.BADTIME)
+	responseSignature, err := key.Verify(response, mac)
+	assert.Equal(t, ErrBadTime, err)
+	assert.Len(t, responseSignature.Other, 6)
+}
+
+func TestStream(t *testing.T) {
+	key, _ := NewKey("transfer", HmacSHA512, testSecret)
+	request, mac := key.Sign(newQuery(t), nil)
+	signature, _, _ := Parse(request)
+
+	signer := NewStream(key, signature.MAC)
+	messages := [][]byte{
+		signer.Sign(newResponse(t, "192.0.2.1")),
+		signer.Sign(newResponse(t, "192.0.2.2")),
+		signer.Sign(newResponse(t, "192.0.2.3")),
+	}
+
+	verifier := NewStream(key, mac)
+	for _, message := range messages {
+		assert.NoError(t, verifier.Verify(message))
+	}
+
+	// Messages cannot be reordered or dropped
+	verifier = NewStream(key, mac)
+	assert.NoError(t, verifier.Verify(messages[0]))
+	assert.Equal(t, ErrBadSig, verifier.Verify(messages[2]))
+}
+
+func TestLoadKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys")
+	content := "# transfer keys\ntransfer. hmac-sha256 " + testSecret + "\n\nupdate hmac-sha512 " + testSecret + "\n"
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	keys, err := LoadKeys(path)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 2)
+	assert.Equal(t, HmacSHA512, keys.Get("UPDATE.").Algorithm)
+
+	assert.NoError(t, os.WriteFile(path, []byte("transfer hmac-md5 "+testSecret+"\n"), 0o600))
+	_, err = LoadKeys(path)
+	assert.Error(t, err)
+}
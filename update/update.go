@@ -0,0 +1,296 @@
+package update
+
+import (
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+	recordclass "dns-client-go/record-class"
+	resultcode "dns-client-go/result-code"
+	"dns-client-go/zone"
+	"net"
+)
+
+// Policy restricts who may update a zone and what they may change. A zero policy refuses every update.
+type Policy struct {
+	Clients []*net.IPNet          // source networks allowed to send updates
+	Keys    []string              // TSIG keys whose signed updates are accepted from any address
+	Names   []dns.Name            // subtrees that may be changed, the whole zone when empty
+	Types   []querytype.QueryType // types that may be changed, all when empty
+}
+
+// AllowsClient reports whether updates from ip are accepted
+func (p Policy) AllowsClient(ip net.IP) bool {
+	for _, network := range p.Clients {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsKey reports whether updates signed with the named TSIG key are accepted
+func (p Policy) AllowsKey(name string) bool {
+	for _, key := range p.Keys {
+		if dns.Name(key).Equal(dns.Name(name)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Permits reports whether the policy allows the update record to change the zone
+func (p Policy) Permits(record dns.DnsRecord) bool {
+	nameAllowed := len(p.Names) == 0
+	for _, name := range p.Names {
+		if record.Domain().IsSubdomainOf(name) {
+			nameAllowed = true
+			break
+		}
+	}
+
+	// Deleting all RRsets at a name (type ANY) would also remove the types outside the restriction
+	typeAllowed := len(p.Types) == 0
+	for _, qtype := range p.Types {
+		if record.Type() == qtype {
+			typeAllowed = true
+			break
+		}
+	}
+
+	return nameAllowed && typeAllowed
+}
+
+// Apply processes an UPDATE message against current as described in section 3 of RFC 2136: the prerequisites
+// are checked, the update section is validated as a whole and then applied to a copy of the zone. The SOA serial
+// is increased unless the update raised it itself. It returns the new zone and the difference, which is nil when
+// the update left the zone unchanged.
+func Apply(current *zone.Zone, request *dns.DnsPacket, policy Policy) (*zone.Zone, *zone.Diff, resultcode.ResultCode) {
+	if rescode := checkPrerequisites(current, request.Prerequisites()); rescode != resultcode.NOERROR {
+		return nil, nil, rescode
+	}
+
+	if rescode := prescan(current, request.Updates(), policy); rescode != resultcode.NOERROR {
+		return nil, nil, rescode
+	}
+
+	change := &change{zone: current.Clone(), origin: current.Origin}
+	for _, record := range request.Updates() {
+		change.apply(record)
+	}
+
+	if len(change.deleted) == 0 && len(change.added) == 0 && change.zone.Serial() == current.Serial() {
+		return current, nil, resultcode.NOERROR
+	}
+
+	from := current.RRset(current.Origin, querytype.SOA)[0]
+	to := change.zone.RRset(current.Origin, querytype.SOA)[0]
+	if !zone.SerialNewer(to.SOA.Serial(), from.SOA.Serial()) {
+		change.zone.Remove(to)
+		to = from.SOA.WithSerial(from.SOA.Serial() + 1)
+		change.zone.Add(to)
+	}
+
+	diff := &zone.Diff{From: from, To: to, Deleted: change.deleted, Added: change.added}
+	return change.zone, diff, resultcode.NOERROR
+}
+
+// checkPrerequisites evaluates the prerequisite section (RFC 2136 section 3.2)
+func checkPrerequisites(current *zone.Zone, prerequisites []dns.DnsRecord) resultcode.ResultCode {
+	// Value dependent prerequisites are collected per RRset and compared as a whole
+	expected := map[string][]dns.DnsRecord{}
+
+	for _, record := range prerequisites {
+		if record.TTL() != 0 {
+			return resultcode.FORMERR
+		}
+		if !current.Contains(record.Domain()) {
+			return resultcode.NOTZONE
+		}
+
+		name, qtype := record.Domain(), record.Type()
+		switch record.Class() {
+		case recordclass.ANY:
+			if record.HasData() {
+				return resultcode.FORMERR
+			}
+			if qtype == querytype.ANY {
+				if len(current.RecordsAt(name)) == 0 {
+					return resultcode.NXDOMAIN
+				}
+			} else if len(current.RRset(name, qtype)) == 0 {
+				return resultcode.NXRRSET
+			}
+		case recordclass.NONE:
+			if record.HasData() {
+				return resultcode.FORMERR
+			}
+			if qtype == querytype.ANY {
+				if len(current.RecordsAt(name)) > 0 {
+					return resultcode.YXDOMAIN
+				}
+			} else if len(current.RRset(name, qtype)) > 0 {
+				return resultcode.YXRRSET
+			}
+		case recordclass.IN:
+			key := rrsetKey(name, qtype)
+			expected[key] = append(expected[key], record)
+		default:
+			return resultcode.FORMERR
+		}
+	}
+
+	for _, rrset := range expected {
+		if !sameRRset(current.RRset(rrset[0].Domain(), rrset[0].Type()), rrset) {
+			return resultcode.NXRRSET
+		}
+	}
+
+	return resultcode.NOERROR
+}
+
+// prescan validates the whole update section before anything is changed (RFC 2136 section 3.4.1)
+func prescan(current *zone.Zone, updates []dns.DnsRecord, policy Policy) resultcode.ResultCode {
+	for _, record := range updates {
+		if !current.Contains(record.Domain()) {
+			return resultcode.NOTZONE
+		}
+
+		qtype := record.Type()
+		switch record.Class() {
+		case recordclass.IN:
+			if qtype.IsMeta() {
+				return resultcode.FORMERR
+			}
+		case recordclass.ANY:
+			if record.TTL() != 0 || record.HasData() || (qtype.IsMeta() && qtype != querytype.ANY) {
+				return resultcode.FORMERR
+			}
+		case recordclass.NONE:
+			if record.TTL() != 0 || qtype.IsMeta() {
+				return resultcode.FORMERR
+			}
+		default:
+			return resultcode.FORMERR
+		}
+
+		if !policy.Permits(record) {
+			return resultcode.REFUSED
+		}
+	}
+
+	return resultcode.NOERROR
+}
+
+// change tracks the records an update removed and added, which become the journal entry and IXFR difference
+type change struct {
+	zone    *zone.Zone
+	origin  dns.Name
+	deleted []dns.DnsRecord
+	added   []dns.DnsRecord
+}
+
+// apply performs a single update record (RFC 2136 section 3.4.2)
+func (c *change) apply(record dns.DnsRecord) {
+	name, qtype := record.Domain(), record.Type()
+	apex := name.Canonical() == c.origin
+
+	switch record.Class() {
+	case recordclass.IN:
+		c.addRecord(record, apex)
+	case recordclass.ANY:
+		for _, existing := range c.zone.RecordsAt(name) {
+			protected := apex && (existing.SOA != nil || existing.NS != nil)
+			if (qtype == querytype.ANY || existing.Type() == qtype) && !protected {
+				c.remove(existing)
+			}
+		}
+	case recordclass.NONE:
+		if qtype == querytype.SOA || (apex && qtype == querytype.NS && len(c.zone.RRset(name, querytype.NS)) <= 1) {
+			return // the zone must keep its SOA and at least one NS record
+		}
+		for _, existing := range c.zone.RRset(name, qtype) {
+			if existing.Equal(&record) {
+				c.remove(existing)
+			}
+		}
+	}
+}
+
+func (c *change) addRecord(record dns.DnsRecord, apex bool) {
+	name, qtype := record.Domain(), record.Type()
+
+	if qtype == querytype.SOA {
+		current := c.zone.RRset(name, querytype.SOA)
+		if !apex || len(current) == 0 || !zone.SerialNewer(record.SOA.Serial(), current[0].SOA.Serial()) {
+			return
+		}
+		c.zone.Remove(current[0])
+		c.zone.Add(record)
+		return
+	}
+
+	// A CNAME cannot coexist with other data at the same name
+	for _, existing := range c.zone.RecordsAt(name) {
+		if (qtype == querytype.CNAME) != (existing.Type() == querytype.CNAME) {
+			return
+		}
+		if qtype == querytype.CNAME && !existing.Equal(&record) {
+			c.remove(existing)
+		}
+	}
+
+	for _, existing := range c.zone.RRset(name, qtype) {
+		if existing.Equal(&record) {
+			if existing.TTL() == record.TTL() {
+				return
+			}
+			c.remove(existing)
+		}
+	}
+
+	c.zone.Add(record)
+	c.added = append(c.added, record)
+}
+
+func (c *change) remove(record dns.DnsRecord) {
+	if c.zone.Remove(record) {
+		c.deleted = append(c.deleted, record)
+	}
+}
+
+func rrsetKey(name dns.Name, qtype querytype.QueryType) string {
+	return name.Canonical().String() + "/" + qtype.String()
+}
+
+// sameRRset compares two RRsets ignoring order and TTLs
+func sameRRset(actual []dns.DnsRecord, expected []dns.DnsRecord) bool {
+	unique := []dns.DnsRecord{}
+	for _, record := range expected {
+		if !contains(unique, record) {
+			unique = append(unique, record)
+		}
+	}
+
+	if len(actual) != len(unique) {
+		return false
+	}
+
+	for _, record := range unique {
+		if !contains(actual, record) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func contains(records []dns.DnsRecord, record dns.DnsRecord) bool {
+	for _, existing := range records {
+		if existing.Equal(&record) {
+			return true
+		}
+	}
+
+	return false
+}
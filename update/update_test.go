@@ -0,0 +1,139 @@
+package update
+
+import (
+	"net"
+	"testing"
+
+	"dns-client-go/dns"
+	"dns-client-go/opcode"
+	querytype "dns-client-go/query-type"
+	recordclass "dns-client-go/record-class"
+	resultcode "dns-client-go/result-code"
+	"dns-client-go/zone"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testZone() *zone.Zone {
+	z := zone.New("example.com")
+	z.Add(dns.NewSOARecord("example.com", "ns1.example.com", "admin.example.com", 10, 3600, 600, 86400, 300, 3600))
+	z.Add(dns.NewNSRecord("example.com", "ns1.example.com", 3600))
+	z.Add(dns.NewARecord("ns1.example.com", "192.0.2.1", 3600))
+	z.Add(dns.NewARecord("www.example.com", "192.0.2.10", 300))
+	return z
+}
+
+func rdata(record dns.DnsRecord) []byte {
+	return record.Rdata()
+}
+
+func updateRequest(prerequisites []dns.DnsRecord, updates []dns.DnsRecord) *dns.DnsPacket {
+	request := dns.NewPacket()
+	request.Header.Opcode = opcode.UPDATE
+	request.Question = []dns.DnsQuestion{*dns.NewQuestion("example.com", querytype.SOA)}
+	request.Answers = prerequisites
+	request.Authorities = updates
+	return request
+}
+
+func TestApply_Prerequisites(t *testing.T) {
+	testCases := []struct {
+		name         string
+		prerequisite dns.DnsRecord
+		expected     resultcode.ResultCode
+	}{
+		{name: "name in use", prerequisite: dns.NewUnknownRecord("www.example.com", querytype.ANY, recordclass.ANY, nil, 0), expected: resultcode.NOERROR},
+		{name: "name not in use", prerequisite: dns.NewUnknownRecord("new.example.com", querytype.ANY, recordclass.ANY, nil, 0), expected: resultcode.NXDOMAIN},
+		{name: "rrset exists", prerequisite: dns.NewUnknownRecord("www.example.com", querytype.AAAA, recordclass.ANY, nil, 0), expected: resultcode.NXRRSET},
+		{name: "name must not exist", prerequisite: dns.NewUnknownRecord("www.example.com", querytype.ANY, recordclass.NONE, nil, 0), expected: resultcode.YXDOMAIN},
+		{name: "rrset must not exist", prerequisite: dns.NewUnknownRecord("www.example.com", querytype.A, recordclass.NONE, nil, 0), expected: resultcode.YXRRSET},
+		{name: "value dependent match", prerequisite: dns.NewARecord("www.example.com", "192.0.2.10", 0), expected: resultcode.NOERROR},
+		{name: "value dependent mismatch", prerequisite: dns.NewARecord("www.example.com", "192.0.2.11", 0), expected: resultcode.NXRRSET},
+		{name: "outside zone", prerequisite: dns.NewUnknownRecord("www.example.org", querytype.ANY, recordclass.ANY, nil, 0), expected: resultcode.NOTZONE},
+		{name: "ttl set", prerequisite: dns.NewUnknownRecord("www.example.com", querytype.ANY, recordclass.ANY, nil, 60), expected: resultcode.FORMERR},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, rescode := Apply(testZone(), updateRequest([]dns.DnsRecord{tc.prerequisite}, nil), Policy{})
+			assert.Equal(t, tc.expected, rescode)
+		})
+	}
+}
+
+func TestApply_Updates(t *testing.T) {
+	current := testZone()
+	updates := []dns.DnsRecord{
+		dns.NewUnknownRecord("www.example.com", querytype.A, recordclass.ANY, nil, 0),
+		dns.NewARecord("www.example.com", "192.0.2.11", 300),
+		dns.NewARecord("host.example.com", "192.0.2.20", 60),
+		dns.NewCNAMERecord("host.example.com", "www.example.com", 60), // ignored, the name already holds data
+		dns.NewUnknownRecord("example.com", querytype.NS, recordclass.NONE, rdata(dns.NewNSRecord("example.com", "ns1.example.com", 0)), 0),
+	}
+
+	updated, diff, rescode := Apply(current, updateRequest(nil, updates), Policy{})
+
+	assert.Equal(t, resultcode.NOERROR, rescode)
+	assert.Equal(t, uint32(11), updated.Serial(), "the serial is increased")
+	assert.Equal(t, uint32(10), current.Serial(), "the current zone is left untouched")
+	assert.Equal(t, []dns.DnsRecord{dns.NewARecord("www.example.com", "192.0.2.11", 300)}, updated.RRset("www.example.com", querytype.A))
+	assert.Len(t, updated.RRset("host.example.com", querytype.CNAME), 0)
+	assert.Len(t, updated.RRset("example.com", querytype.NS), 1, "the last NS record is kept")
+
+	assert.Equal(t, uint32(10), diff.From.SOA.Serial())
+	assert.Equal(t, uint32(11), diff.To.SOA.Serial())
+	assert.Len(t, diff.Deleted, 1)
+	assert.Len(t, diff.Added, 2)
+}
+
+func TestApply_NoChange(t *testing.T) {
+	current := testZone()
+	updated, diff, rescode := Apply(current, updateRequest(nil, []dns.DnsRecord{dns.NewARecord("www.example.com", "192.0.2.10", 300)}), Policy{})
+
+	assert.Equal(t, resultcode.NOERROR, rescode)
+	assert.Nil(t, diff)
+	assert.Same(t, current, updated)
+}
+
+func TestPolicy(t *testing.T) {
+	_, network, _ := net.ParseCIDR("10.0.0.0/8")
+	policy := Policy{Clients: []*net.IPNet{network}, Names: []dns.Name{"dhcp.example.com"}, Types: []querytype.QueryType{querytype.A}}
+
+	assert.True(t, policy.AllowsClient(net.ParseIP("10.1.2.3")))
+	assert.False(t, policy.AllowsClient(net.ParseIP("192.0.2.1")))
+
+	_, _, rescode := Apply(testZone(), updateRequest(nil, []dns.DnsRecord{dns.NewARecord("www.example.com", "192.0.2.11", 300)}), policy)
+	assert.Equal(t, resultcode.REFUSED, rescode)
+
+	_, _, rescode = Apply(testZone(), updateRequest(nil, []dns.DnsRecord{dns.NewARecord("pc1.dhcp.example.com", "10.0.0.5", 300)}), policy)
+	assert.Equal(t, resultcode.NOERROR, rescode)
+}
+
+func TestPolicy_Types(t *testing.T) {
+	current := testZone()
+	current.Add(dns.NewTXTRecord("www.example.com", []string{"v=spf1 -all"}, 300))
+	policy := Policy{Types: []querytype.QueryType{querytype.A}}
+
+	testCases := []struct {
+		name     string
+		update   dns.DnsRecord
+		expected resultcode.ResultCode
+	}{
+		{name: "delete permitted rrset", update: dns.NewUnknownRecord("www.example.com", querytype.A, recordclass.ANY, nil, 0), expected: resultcode.NOERROR},
+		{name: "delete other rrset", update: dns.NewUnknownRecord("www.example.com", querytype.TXT, recordclass.ANY, nil, 0), expected: resultcode.REFUSED},
+		{name: "delete all rrsets", update: dns.NewUnknownRecord("www.example.com", querytype.ANY, recordclass.ANY, nil, 0), expected: resultcode.REFUSED},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, _, rescode := Apply(current, updateRequest(nil, []dns.DnsRecord{tc.update}), policy)
+			assert.Equal(t, tc.expected, rescode)
+		})
+	}
+	assert.Len(t, current.RRset("www.example.com", querytype.TXT), 1)
+
+	// Without a type restriction all RRsets at the name may be deleted
+	updated, _, rescode := Apply(current, updateRequest(nil, []dns.DnsRecord{dns.NewUnknownRecord("www.example.com", querytype.ANY, recordclass.ANY, nil, 0)}), Policy{})
+	assert.Equal(t, resultcode.NOERROR, rescode)
+	assert.Empty(t, updated.RecordsAt("www.example.com"))
+}
@@ -0,0 +1,42 @@
+package zone
+
+import (
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+	"fmt"
+)
+
+// Diff is the difference between two versions of a zone, as carried by IXFR and kept in update journals.
+// From and To are the SOA records before and after the change, the SOA itself is not part of Deleted or Added.
+type Diff struct {
+	From    dns.DnsRecord
+	To      dns.DnsRecord
+	Deleted []dns.DnsRecord
+	Added   []dns.DnsRecord
+}
+
+// Apply replays the difference on a copy of z, which must be at the serial the difference starts from
+func (d *Diff) Apply(z *Zone) (*Zone, error) {
+	if z.Serial() != d.From.SOA.Serial() {
+		return nil, fmt.Errorf("difference starts at serial %v, zone is at %v", d.From.SOA.Serial(), z.Serial())
+	}
+
+	updated := z.Clone()
+	for _, record := range d.Deleted {
+		updated.Remove(record)
+	}
+	for _, soa := range updated.RRset(updated.Origin, querytype.SOA) {
+		updated.Remove(soa)
+	}
+	updated.Add(d.To)
+	for _, record := range d.Added {
+		updated.Add(record)
+	}
+
+	return updated, nil
+}
+
+// SerialNewer compares SOA serials using the sequence space arithmetic of RFC 1982
+func SerialNewer(serial uint32, than uint32) bool {
+	return serial != than && int32(serial-than) > 0
+}
@@ -0,0 +1,83 @@
+package zone
+
+import (
+	"dns-client-go/dns"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+// Store holds the zones the server answers authoritatively. Readers never lock: every change
+// builds a new map and swaps it in atomically, so a query always sees a complete zone.
+type Store struct {
+	zones atomic.Pointer[map[dns.Name]*Zone]
+	mu    sync.Mutex // serializes writers
+}
+
+func NewStore() *Store {
+	store := &Store{}
+	store.zones.Store(&map[dns.Name]*Zone{})
+	return store
+}
+
+// Get returns the zone with exactly the given origin
+func (s *Store) Get(origin dns.Name) *Zone {
+	return (*s.zones.Load())[origin.Canonical()]
+}
+
+// Find returns the most specific zone containing qname, or nil when none does
+func (s *Store) Find(qname dns.Name) *Zone {
+	zones := *s.zones.Load()
+	name := qname.Canonical()
+	for {
+		if zone, ok := zones[name]; ok {
+			return zone
+		}
+
+		if name.IsRoot() {
+			return nil
+		}
+		name = name.Parent()
+	}
+}
+
+// Replace installs the zone, swapping out any previous version with the same origin
+func (s *Store) Replace(zone *Zone) {
+	s.update(func(zones map[dns.Name]*Zone) {
+		zones[zone.Origin] = zone
+	})
+}
+
+func (s *Store) Remove(origin dns.Name) {
+	s.update(func(zones map[dns.Name]*Zone) {
+		delete(zones, origin.Canonical())
+	})
+}
+
+// Origins lists the origins of all zones in the store in canonical order
+func (s *Store) Origins() []dns.Name {
+	zones := *s.zones.Load()
+	origins := make([]dns.Name, 0, len(zones))
+	for origin := range zones {
+		origins = append(origins, origin)
+	}
+	sort.Slice(origins, func(i, j int) bool {
+		return origins[i].Compare(origins[j]) < 0
+	})
+
+	return origins
+}
+
+func (s *Store) update(change func(map[dns.Name]*Zone)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := *s.zones.Load()
+	next := make(map[dns.Name]*Zone, len(current)+1)
+	for origin, zone := range current {
+		next[origin] = zone
+	}
+	change(next)
+
+	s.zones.Store(&next)
+}
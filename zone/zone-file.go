@@ -0,0 +1,477 @@
+package zone
+
+import (
+	"bufio"
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+	recordclass "dns-client-go/record-class"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const defaultTTL = 3600
+
+// LoadFile reads a zone in RFC 1035 master file format
+func LoadFile(path string, origin dns.Name) (*Zone, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	z, err := Parse(file, origin)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return z, nil
+}
+
+// Parse reads a zone in master file format. It supports the $ORIGIN and $TTL directives,
+// relative names, omitted owners and multi-line records in parentheses.
+func Parse(reader io.Reader, origin dns.Name) (*Zone, error) {
+	ascii, err := dns.ToASCII(origin.String())
+	if err != nil {
+		return nil, err
+	}
+	z := New(dns.Name(ascii))
+	parser := &zoneParser{origin: z.Origin.String(), ttl: defaultTTL}
+
+	scanner := bufio.NewScanner(reader)
+	var pending []string
+	var pendingOwner bool
+	depth := 0
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		tokens, opened, err := tokenize(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+
+		if depth == 0 {
+			pendingOwner = len(line) > 0 && !unicode.IsSpace(rune(line[0]))
+		}
+		pending = append(pending, tokens...)
+		depth += opened
+		if depth < 0 {
+			return nil, fmt.Errorf("line %d: unbalanced parentheses", lineNumber)
+		}
+		if depth > 0 || len(pending) == 0 {
+			continue
+		}
+
+		record, err := parser.parse(pending, pendingOwner)
+		pending = nil
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNumber, err)
+		}
+
+		if record == nil {
+			continue // a directive
+		}
+
+		if !z.Contains(record.Domain()) {
+			return nil, fmt.Errorf("line %d: %v is outside of zone %v", lineNumber, record.Domain(), z.Origin)
+		}
+		z.Add(*record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if depth != 0 {
+		return nil, errors.New("unbalanced parentheses at end of file")
+	}
+
+	if z.SOA() == nil {
+		return nil, fmt.Errorf("zone %v has no SOA record", z.Origin)
+	}
+
+	return z, nil
+}
+
+type zoneParser struct {
+	origin    string
+	ttl       uint32
+	lastOwner dns.Name
+	haveOwner bool
+}
+
+// tokenize splits a line into fields, dropping comments and parentheses. It returns how many
+// parentheses were opened (negative when closed) so records can span lines.
+func tokenize(line string) ([]string, int, error) {
+	var tokens []string
+	var current strings.Builder
+	inQuotes := false
+	depth := 0
+
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case inQuotes:
+			current.WriteByte(c)
+			if c == '\\' && i+1 < len(line) {
+				i++
+				current.WriteByte(line[i])
+			} else if c == '"' {
+				inQuotes = false
+			}
+		case c == '"':
+			current.WriteByte(c)
+			inQuotes = true
+		case c == '\\' && i+1 < len(line):
+			// An escaped character belongs to the field, e.g. a space or a semicolon in a name
+			current.WriteByte(c)
+			i++
+			current.WriteByte(line[i])
+		case c == ';':
+			flush()
+			return tokens, depth, nil
+		case c == '(':
+			flush()
+			depth++
+		case c == ')':
+			flush()
+			depth--
+		case c == ' ' || c == '\t':
+			flush()
+		default:
+			current.WriteByte(c)
+		}
+	}
+
+	if inQuotes {
+		return nil, 0, errors.New("unterminated quoted string")
+	}
+
+	flush()
+	return tokens, depth, nil
+}
+
+func (zp *zoneParser) parse(tokens []string, hasOwner bool) (*dns.DnsRecord, error) {
+	switch strings.ToUpper(tokens[0]) {
+	case "$ORIGIN":
+		if len(tokens) != 2 {
+			return nil, errors.New("$ORIGIN expects a single name")
+		}
+		origin, err := zp.name(tokens[1])
+		if err != nil {
+			return nil, err
+		}
+		zp.origin = origin
+		return nil, nil
+	case "$TTL":
+		if len(tokens) != 2 {
+			return nil, errors.New("$TTL expects a single value")
+		}
+		ttl, err := ParseTTL(tokens[1])
+		if err != nil {
+			return nil, err
+		}
+		zp.ttl = ttl
+		return nil, nil
+	}
+
+	if hasOwner {
+		owner, err := zp.name(tokens[0])
+		if err != nil {
+			return nil, err
+		}
+		zp.lastOwner = dns.Name(owner)
+		zp.haveOwner = true
+		tokens = tokens[1:]
+	}
+	if !zp.haveOwner {
+		return nil, errors.New("record without an owner name")
+	}
+	owner := zp.lastOwner
+
+	ttl := zp.ttl
+	for len(tokens) > 0 {
+		if strings.EqualFold(tokens[0], "IN") {
+			tokens = tokens[1:]
+			continue
+		}
+
+		value, err := ParseTTL(tokens[0])
+		if err != nil {
+			break
+		}
+		ttl = value
+		tokens = tokens[1:]
+	}
+
+	if len(tokens) == 0 {
+		return nil, errors.New("missing record type")
+	}
+
+	return zp.record(owner, ttl, strings.ToUpper(tokens[0]), tokens[1:])
+}
+
+func (zp *zoneParser) record(owner dns.Name, ttl uint32, rrtype string, rdata []string) (*dns.DnsRecord, error) {
+	expect := func(count int) error {
+		if len(rdata) != count {
+			return fmt.Errorf("%v record expects %d fields, got %d", rrtype, count, len(rdata))
+		}
+		return nil
+	}
+
+	var record dns.DnsRecord
+	switch rrtype {
+	case "A":
+		if err := expect(1); err != nil {
+			return nil, err
+		}
+		ip := net.ParseIP(rdata[0])
+		if ip == nil || ip.To4() == nil {
+			return nil, fmt.Errorf("invalid IPv4 address %q", rdata[0])
+		}
+		record = dns.NewARecord(owner, ip.To4().String(), ttl)
+	case "AAAA":
+		if err := expect(1); err != nil {
+			return nil, err
+		}
+		ip := net.ParseIP(rdata[0])
+		if ip == nil || ip.To4() != nil {
+			return nil, fmt.Errorf("invalid IPv6 address %q", rdata[0])
+		}
+		record = dns.NewAAAARecord(owner, ip.String(), ttl)
+	case "NS":
+		if err := expect(1); err != nil {
+			return nil, err
+		}
+		host, err := zp.name(rdata[0])
+		if err != nil {
+			return nil, err
+		}
+		record = dns.NewNSRecord(owner, host, ttl)
+	case "CNAME":
+		if err := expect(1); err != nil {
+			return nil, err
+		}
+		host, err := zp.name(rdata[0])
+		if err != nil {
+			return nil, err
+		}
+		record = dns.NewCNAMERecord(owner, host, ttl)
+	case "PTR":
+		if err := expect(1); err != nil {
+			return nil, err
+		}
+		host, err := zp.name(rdata[0])
+		if err != nil {
+			return nil, err
+		}
+		record = dns.NewPTRRecord(owner, host, ttl)
+	case "TXT":
+		if len(rdata) == 0 {
+			return nil, errors.New("TXT record expects at least one string")
+		}
+		texts := make([]string, len(rdata))
+		for i, token := range rdata {
+			text, err := characterString(token)
+			if err != nil {
+				return nil, err
+			}
+			texts[i] = text
+		}
+		record = dns.NewTXTRecord(owner, texts, ttl)
+	case "MX":
+		if err := expect(2); err != nil {
+			return nil, err
+		}
+		priority, err := strconv.ParseUint(rdata[0], 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("invalid MX priority %q", rdata[0])
+		}
+		host, err := zp.name(rdata[1])
+		if err != nil {
+			return nil, err
+		}
+		record = dns.NewMXRecord(owner, host, uint16(priority), ttl)
+	case "SOA":
+		if err := expect(7); err != nil {
+			return nil, err
+		}
+		serial, err := strconv.ParseUint(rdata[2], 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid SOA serial %q", rdata[2])
+		}
+		var timers [4]uint32
+		for i := range timers {
+			if timers[i], err = ParseTTL(rdata[3+i]); err != nil {
+				return nil, err
+			}
+		}
+		mname, err := zp.name(rdata[0])
+		if err != nil {
+			return nil, err
+		}
+		rname, err := zp.name(rdata[1])
+		if err != nil {
+			return nil, err
+		}
+		record = dns.NewSOARecord(owner, mname, rname, uint32(serial), timers[0], timers[1], timers[2], timers[3], ttl)
+	default:
+		qtype, ok := querytype.Parse(rrtype)
+		if !ok || len(rdata) < 2 || rdata[0] != "\\#" {
+			return nil, fmt.Errorf("unsupported record type %v", rrtype)
+		}
+
+		data, err := genericRdata(rdata[1:])
+		if err != nil {
+			return nil, err
+		}
+		record = dns.NewUnknownRecord(owner, qtype, recordclass.IN, data, ttl)
+	}
+
+	return &record, nil
+}
+
+// characterString decodes a possibly quoted string with \" style and \DDD decimal escapes
+func characterString(token string) (string, error) {
+	if len(token) >= 2 && token[0] == '"' && token[len(token)-1] == '"' {
+		token = token[1 : len(token)-1]
+	}
+
+	var text strings.Builder
+	for i := 0; i < len(token); i++ {
+		c := token[i]
+		if c != '\\' {
+			text.WriteByte(c)
+			continue
+		}
+
+		if i+4 <= len(token) && isDigits(token[i+1:i+4]) {
+			value, _ := strconv.Atoi(token[i+1 : i+4])
+			if value > 255 {
+				return "", fmt.Errorf("invalid escape in %q", token)
+			}
+			text.WriteByte(byte(value))
+			i += 3
+			continue
+		}
+
+		if i+1 >= len(token) {
+			return "", fmt.Errorf("dangling escape in %q", token)
+		}
+		i++
+		text.WriteByte(token[i])
+	}
+
+	return text.String(), nil
+}
+
+func isDigits(value string) bool {
+	for i := 0; i < len(value); i++ {
+		if value[i] < '0' || value[i] > '9' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// genericRdata decodes the "\# length hex" form of RFC 3597 used for types without a presentation format
+func genericRdata(fields []string) ([]byte, error) {
+	length, err := strconv.Atoi(fields[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid generic data length %q", fields[0])
+	}
+
+	data, err := hex.DecodeString(strings.Join(fields[1:], ""))
+	if err != nil || len(data) != length {
+		return nil, errors.New("invalid generic record data")
+	}
+
+	return data, nil
+}
+
+// ParseRecord reads a single record in presentation format with absolute names, as written by DnsRecord.String
+func ParseRecord(line string) (*dns.DnsRecord, error) {
+	tokens, depth, err := tokenize(line)
+	if err != nil {
+		return nil, err
+	}
+	if depth != 0 || len(tokens) == 0 {
+		return nil, fmt.Errorf("invalid record %q", line)
+	}
+
+	parser := &zoneParser{ttl: defaultTTL}
+	record, err := parser.parse(tokens, true)
+	if err == nil && record == nil {
+		return nil, fmt.Errorf("invalid record %q", line)
+	}
+
+	return record, err
+}
+
+// name turns a name from the zone file into an absolute name without the trailing dot, with Unicode
+// labels converted to A-labels
+func (zp *zoneParser) name(name string) (string, error) {
+	name, err := dns.ToASCII(name)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case name == "@":
+		return zp.origin, nil
+	case dns.Name(name).IsAbsolute():
+		return strings.TrimSuffix(name, "."), nil
+	case zp.origin == "":
+		return name, nil
+	default:
+		return name + "." + zp.origin, nil
+	}
+}
+
+// ParseTTL reads a duration given in seconds or with BIND style units, e.g. 3600, 1h or 1d12h
+func ParseTTL(value string) (uint32, error) {
+	if seconds, err := strconv.ParseUint(value, 10, 32); err == nil {
+		return uint32(seconds), nil
+	}
+
+	units := map[byte]uint64{'s': 1, 'm': 60, 'h': 3600, 'd': 86400, 'w': 604800}
+	var total, number uint64
+	digits := 0
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		if c >= '0' && c <= '9' {
+			number = number*10 + uint64(c-'0')
+			digits++
+			continue
+		}
+
+		multiplier, ok := units[byte(unicode.ToLower(rune(c)))]
+		if !ok || digits == 0 {
+			return 0, fmt.Errorf("invalid TTL %q", value)
+		}
+		total += number * multiplier
+		number, digits = 0, 0
+	}
+
+	if digits > 0 || total > 0xffffffff || len(value) == 0 {
+		return 0, fmt.Errorf("invalid TTL %q", value)
+	}
+
+	return uint32(total), nil
+}
@@ -0,0 +1,99 @@
+package zone
+
+import (
+	"strings"
+	"testing"
+
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const exampleZone = `
+$ORIGIN example.com.
+$TTL 1h
+@       IN SOA ns1 hostmaster (
+                2024010101 ; serial
+                1h 10m 1w 5m )
+        IN NS  ns1
+ns1     IN A   192.0.2.1
+www 300 IN A   192.0.2.10
+        IN AAAA 2001:db8::10
+mail    MX     10 www
+alias   CNAME  www.example.com.
+www     TXT    "v=spf1 -all" "quote \" and \\ backslash" plain
+10      PTR    www
+`
+
+func TestParse(t *testing.T) {
+	z, err := Parse(strings.NewReader(exampleZone), "example.com")
+	assert.NoError(t, err)
+
+	soa := z.SOA()
+	assert.NotNil(t, soa)
+	assert.Equal(t, uint32(2024010101), soa.Serial())
+	assert.Equal(t, uint32(3600), soa.Refresh())
+	assert.Equal(t, uint32(604800), soa.Expire())
+
+	www := z.RRset("www.example.com", querytype.A)
+	assert.Len(t, www, 1)
+	assert.Equal(t, uint32(300), www[0].TTL())
+	assert.Len(t, z.RRset("www.example.com", querytype.AAAA), 1, "omitted owner repeats the previous one")
+	assert.Equal(t, uint32(3600), z.RRset("example.com", querytype.NS)[0].TTL())
+	assert.Equal(t, "www.example.com", z.RRset("alias.example.com", querytype.CNAME)[0].CNAME.Host())
+	assert.Equal(t, []string{"v=spf1 -all", `quote " and \ backslash`, "plain"}, z.RRset("www.example.com", querytype.TXT)[0].TXT.Texts())
+	assert.Equal(t, "www.example.com", z.RRset("10.example.com", querytype.PTR)[0].PTR.Host())
+}
+
+func TestParse_Unicode(t *testing.T) {
+	z, err := Parse(strings.NewReader("@ SOA ns hm 1 1 1 1 1\nmünchen A 192.0.2.1\nwww CNAME münchen.bücher.de.\n"), "bücher.de")
+	assert.NoError(t, err)
+
+	assert.Equal(t, dns.Name("xn--bcher-kva.de"), z.Origin)
+	assert.Len(t, z.RRset("xn--mnchen-3ya.xn--bcher-kva.de", querytype.A), 1)
+	assert.Equal(t, "xn--mnchen-3ya.xn--bcher-kva.de", z.RRset("www.xn--bcher-kva.de", querytype.CNAME)[0].CNAME.Host())
+}
+
+func TestParseRecord_RoundTrip(t *testing.T) {
+	for _, line := range []string{
+		"www.example.com. 300 IN A 192.0.2.1",
+		"www.example.com. 300 IN TXT \"a \\\" quote\" \"\\009tab\"",
+		"1.2.0.192.in-addr.arpa. 300 IN PTR www.example.com.",
+		`a\;b\032c.example.com. 300 IN CNAME d\.e.example.com.`,
+	} {
+		record, err := ParseRecord(line)
+		assert.NoError(t, err)
+		assert.Equal(t, line, record.String())
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	testCases := []struct {
+		name string
+		data string
+	}{
+		{name: "missing soa", data: "www.example.com. IN A 192.0.2.1"},
+		{name: "out of zone", data: "@ SOA ns hm 1 1 1 1 1\nwww.example.org. A 192.0.2.1"},
+		{name: "bad address", data: "@ SOA ns hm 1 1 1 1 1\nwww A 300.0.0.1"},
+		{name: "unbalanced", data: "@ SOA ns hm ( 1 1 1 1 1"},
+		{name: "unknown type", data: "@ SOA ns hm 1 1 1 1 1\nwww HINFO a b"},
+		{name: "invalid name", data: "@ SOA ns hm 1 1 1 1 1\n-bü A 192.0.2.1"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tc.data), "example.com")
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestParseTTL(t *testing.T) {
+	ttl, err := ParseTTL("1d12h")
+	assert.NoError(t, err)
+	assert.Equal(t, uint32(129600), ttl)
+
+	_, err = ParseTTL("12x")
+	assert.Error(t, err)
+}
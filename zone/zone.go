@@ -0,0 +1,220 @@
+package zone
+
+import (
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+	resultcode "dns-client-go/result-code"
+	"sort"
+)
+
+// maxCNAMEChain bounds how many CNAMEs inside the zone are followed for a single answer
+const maxCNAMEChain = 8
+
+// Zone holds the records a server is authoritative for. A zone that has been handed to a Store
+// must be treated as read-only, changes are made on a Clone which then replaces it.
+type Zone struct {
+	Origin  dns.Name                     // canonical
+	records map[dns.Name][]dns.DnsRecord // keyed by the canonical owner name
+}
+
+// Answer is the outcome of looking up a name in a zone
+type Answer struct {
+	Rescode       resultcode.ResultCode
+	Authoritative bool // false for referrals to a delegated child zone
+	Answers       []dns.DnsRecord
+	Authorities   []dns.DnsRecord
+	Resources     []dns.DnsRecord
+}
+
+func New(origin dns.Name) *Zone {
+	return &Zone{
+		Origin:  origin.Canonical(),
+		records: map[dns.Name][]dns.DnsRecord{},
+	}
+}
+
+func (z *Zone) Contains(name dns.Name) bool {
+	return name.IsSubdomainOf(z.Origin)
+}
+
+// Add inserts the record unless an equal record is already present
+func (z *Zone) Add(record dns.DnsRecord) {
+	owner := record.Domain().Canonical()
+	for i, existing := range z.records[owner] {
+		if existing.Equal(&record) {
+			z.records[owner][i] = record // keep the newest TTL
+			return
+		}
+	}
+
+	z.records[owner] = append(z.records[owner], record)
+}
+
+// Remove deletes the record and reports whether it was present
+func (z *Zone) Remove(record dns.DnsRecord) bool {
+	owner := record.Domain().Canonical()
+	for i, existing := range z.records[owner] {
+		if existing.Equal(&record) {
+			z.records[owner] = append(z.records[owner][:i:i], z.records[owner][i+1:]...)
+			if len(z.records[owner]) == 0 {
+				delete(z.records, owner)
+			}
+			return true
+		}
+	}
+
+	return false
+}
+
+// RecordsAt returns every record owned by name
+func (z *Zone) RecordsAt(name dns.Name) []dns.DnsRecord {
+	return append([]dns.DnsRecord(nil), z.records[name.Canonical()]...)
+}
+
+// RRset returns the records of the given type owned by name
+func (z *Zone) RRset(name dns.Name, qtype querytype.QueryType) []dns.DnsRecord {
+	var rrset []dns.DnsRecord
+	for _, record := range z.records[name.Canonical()] {
+		if record.Type() == qtype {
+			rrset = append(rrset, record)
+		}
+	}
+
+	return rrset
+}
+
+func (z *Zone) SOA() *dns.SOARecord {
+	soa := z.RRset(z.Origin, querytype.SOA)
+	if len(soa) == 0 {
+		return nil
+	}
+
+	return soa[0].SOA
+}
+
+func (z *Zone) Serial() uint32 {
+	if soa := z.SOA(); soa != nil {
+		return soa.Serial()
+	}
+
+	return 0
+}
+
+// Records returns every record of the zone with the SOA first and the rest in canonical order (RFC 4034
+// section 6.1), which is the order used by zone transfers
+func (z *Zone) Records() []dns.DnsRecord {
+	owners := make([]dns.Name, 0, len(z.records))
+	for owner := range z.records {
+		owners = append(owners, owner)
+	}
+	sort.Slice(owners, func(i, j int) bool {
+		return owners[i].Compare(owners[j]) < 0
+	})
+
+	var records []dns.DnsRecord
+	records = append(records, z.RRset(z.Origin, querytype.SOA)...)
+	for _, owner := range owners {
+		for _, record := range z.records[owner] {
+			if record.SOA == nil {
+				records = append(records, record)
+			}
+		}
+	}
+
+	return records
+}
+
+func (z *Zone) Clone() *Zone {
+	clone := New(z.Origin)
+	for owner, records := range z.records {
+		clone.records[owner] = append([]dns.DnsRecord(nil), records...)
+	}
+
+	return clone
+}
+
+// Lookup answers qname from the zone data, following CNAMEs that stay inside the zone
+// and returning a referral when the name lies below a delegation.
+func (z *Zone) Lookup(qname dns.Name, qtype querytype.QueryType) *Answer {
+	answer := &Answer{Rescode: resultcode.NOERROR, Authoritative: true}
+
+	for i := 0; i < maxCNAMEChain; i++ {
+		if !z.Contains(qname) {
+			return answer
+		}
+
+		if cut := z.delegation(qname); cut != "" {
+			if len(answer.Answers) > 0 {
+				return answer // the CNAME chain leaves our authority, the client resolves the rest
+			}
+			answer.Authoritative = false
+			answer.Authorities = z.RRset(cut, querytype.NS)
+			answer.Resources = z.glue(answer.Authorities)
+			return answer
+		}
+
+		owned := z.records[qname.Canonical()]
+		if len(owned) == 0 {
+			if !z.hasDescendants(qname) {
+				answer.Rescode = resultcode.NXDOMAIN
+			}
+			answer.Authorities = z.RRset(z.Origin, querytype.SOA)
+			return answer
+		}
+
+		rrset := z.RRset(qname, qtype)
+		if len(rrset) > 0 {
+			answer.Answers = append(answer.Answers, rrset...)
+			return answer
+		}
+
+		cname := z.RRset(qname, querytype.CNAME)
+		if len(cname) == 0 {
+			answer.Authorities = z.RRset(z.Origin, querytype.SOA)
+			return answer
+		}
+
+		answer.Answers = append(answer.Answers, cname[0])
+		qname = dns.Name(cname[0].CNAME.Host())
+	}
+
+	return answer
+}
+
+// delegation returns the closest name between the origin and qname that holds an NS RRset,
+// i.e. the point where authority is handed to a child zone.
+func (z *Zone) delegation(qname dns.Name) dns.Name {
+	name := qname.Canonical()
+	var cut dns.Name
+	for name != z.Origin && name.IsSubdomainOf(z.Origin) {
+		if len(z.RRset(name, querytype.NS)) > 0 {
+			cut = name
+		}
+		name = name.Parent()
+	}
+
+	return cut
+}
+
+// glue returns the addresses held in the zone for the given NS records
+func (z *Zone) glue(nsRecords []dns.DnsRecord) []dns.DnsRecord {
+	var glue []dns.DnsRecord
+	for _, ns := range nsRecords {
+		host := dns.Name(ns.NS.Host())
+		glue = append(glue, z.RRset(host, querytype.A)...)
+		glue = append(glue, z.RRset(host, querytype.AAAA)...)
+	}
+
+	return glue
+}
+
+// hasDescendants tells an empty non-terminal, which exists without records of its own, from a missing name
+func (z *Zone) hasDescendants(name dns.Name) bool {
+	for owner := range z.records {
+		if owner.IsSubdomainOf(name) && !owner.Equal(name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,99 @@
+package zone
+
+import (
+	"testing"
+
+	"dns-client-go/dns"
+	querytype "dns-client-go/query-type"
+	resultcode "dns-client-go/result-code"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testZone() *Zone {
+	z := New("example.com.")
+	z.Add(dns.NewSOARecord("example.com", "ns1.example.com", "admin.example.com", 1, 3600, 600, 86400, 300, 3600))
+	z.Add(dns.NewNSRecord("example.com", "ns1.example.com", 3600))
+	z.Add(dns.NewARecord("ns1.example.com", "192.0.2.1", 3600))
+	z.Add(dns.NewARecord("www.example.com", "192.0.2.10", 300))
+	z.Add(dns.NewCNAMERecord("alias.example.com", "www.example.com", 300))
+	z.Add(dns.NewARecord("host.deep.example.com", "192.0.2.20", 300))
+	z.Add(dns.NewNSRecord("sub.example.com", "ns.sub.example.com", 3600))
+	z.Add(dns.NewARecord("ns.sub.example.com", "192.0.2.53", 3600))
+	return z
+}
+
+func TestZone_Lookup(t *testing.T) {
+	z := testZone()
+
+	testCases := []struct {
+		name          string
+		qname         dns.Name
+		qtype         querytype.QueryType
+		rescode       resultcode.ResultCode
+		authoritative bool
+		answers       int
+		authorities   int
+		resources     int
+	}{
+		{name: "answer", qname: "WWW.example.com", qtype: querytype.A, rescode: resultcode.NOERROR, authoritative: true, answers: 1},
+		{name: "cname chased", qname: "alias.example.com", qtype: querytype.A, rescode: resultcode.NOERROR, authoritative: true, answers: 2},
+		{name: "nodata", qname: "www.example.com", qtype: querytype.MX, rescode: resultcode.NOERROR, authoritative: true, authorities: 1},
+		{name: "empty non-terminal", qname: "deep.example.com", qtype: querytype.A, rescode: resultcode.NOERROR, authoritative: true, authorities: 1},
+		{name: "nxdomain", qname: "missing.example.com", qtype: querytype.A, rescode: resultcode.NXDOMAIN, authoritative: true, authorities: 1},
+		{name: "referral", qname: "www.sub.example.com", qtype: querytype.A, rescode: resultcode.NOERROR, authoritative: false, authorities: 1, resources: 1},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			answer := z.Lookup(tc.qname, tc.qtype)
+
+			assert.Equal(t, tc.rescode, answer.Rescode)
+			assert.Equal(t, tc.authoritative, answer.Authoritative)
+			assert.Len(t, answer.Answers, tc.answers)
+			assert.Len(t, answer.Authorities, tc.authorities)
+			assert.Len(t, answer.Resources, tc.resources)
+		})
+	}
+}
+
+func TestZone_AddRemove(t *testing.T) {
+	z := testZone()
+
+	z.Add(dns.NewARecord("www.example.com", "192.0.2.10", 60))
+	assert.Len(t, z.RRset("www.example.com", querytype.A), 1, "equal records are not duplicated")
+
+	assert.True(t, z.Remove(dns.NewARecord("www.Example.com", "192.0.2.10", 0)))
+	assert.False(t, z.Remove(dns.NewARecord("www.example.com", "192.0.2.10", 0)))
+	assert.Equal(t, resultcode.NXDOMAIN, z.Lookup("www.example.com", querytype.A).Rescode)
+}
+
+func TestStore_Find(t *testing.T) {
+	store := NewStore()
+	store.Replace(testZone())
+	store.Replace(New("sub.example.com"))
+
+	assert.Equal(t, dns.Name("example.com"), store.Find("www.example.com").Origin)
+	assert.Equal(t, dns.Name("sub.example.com"), store.Find("a.b.sub.example.com").Origin)
+	assert.Nil(t, store.Find("badexample.com"))
+
+	store.Remove("sub.example.com")
+	assert.Equal(t, dns.Name("example.com"), store.Find("a.b.sub.example.com").Origin)
+}
+
+func TestStore_Find_EscapedDot(t *testing.T) {
+	store := NewStore()
+	store.Replace(testZone())
+
+	// One label holding a dot, which is not inside example.com
+	assert.Nil(t, store.Find(`www\.example.com`))
+	assert.Equal(t, dns.Name("example.com"), store.Find(`a\.b.example.com`).Origin)
+	assert.Nil(t, store.Find(`example\.com`))
+}
+
+func TestSerialNewer(t *testing.T) {
+	assert.True(t, SerialNewer(2, 1))
+	assert.False(t, SerialNewer(1, 1))
+	assert.False(t, SerialNewer(1, 2))
+	assert.True(t, SerialNewer(1, 0xffffffff), "serials wrap around")
+}